@@ -0,0 +1,87 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// serveAdmin serves the dashboard, the JSON API and the metrics on addr.
+// The API and metrics are protected by the admin token when one is
+// configured, and the API is read-only without one.
+func (d *daemon) serveAdmin(addr string) {
+	static, _ := fs.Sub(webFiles, "web")
+
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/status", d.handleStatus)
+	api.HandleFunc("POST /api/run", d.handleRun)
+	api.HandleFunc("GET /api/clients", d.handleListClients)
+	api.HandleFunc("POST /api/clients", d.handleAddClient)
+	api.HandleFunc("DELETE /api/clients/{mac}", d.handleDeleteClient)
+	api.HandleFunc("POST /api/clients/{mac}/toggle", d.handleToggle)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServerFS(static))
+	mux.Handle("/api/", d.requireToken(api))
+	mux.Handle("GET /metrics", d.requireToken(http.HandlerFunc(d.handleMetrics)))
+	mux.Handle("GET /status", d.requireToken(http.HandlerFunc(d.handleLiveStatus)))
+
+	if d.o.AdminToken == "" {
+		fmt.Println("⚠️  ADMIN_TOKEN is not set, the admin API is unauthenticated and read-only")
+	}
+	fmt.Println("✅ Admin UI listening on", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Println("❌ Admin listener failed:", err)
+	}
+}
+
+func (d *daemon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.status())
+}
+
+// liveStatus is the status file's content with when the next cycle runs
+// and when each client is checked next.
+type liveStatus struct {
+	updater.Status
+	NextRun time.Time    `json:"next_run"`
+	Clients []liveClient `json:"clients"`
+}
+
+type liveClient struct {
+	updater.ClientStatus
+	NextCheck time.Time `json:"next_check,omitzero"`
+}
+
+func (d *daemon) handleLiveStatus(w http.ResponseWriter, r *http.Request) {
+	st := liveStatus{Status: d.status(), NextRun: d.nextRun().Truncate(time.Second)}
+	st.Clients = make([]liveClient, len(st.Status.Clients))
+	for i, c := range st.Status.Clients {
+		st.Clients[i].ClientStatus = c
+		st.Clients[i].NextCheck, _ = d.engine.NextCheck(c.MAC, c.GroupID)
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (d *daemon) handleRun(w http.ResponseWriter, r *http.Request) {
+	d.requestRun()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "scheduled"})
+}
+
+func (d *daemon) handleToggle(w http.ResponseWriter, r *http.Request) {
+	paused := d.togglePaused(r.PathValue("mac"))
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": paused})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdAgent runs the updater on the client itself: addresses are read from
+// the local interfaces instead of the controller, and a cycle runs as soon
+// as they change.
+func cmdAgent(o *options) int {
+	o.redirectLogs()
+	o.startupDelay()
+	o.requireController()
+	fmt.Println("🚀", versionString())
+	interval := o.interval()
+
+	flushSentry := initSentry(o.SentryDSN, o.SentryEnvironment)
+	defer flushSentry()
+
+	lock, err := lockState(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌", err)
+		return exitFailure
+	}
+	defer lock.Close()
+
+	d := newDaemon(o)
+	if code := checkController(o, d.ctrl); code != exitOK {
+		return code
+	}
+	d.engine.Sources = []updater.Source{updater.InterfaceSource{}}
+	if o.RunOnce {
+		return exitCode(d.runCycle())
+	}
+
+	go d.watchInterfaces(time.Duration(o.AddressPollInterval) * time.Second)
+
+	fmt.Printf("✅ Running agent every %v and on address changes\n", interval)
+	d.run(interval)
+	return exitOK
+}
+
+// watchInterfaces polls the local interfaces' addresses and requests a
+// cycle whenever they change. Reading them is cheap, so this can run far
+// more often than the controller is asked for anything.
+func (d *daemon) watchInterfaces(every time.Duration) {
+	if every <= 0 {
+		every = 10 * time.Second
+	}
+	var last map[string][]string
+	for {
+		addrs, err := updater.InterfaceSource{}.Addresses()
+		if err != nil {
+			fmt.Println("⚠️  Failed to read local addresses:", err)
+		} else {
+			if last != nil && !maps.EqualFunc(addrs, last, slices.Equal) {
+				fmt.Println("⚡ Local addresses changed")
+				d.requestRun()
+			}
+			last = addrs
+		}
+		time.Sleep(every)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+var (
+	errClientExists   = errors.New("client entry already exists")
+	errClientNotFound = errors.New("client entry not found")
+)
+
+// apiStatus maps an error from a config edit to an HTTP status code.
+func apiStatus(err error) int {
+	switch {
+	case errors.Is(err, errClientExists):
+		return http.StatusConflict
+	case errors.Is(err, errClientNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// requireToken rejects requests that don't carry the admin token as a
+// bearer token. Without a configured token only reads are let through, so
+// that nobody who can reach the port can edit clients or run cycles.
+func (d *daemon) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.o.AdminToken == "" && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "set ADMIN_TOKEN to make changes through the admin API"})
+			return
+		}
+		if d.o.AdminToken != "" {
+			got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(d.o.AdminToken)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *daemon) handleListClients(w http.ResponseWriter, r *http.Request) {
+	cfg, err := d.store.Load()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	// the clients' push tokens stay in the config file
+	for i := range cfg.Clients {
+		cfg.Clients[i].Token = ""
+	}
+	writeJSON(w, http.StatusOK, cfg.Clients)
+}
+
+func (d *daemon) handleAddClient(w http.ResponseWriter, r *http.Request) {
+	var c updater.ClientConfig
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := net.ParseMAC(c.MAC); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid mac"})
+		return
+	}
+	if c.GroupID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "group_id is required"})
+		return
+	}
+
+	err := d.editConfig(func(cfg *updater.Config) error {
+		if slices.ContainsFunc(cfg.Clients, func(e updater.ClientConfig) bool {
+			return strings.EqualFold(e.MAC, c.MAC) && e.GroupID == c.GroupID
+		}) {
+			return errClientExists
+		}
+		cfg.Clients = append(cfg.Clients, c)
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, apiStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// handleDeleteClient removes every entry for the MAC in the path, or only
+// the one for the group_id query parameter when given.
+func (d *daemon) handleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	mac, groupID := r.PathValue("mac"), r.URL.Query().Get("group_id")
+	err := d.editConfig(func(cfg *updater.Config) error {
+		n := len(cfg.Clients)
+		cfg.Clients = slices.DeleteFunc(cfg.Clients, func(e updater.ClientConfig) bool {
+			return strings.EqualFold(e.MAC, mac) && (groupID == "" || e.GroupID == groupID)
+		})
+		if len(cfg.Clients) == n {
+			return errClientNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, apiStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
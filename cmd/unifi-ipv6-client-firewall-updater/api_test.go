@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		token, method, auth string
+		want                int
+	}{
+		{method: "GET", want: http.StatusOK},
+		{method: "POST", want: http.StatusForbidden},
+		{method: "DELETE", want: http.StatusForbidden},
+		{token: "secret", method: "GET", want: http.StatusUnauthorized},
+		{token: "secret", method: "POST", auth: "Bearer wrong", want: http.StatusUnauthorized},
+		{token: "secret", method: "POST", auth: "Bearer secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		d := &daemon{o: &options{AdminToken: tt.token}}
+		req := httptest.NewRequest(tt.method, "/api/run", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		d.requireToken(ok).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s with token %q and %q: HTTP %d, want %d", tt.method, tt.token, tt.auth, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestListClientsHidesTokens(t *testing.T) {
+	store := updater.FileStore{Path: filepath.Join(t.TempDir(), "clients.json")}
+	if err := store.Save(&updater.Config{Clients: []updater.ClientConfig{{MAC: "aa:bb:cc:dd:ee:01", GroupID: "g1", Token: "push-secret"}}}); err != nil {
+		t.Fatal(err)
+	}
+	d := &daemon{o: &options{}, store: store}
+	rec := httptest.NewRecorder()
+	d.handleListClients(rec, httptest.NewRequest("GET", "/api/clients", nil))
+
+	var clients []map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&clients); err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 1 || clients[0]["token"] != nil {
+		t.Errorf("clients = %v, want one without its token", clients)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// While the controller is unreachable, cycles stop and it is probed
+// instead, first after probeMin and then twice as long each time, up to
+// probeMax.
+const (
+	probeMin = 10 * time.Second
+	probeMax = 5 * time.Minute
+)
+
+// availability tracks whether the controller is reachable, so an outage,
+// e.g. a firmware update, is logged once as it starts and once as it ends
+// rather than as failures every cycle.
+type availability struct {
+	mu      sync.Mutex
+	since   time.Time // when it became unreachable, zero while it is up
+	backoff time.Duration
+	next    time.Time // when to probe it next
+	probes  int
+}
+
+// checkReachable probes the controller after a cycle failed with err and,
+// if it is the controller that can't be reached, marks it down.
+func (d *daemon) checkReachable(err error) {
+	if err == nil || !unifi.IsUnreachable(err) {
+		return
+	}
+	perr := d.ctrl.Ping()
+	if perr == nil || !unifi.IsUnreachable(perr) {
+		return // another controller or target failed
+	}
+
+	a := &d.avail
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.since.IsZero() {
+		return
+	}
+	now := time.Now()
+	a.since, a.backoff, a.next, a.probes = now, probeMin, now.Add(probeMin), 0
+	fmt.Printf("🔌 Controller %s is unreachable (%v), pausing cycles and probing it every %v, backing off to %v\n",
+		d.ctrl.Host(), perr, probeMin, probeMax)
+}
+
+// probeWait returns how long until the controller is probed next, and
+// whether it is down at all.
+func (d *daemon) probeWait() (time.Duration, bool) {
+	a := &d.avail
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.since.IsZero() {
+		return 0, false
+	}
+	return max(time.Until(a.next), 0), true
+}
+
+// probe checks on a controller that is down, reporting whether it has
+// recovered. Otherwise the next probe is pushed back.
+func (d *daemon) probe() bool {
+	err := d.ctrl.Ping()
+
+	a := &d.avail
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.probes++
+	if err != nil && unifi.IsUnreachable(err) {
+		a.backoff = min(a.backoff*2, probeMax)
+		a.next = time.Now().Add(a.backoff)
+		return false
+	}
+	fmt.Printf("✅ Controller %s recovered after %v (%d probes), resuming cycles\n",
+		d.ctrl.Host(), time.Since(a.since).Round(time.Second), a.probes)
+	a.since = time.Time{}
+	return true
+}
+
+// controllerUp reports whether the controller is reachable, and since
+// when it hasn't been if not.
+func (d *daemon) controllerUp() (bool, time.Time) {
+	a := &d.avail
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.since.IsZero(), a.since
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// managedGroup is a firewall group tracked clients are published to, with
+// the addresses they last published there.
+type managedGroup struct {
+	client     updater.ClientConfig // the first client published to it
+	owned      []string
+	allowEmpty bool // every client published to it has AllowEmpty
+}
+
+// cmdCleanup removes the members of the firewall groups clients are
+// published to that none of those clients last published, e.g. left behind
+// by a client dropped from the config or an address added by hand. With
+// --dry-run it only prints what it would remove.
+func cmdCleanup(o *options) int {
+	o.requireController()
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Failed to load config:", err)
+		return exitConfig
+	}
+
+	// groups by controller, site and ID, in config order
+	groups := map[string]*managedGroup{}
+	var keys []string
+	for _, c := range cfg.Clients {
+		if c.GroupID == "" || c.TargetName() != updater.DefaultTarget {
+			continue
+		}
+		key := c.Controller + "/" + c.Site + "/" + c.GroupID
+		g, ok := groups[key]
+		if !ok {
+			g = &managedGroup{client: c, allowEmpty: true}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.owned = append(g.owned, c.Written()...)
+		g.allowEmpty = g.allowEmpty && c.AllowEmpty
+	}
+
+	code := exitOK
+	fail := func(c int, format string, args ...any) {
+		fmt.Printf("❌ "+format+"\n", args...)
+		if code == exitOK {
+			code = c
+		}
+	}
+
+	type site struct {
+		ctrl   *unifi.Client
+		groups []unifi.FirewallGroup
+		err    error
+	}
+	sites := map[string]*site{}
+	var removed int
+	for _, key := range keys {
+		g := groups[key]
+		c := g.client
+		siteKey := c.Controller + "/" + c.Site
+		s, ok := sites[siteKey]
+		if !ok {
+			s = &site{}
+			sites[siteKey] = s
+			if s.ctrl, s.err = o.clientController(cfg, c); s.err == nil {
+				s.groups, s.err = s.ctrl.FirewallGroups()
+			}
+			if s.err != nil {
+				fail(exitCode(s.err), "Failed to get firewall groups for %s: %v", c.Label(), s.err)
+			}
+		}
+		if s.err != nil {
+			continue
+		}
+		i := slices.IndexFunc(s.groups, func(fg unifi.FirewallGroup) bool { return fg.ID == c.GroupID })
+		if i < 0 {
+			fail(exitConfig, "Firewall group %s of %s does not exist", c.GroupID, c.Label())
+			continue
+		}
+		group := s.groups[i]
+
+		var keep, stale []string
+		for _, m := range group.Members {
+			ip := net.ParseIP(strings.TrimSuffix(m, "/128"))
+			switch {
+			case ip == nil:
+				fmt.Printf("⚠️  Keeping %s in %s (%s), it is not an address\n", m, group.Name, group.ID)
+				keep = append(keep, m)
+			case slices.ContainsFunc(g.owned, func(a string) bool { return ip.Equal(net.ParseIP(a)) }):
+				keep = append(keep, m)
+			default:
+				stale = append(stale, m)
+			}
+		}
+		if len(stale) == 0 {
+			continue
+		}
+		if len(keep) == 0 && !g.allowEmpty {
+			fmt.Printf("⚠️  Not removing %s from %s (%s), which would leave it empty: set allow_empty on its clients to allow it\n",
+				strings.Join(stale, ", "), group.Name, group.ID)
+			continue
+		}
+		if o.DryRun {
+			fmt.Printf("🧹 Would remove %s from %s (%s)\n", strings.Join(stale, ", "), group.Name, group.ID)
+			removed += len(stale)
+			continue
+		}
+		if len(keep) == 0 {
+			err = s.ctrl.ClearFirewallGroup(group)
+		} else {
+			err = s.ctrl.UpdateFirewallGroup(group, keep...)
+		}
+		o.record(history.Entry{Action: history.ActionCleanup, Target: updater.DefaultTarget, Ref: group.ID, Old: group.Members, New: keep}, err)
+		if err != nil {
+			fail(exitCode(err), "Failed to update %s (%s): %v", group.Name, group.ID, err)
+			continue
+		}
+		fmt.Printf("🧹 Removed %s from %s (%s)\n", strings.Join(stale, ", "), group.Name, group.ID)
+		removed += len(stale)
+	}
+
+	switch {
+	case code != exitOK:
+	case removed == 0:
+		fmt.Println("✅ No stale members found")
+	case o.DryRun:
+		fmt.Printf("✅ %d stale members would be removed, run without --dry-run to remove them\n", removed)
+	default:
+		fmt.Printf("✅ Removed %d stale members\n", removed)
+	}
+	return code
+}
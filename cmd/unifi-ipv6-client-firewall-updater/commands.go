@@ -0,0 +1,300 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdValidate checks the configuration file and that the controller is
+// reachable, accepts the API key and has every referenced group. Each
+// problem found is printed and the exit code reflects the worst of them.
+func cmdValidate(o *options) int {
+	code := exitOK
+	fail := func(c int, format string, args ...any) {
+		fmt.Printf("❌ "+format+"\n", args...)
+		if code == exitOK {
+			code = c
+		}
+	}
+
+	data, err := os.ReadFile(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Invalid config:", err)
+		return exitConfig
+	}
+	for _, d := range updater.CheckSchema(data) {
+		if d.Path == "" {
+			fail(exitConfig, "%s:%d: %s", o.ConfigPath, d.Line, d.Message)
+		} else {
+			fail(exitConfig, "%s: %s", d.Path, d.Message)
+		}
+	}
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	if err != nil {
+		if code == exitOK {
+			fmt.Println("❌ Invalid config:", err)
+		}
+		return exitConfig
+	}
+
+	// warnings are left to the lint command
+	for _, d := range updater.Lint(cfg) {
+		if d.Severity == updater.SeverityError {
+			fail(exitConfig, "%s: %s", d.Path, d.Message)
+		}
+	}
+
+	if o.Host == "" || !o.hasCredentials() {
+		fail(exitConfig, "UNIFI_HOST or UNIFI_CONSOLE_ID, and UNIFI_API_KEY or a login, are required to check the controller")
+		return code
+	}
+
+	ctrl := o.controller()
+	if _, err := ctrl.Stations(); err != nil {
+		msg, c := explainControllerError(o.Host, err)
+		if c == exitOK {
+			c = exitFailure
+		}
+		fail(c, "%s", msg)
+		return code
+	}
+	groups, err := ctrl.FirewallGroups()
+	if err != nil {
+		fail(exitCode(err), "cannot read firewall groups from %s: %v", o.Host, err)
+		return code
+	}
+	if err := ctrl.CanWrite(); err != nil {
+		msg, c := explainControllerError(o.Host, err)
+		if c == exitOK {
+			c = exitFailure
+		}
+		fail(c, "%s", msg)
+		return code
+	}
+	fmt.Printf("✅ Controller %s reachable, API key can read clients and change firewall groups\n", o.Host)
+
+	// groups of the other sites clients are on, by controller and site
+	siteGroups := map[string][]unifi.FirewallGroup{"/": groups}
+	for i, c := range cfg.Clients {
+		if c.GroupID == "" || c.TargetName() != updater.DefaultTarget {
+			continue
+		}
+		key := c.Controller + "/" + c.Site
+		groups, ok := siteGroups[key]
+		if !ok {
+			siteGroups[key] = nil
+			if c.Controller != "" && !slices.ContainsFunc(cfg.Controllers, func(cc updater.ControllerConfig) bool { return cc.Name == c.Controller }) {
+				continue // reported above
+			}
+			ctrl, err := o.clientController(cfg, c)
+			if err == nil {
+				groups, err = ctrl.FirewallGroups()
+			}
+			if err != nil {
+				fail(exitCode(err), "clients[%d] (%s): cannot read firewall groups of site %q of %s: %v", i, c.MAC, cmp.Or(c.Site, "default"), cmp.Or(c.Controller, o.Host), err)
+				continue
+			}
+			siteGroups[key] = groups
+		}
+		if groups == nil {
+			continue
+		}
+		if !slices.ContainsFunc(groups, func(g unifi.FirewallGroup) bool { return g.ID == c.GroupID }) {
+			fail(exitConfig, "clients[%d] (%s): firewall group %s does not exist", i, c.MAC, c.GroupID)
+		}
+	}
+
+	if code == exitOK {
+		fmt.Printf("✅ %s is valid (%d clients, %d notifiers)\n", o.ConfigPath, len(cfg.Clients), len(cfg.Notifiers))
+	}
+	return code
+}
+
+// cmdList prints the tracked clients and their cached addresses.
+func cmdList(o *options) int {
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Failed to load config:", err)
+		return exitConfig
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tNAME\tGROUP\tLAST IPV6\tENABLED")
+	for _, c := range cfg.Clients {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", c.MAC, c.Name, c.GroupID, cmp.Or(strings.Join(c.Addresses, ", "), c.LastIPv6), c.IsEnabled())
+	}
+	w.Flush()
+	return exitOK
+}
+
+// cmdListClients prints every client the controller currently sees, to help
+// find the MACs to track.
+func cmdListClients(o *options) int {
+	o.requireController()
+	clients, err := o.controller().Stations()
+	if err != nil {
+		fmt.Println("❌ Failed to get UniFi clients:", err)
+		return exitCode(err)
+	}
+	slices.SortFunc(clients, func(a, b unifi.Station) int { return strings.Compare(a.MAC, b.MAC) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tNAME\tHOSTNAME\tNETWORK\tIP\tIPV6")
+	for _, c := range clients {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.MAC, c.Name, c.Hostname, c.Network, c.IP,
+			strings.Join(c.IPv6Addresses, ", "))
+	}
+	w.Flush()
+	return exitOK
+}
+
+// cmdListGroups prints every firewall group, to help find the group IDs to
+// configure.
+func cmdListGroups(o *options) int {
+	o.requireController()
+	groups, err := o.controller().FirewallGroups()
+	if err != nil {
+		fmt.Println("❌ Failed to get firewall groups:", err)
+		return exitCode(err)
+	}
+	slices.SortFunc(groups, func(a, b unifi.FirewallGroup) int { return strings.Compare(a.Name, b.Name) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tMEMBERS")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", g.ID, g.Name, g.Type, strings.Join(g.Members, ", "))
+	}
+	w.Flush()
+	return exitOK
+}
+
+// cmdListConsoles prints the consoles the Site Manager API key has access
+// to, to find the ID to reach one by.
+func cmdListConsoles(o *options) int {
+	if o.APIKey == "" {
+		fmt.Println("❌ UNIFI_API_KEY (--api-key) is required, a Site Manager API key")
+		return exitConfig
+	}
+	consoles, err := unifi.Consoles(o.APIKey, userAgent())
+	if err != nil {
+		fmt.Println("❌ Failed to list consoles:", err)
+		return exitCode(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tIP")
+	for _, c := range consoles {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.ID, c.Name, c.IP)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// cmdImport writes a starter config to stdout, mapping the MAC of every
+// client whose address is a member of an IPv6 firewall group to that group.
+// Members that match no client are reported on stderr.
+func cmdImport(o *options) int {
+	o.requireController()
+	ctrl := o.controller()
+	clients, err := ctrl.Stations()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to get UniFi clients:", err)
+		return exitCode(err)
+	}
+	groups, err := ctrl.FirewallGroups()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to get firewall groups:", err)
+		return exitCode(err)
+	}
+
+	cfg := updater.Config{Clients: []updater.ClientConfig{}}
+	for _, g := range groups {
+		if g.Type != unifi.GroupTypeIPv6 {
+			continue
+		}
+		for _, member := range g.Members {
+			addr := net.ParseIP(strings.SplitN(member, "/", 2)[0])
+			i := slices.IndexFunc(clients, func(c unifi.Station) bool {
+				return slices.ContainsFunc(c.IPv6Addresses, func(a string) bool { return addr.Equal(net.ParseIP(a)) })
+			})
+			if i < 0 {
+				fmt.Fprintf(os.Stderr, "⚠️  No client has %s from group %s (%s)\n", member, g.Name, g.ID)
+				continue
+			}
+			name := clients[i].Name
+			if name == "" {
+				name = clients[i].Hostname
+			}
+			cfg.Clients = append(cfg.Clients, updater.ClientConfig{MAC: clients[i].MAC, Name: name, GroupID: g.ID, LastIPv6: addr.String()})
+			fmt.Fprintf(os.Stderr, "✅ %s (%s) → %s (%s)\n", clients[i].MAC, clients[i].Hostname, g.Name, g.ID)
+		}
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to encode config:", err)
+		return exitFailure
+	}
+	fmt.Println(string(data))
+	return exitOK
+}
+
+// cmdStatus prints the outcome of the last cycle from the status file: when
+// it ran, each client's current address and result, and recent errors.
+func cmdStatus(o *options) int {
+	if o.StatusFile == "" {
+		fmt.Println("❌ STATUS_FILE (--status-file) is required")
+		return exitConfig
+	}
+	st, err := updater.LoadStatus(o.StatusFile)
+	if err != nil {
+		fmt.Println("❌ Failed to read status file:", err)
+		return exitFailure
+	}
+
+	result := "✅ succeeded"
+	if !st.Success {
+		result = "❌ failed"
+	}
+	fmt.Printf("Last run %s (%s ago, took %dms) %s: %s\n", st.Timestamp.Local().Format(time.DateTime),
+		time.Since(st.Timestamp).Round(time.Second), st.DurationMS, result, st.Summary)
+	if st.Endpoint != "" {
+		fmt.Println("Controller:", st.Endpoint)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MAC\tNAME\tGROUP\tIPV6\tRESULT\tERROR")
+	for _, c := range st.Clients {
+		addrs := c.Addresses
+		if len(addrs) == 0 && c.IPv6 != "" {
+			addrs = []string{c.IPv6}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.MAC, c.Name, c.GroupID, updater.Annotate(addrs, c.Hostnames), c.Result, c.Error)
+	}
+	w.Flush()
+
+	if len(st.RecentErrors) > 0 {
+		fmt.Println("\nRecent errors:")
+		for _, e := range st.RecentErrors {
+			fmt.Printf("  %s  %s\n", e.Time.Local().Format(time.DateTime), e.Error)
+		}
+	}
+	return exitOK
+}
+
+// cmdVersion prints the version.
+func cmdVersion(o *options) int {
+	fmt.Println(versionString())
+	return exitOK
+}
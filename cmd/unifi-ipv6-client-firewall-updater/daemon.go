@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/kube"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/leader"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/neighbor"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/operator"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// daemon runs update cycles on a schedule or on demand and keeps the outcome
+// of the latest one for the admin UI.
+type daemon struct {
+	o          *options
+	ctrl       *unifi.Client
+	store      updater.Store
+	engine     *updater.Updater
+	pushed     *updater.PushSource
+	resources  *operator.Store
+	heartbeats []heartbeat
+	sinks      map[string]metricsSink
+	trigger    chan struct{}
+
+	// elector, if set, decides whether this replica is the one running
+	// cycles.
+	elector leader.Elector
+	leading atomic.Bool
+
+	// cfgMu serialises cycles and API edits of the config file, since both
+	// rewrite it.
+	cfgMu sync.Mutex
+
+	mu     sync.RWMutex
+	last   updater.Status
+	paused map[string]bool
+
+	// sites are the controllers of clients on other sites, kept so they
+	// reuse their connections across cycles.
+	sitesMu sync.Mutex
+	sites   map[string]*unifi.Client
+
+	// avail pauses cycles while the controller is unreachable.
+	avail availability
+
+	// runAt, if set, runs cycles at fixed times of day instead of as
+	// clients come due.
+	runAt *runAt
+}
+
+func newDaemon(o *options) *daemon {
+	if !updater.ValidPreference(o.AddressPreference) {
+		fmt.Printf("❌ Invalid address preference %q, use first, stable, temporary or all\n", o.AddressPreference)
+		exit(exitConfig)
+	}
+	if !updater.ValidVerify(o.VerifyReachable) {
+		fmt.Printf("❌ Invalid reachability check %q, use ping, tcp:<port> or off\n", o.VerifyReachable)
+		exit(exitConfig)
+	}
+	if !updater.ValidDriftPolicy(o.DriftPolicy) {
+		fmt.Printf("❌ Invalid drift policy %q, use alert, repair or respect\n", o.DriftPolicy)
+		exit(exitConfig)
+	}
+	if o.InfluxURL != "" && (o.InfluxOrg == "" || o.InfluxBucket == "") {
+		fmt.Println("❌ INFLUX_ORG and INFLUX_BUCKET (--influx-org and --influx-bucket) are required with INFLUX_URL")
+		exit(exitConfig)
+	}
+	var runAt *runAt
+	if o.RunAt != "" {
+		var err error
+		if runAt, err = parseRunAt(o.RunAt, o.Timezone); err != nil {
+			fmt.Println("❌ Invalid RUN_AT:", err)
+			exit(exitConfig)
+		}
+	}
+	var store updater.Store = updater.FileStore{Path: o.ConfigPath}
+	var resources *operator.Store
+	if o.Operator {
+		client, err := kube.InCluster()
+		if err != nil {
+			fmt.Println("❌ Kubernetes:", err)
+			exit(exitConfig)
+		}
+		resources = operator.NewStore(client, o.WatchNamespace, o.ConfigPath)
+		store = resources
+	}
+	d := &daemon{
+		o:          o,
+		ctrl:       o.controller(),
+		store:      store,
+		resources:  resources,
+		heartbeats: o.heartbeats(),
+		sinks:      o.metricsSinks(),
+		trigger:    make(chan struct{}, 1),
+		paused:     map[string]bool{},
+		sites:      map[string]*unifi.Client{},
+		runAt:      runAt,
+	}
+	d.engine = &updater.Updater{
+		Controller:     d.ctrl,
+		Store:          store,
+		Concurrency:    o.Concurrency,
+		IncludeOffline: o.IncludeOffline,
+		Paused:         d.isPaused,
+		ReportError:    reportError,
+		Panicked:       reportPanic,
+		Selection:      updater.Selection{Prefer: o.AddressPreference, AllowULA: o.AllowULA, MaxAddresses: o.MaxAddresses},
+		Connect:        d.connect,
+		History:        o.history(),
+		DriftPolicy:    o.DriftPolicy,
+		Verify:         o.VerifyReachable,
+		Overlap:        time.Duration(o.AddressOverlap) * time.Second,
+
+		MaxGroupMembers: o.MaxGroupMembers,
+
+		ResolveHostnames: o.ResolveHostnames,
+	}
+	sources := d.engine.DefaultSources()
+	if o.ListenAddr != "" {
+		// pushed addresses take precedence over the controller's
+		d.pushed = &updater.PushSource{Base: sources[0]}
+		sources[0] = d.pushed
+	}
+	if o.LocalNeighbors {
+		sources = append(sources, neighbor.Local{Interface: o.NeighborInterface})
+	}
+	if o.SSHHost != "" {
+		gw, err := neighbor.NewSSH(neighbor.SSHConfig{
+			Addr:       o.SSHHost,
+			User:       o.SSHUser,
+			Password:   o.SSHPassword,
+			KeyFile:    o.SSHKeyFile,
+			KnownHosts: o.SSHKnownHosts,
+		})
+		if err != nil {
+			fmt.Println("❌ Invalid SSH settings:", err)
+			exit(exitConfig)
+		}
+		if o.SSHKnownHosts == "" {
+			fmt.Println("⚠️  SSH_KNOWN_HOSTS is not set, the gateway's host key is not verified")
+		}
+		sources = append(sources, gw)
+	}
+	d.engine.Sources = sources
+	if o.StatusFile != "" {
+		if st, err := updater.LoadStatus(o.StatusFile); err == nil {
+			d.last = *st
+		}
+	}
+	return d
+}
+
+// connect returns the controller for site of the config's controller cc,
+// or of UNIFI_HOST when cc is nil, reusing it in later cycles.
+func (d *daemon) connect(cc *updater.ControllerConfig, site string) (updater.Controller, error) {
+	key := "/" + site
+	if cc != nil {
+		key = fmt.Sprintf("%s %s %t/%s", cc.URL(), cc.APIKey, cc.Insecure, site)
+	}
+
+	d.sitesMu.Lock()
+	defer d.sitesMu.Unlock()
+	if c, ok := d.sites[key]; ok {
+		return c, nil
+	}
+	c, err := d.o.siteController(cc, site)
+	if err != nil {
+		return nil, err
+	}
+	d.sites[key] = c
+	return c, nil
+}
+
+// runCycle runs a cycle checking every client and records its outcome.
+func (d *daemon) runCycle() error {
+	return d.cycle(d.engine.Run)
+}
+
+// cycle runs a cycle with run, one of the engine's Run methods, and records
+// its outcome.
+func (d *daemon) cycle(run func() (updater.Status, error)) error {
+	defer recoverPanic()
+
+	if !d.isLeader() {
+		fmt.Println("💤 Not the leader, skipping cycle")
+		return nil
+	}
+
+	for _, hb := range d.heartbeats {
+		hb.start()
+	}
+	started := time.Now()
+	d.cfgMu.Lock()
+	st, err := run()
+	d.cfgMu.Unlock()
+	fmt.Println("📊 Summary:", st.Summary)
+	st.Finish(started, err)
+
+	d.mu.Lock()
+	st.CarryOver(&d.last)
+	d.last = st
+	d.mu.Unlock()
+
+	if d.o.StatusFile != "" {
+		if err := updater.SaveStatus(d.o.StatusFile, &st); err != nil {
+			fmt.Println("⚠️  Failed to write status file:", err)
+		}
+	}
+	if d.resources != nil {
+		if err := d.resources.Report(st); err != nil {
+			fmt.Println("⚠️  Failed to update resource status:", err)
+		}
+	}
+	for name, sink := range d.sinks {
+		if err := sink.report(st); err != nil {
+			fmt.Printf("⚠️  Failed to write metrics to %s: %v\n", name, err)
+		}
+	}
+	for _, hb := range d.heartbeats {
+		hb.finish(err)
+	}
+	return err
+}
+
+// editConfig loads the config, applies fn and saves the result, without
+// interleaving with a running cycle.
+func (d *daemon) editConfig(fn func(*updater.Config) error) error {
+	d.cfgMu.Lock()
+	defer d.cfgMu.Unlock()
+
+	cfg, err := d.store.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return d.store.Save(cfg)
+}
+
+// run runs a cycle immediately, unless RUN_ON_START is off, and then
+// whenever a client is due, checking every client every interval unless it
+// has its own, or on a requested run, which checks them all. With RUN_AT,
+// every client is checked at its times instead. While the controller is
+// unreachable, it is probed instead, and every client is checked once it
+// answers again.
+func (d *daemon) run(interval time.Duration) {
+	d.engine.Interval = interval
+	if d.runAt != nil {
+		fmt.Println("⏰ Running at", d.runAt)
+	}
+	if d.o.RunOnStart {
+		d.checkReachable(d.runCycle())
+	} else {
+		next := d.nextRun()
+		fmt.Println("⏭️  Not running at startup, the first cycle runs at", next.Format(time.DateTime))
+		// systemd would otherwise wait for the first cycle to count the
+		// service as started; the Windows service already reports running
+		for _, hb := range d.heartbeats {
+			if sd, ok := hb.(*systemd); ok {
+				sd.skip(next)
+			}
+		}
+	}
+
+	for {
+		wait := time.Until(d.nextRun())
+		if !d.isLeader() && d.runAt == nil {
+			// the schedule only moves on in cycles the leader runs
+			wait = interval
+		}
+		probeIn, down := d.probeWait()
+		if down {
+			wait = probeIn
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			if !down && d.runAt == nil {
+				d.checkReachable(d.cycle(d.engine.RunDue))
+			} else if !down || d.probe() {
+				d.checkReachable(d.runCycle())
+			}
+		case <-d.trigger:
+			timer.Stop()
+			if !down || d.probe() {
+				d.checkReachable(d.runCycle())
+			}
+		}
+	}
+}
+
+// nextRun returns when the next scheduled cycle runs.
+func (d *daemon) nextRun() time.Time {
+	if d.runAt != nil {
+		return d.runAt.next(time.Now())
+	}
+	return time.Now().Add(d.engine.NextDue())
+}
+
+// requestRun asks for a cycle to run as soon as the current one, if any, is
+// done. Requests made while one is already pending are merged.
+func (d *daemon) requestRun() {
+	select {
+	case d.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (d *daemon) isPaused(mac string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.paused[strings.ToLower(mac)]
+}
+
+// togglePaused pauses or resumes updates for a client until the process
+// restarts, returning whether it is now paused.
+func (d *daemon) togglePaused(mac string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	mac = strings.ToLower(mac)
+	d.paused[mac] = !d.paused[mac]
+	return d.paused[mac]
+}
+
+// status returns a copy of the latest cycle's outcome.
+func (d *daemon) status() updater.Status {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	st := d.last
+	st.Clients = make([]updater.ClientStatus, len(d.last.Clients))
+	for i, c := range d.last.Clients {
+		c.Paused = d.paused[strings.ToLower(c.MAC)]
+		st.Clients[i] = c
+	}
+	return st
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// discoverTimeout is how long devices are given to answer a discovery
+// request.
+const discoverTimeout = 3 * time.Second
+
+// cmdDiscover lists the UniFi devices on the local network, marking the
+// consoles the updater can use as its controller.
+func cmdDiscover(o *options) int {
+	devices, err := unifi.Discover(discoverTimeout)
+	if err != nil {
+		fmt.Println("❌ Discovery failed:", err)
+		return exitFailure
+	}
+	if len(devices) == 0 {
+		fmt.Println("No UniFi devices answered on the local network")
+		return exitFailure
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tMAC\tMODEL\tHOSTNAME\tFIRMWARE\tCONTROLLER")
+	for _, d := range devices {
+		url := ""
+		if d.Console() {
+			url = d.URL()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.IP, d.MAC, d.Model, d.Hostname, d.Firmware, url)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// discoverHost sets Host to the console found on the local network, when
+// there is exactly one. Otherwise Host is left empty, with the reason
+// printed.
+func (o *options) discoverHost() {
+	fmt.Println("🔎 UNIFI_HOST is not set, looking for the controller on the local network")
+	devices, err := unifi.Discover(discoverTimeout)
+	if err != nil {
+		fmt.Println("⚠️  Discovery failed:", err)
+		return
+	}
+	devices = slices.DeleteFunc(devices, func(d unifi.Device) bool { return !d.Console() })
+	switch len(devices) {
+	case 0:
+		fmt.Println("⚠️  No UniFi console answered on the local network")
+	case 1:
+		d := devices[0]
+		fmt.Printf("✅ Found %s (%s) at %s; set UNIFI_HOST to skip discovery\n", d.Model, d.Hostname, d.URL())
+		if o.VerifySSL {
+			fmt.Println("ℹ️  Consoles usually have a self-signed certificate, which needs VERIFY_SSL=false")
+		}
+		o.Host = d.URL()
+	default:
+		fmt.Println("⚠️  Several UniFi consoles answered, set UNIFI_HOST to one of them:")
+		for _, d := range devices {
+			fmt.Printf("   %s %s (%s)\n", d.URL(), d.Model, d.Hostname)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// stateDump is the daemon's in-memory state, for debugging an instance
+// that seems stuck without restarting it.
+type stateDump struct {
+	Time    time.Time `json:"time"`
+	Leading *bool     `json:"leading,omitempty"`
+	// Controller is whether the controller is reachable and, while it
+	// isn't, how probing it is going.
+	Controller   controllerState `json:"controller"`
+	RunRequested bool            `json:"run_requested"`
+	NextRun      time.Time       `json:"next_run"`
+	Paused       []string        `json:"paused,omitempty"`
+	// Clients are the tracked clients as the config has them, with the
+	// addresses last published and those still retiring.
+	Clients []dumpedClient `json:"clients"`
+	// Pushed are the addresses clients pushed to LISTEN_ADDR, by MAC.
+	Pushed     map[string][]string `json:"pushed,omitempty"`
+	LastStatus updater.Status      `json:"last_status"`
+	ConfigErr  string              `json:"config_error,omitempty"`
+}
+
+type controllerState struct {
+	Host      string    `json:"host"`
+	Up        bool      `json:"up"`
+	Since     time.Time `json:"unreachable_since,omitzero"`
+	Probes    int       `json:"probes,omitempty"`
+	NextProbe time.Time `json:"next_probe,omitzero"`
+}
+
+type dumpedClient struct {
+	updater.ClientConfig
+	NextCheck time.Time `json:"next_check,omitzero"`
+}
+
+// state returns the daemon's in-memory state.
+func (d *daemon) state() stateDump {
+	now := time.Now()
+	st := stateDump{Time: now, RunRequested: len(d.trigger) > 0, NextRun: d.nextRun(), LastStatus: d.status()}
+	if d.elector != nil {
+		leading := d.leading.Load()
+		st.Leading = &leading
+	}
+
+	a := &d.avail
+	a.mu.Lock()
+	st.Controller = controllerState{Host: d.ctrl.Host(), Up: a.since.IsZero(), Since: a.since}
+	if !a.since.IsZero() {
+		st.Controller.Probes, st.Controller.NextProbe = a.probes, a.next
+	}
+	a.mu.Unlock()
+
+	d.mu.RLock()
+	for mac, paused := range d.paused {
+		if paused {
+			st.Paused = append(st.Paused, mac)
+		}
+	}
+	d.mu.RUnlock()
+	slices.Sort(st.Paused)
+
+	if d.pushed != nil {
+		st.Pushed = d.pushed.Pushed()
+	}
+	cfg, err := d.store.Load()
+	if err != nil {
+		st.ConfigErr = err.Error()
+		return st
+	}
+	for _, c := range cfg.Clients {
+		next, _ := d.engine.NextCheck(c.MAC, c.GroupID)
+		st.Clients = append(st.Clients, dumpedClient{ClientConfig: c, NextCheck: next})
+	}
+	return st
+}
+
+// dumpState writes the daemon's state as JSON to STATE_DUMP_FILE, or logs
+// it when that isn't set.
+func (d *daemon) dumpState() {
+	data, err := json.MarshalIndent(d.state(), "", "  ")
+	if err != nil {
+		fmt.Println("⚠️  Failed to dump state:", err)
+		return
+	}
+	if d.o.StateDumpFile == "" {
+		fmt.Printf("🩺 State:\n%s\n", data)
+		return
+	}
+	if err := os.WriteFile(d.o.StateDumpFile, append(data, '\n'), 0o600); err != nil {
+		fmt.Println("⚠️  Failed to dump state:", err)
+		return
+	}
+	fmt.Println("🩺 State dumped to", d.o.StateDumpFile)
+}
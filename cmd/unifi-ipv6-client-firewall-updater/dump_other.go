@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// dumpOnSignal is a no-op where there is no SIGUSR2.
+func (d *daemon) dumpOnSignal() {}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// dumpOnSignal dumps the daemon's state whenever it receives SIGUSR2.
+func (d *daemon) dumpOnSignal() {
+	dump := make(chan os.Signal, 1)
+	signal.Notify(dump, syscall.SIGUSR2)
+	go func() {
+		for range dump {
+			d.dumpState()
+		}
+	}()
+}
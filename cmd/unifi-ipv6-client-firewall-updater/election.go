@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/kube"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/leader"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+)
+
+// leaderTTL is how long leadership lasts without renewal, and so how long
+// a crashed leader's replicas wait before taking over.
+const leaderTTL = 15 * time.Second
+
+// elector returns the configured leader election, or nil if the updater
+// runs alone.
+func (o *options) elector() (leader.Elector, error) {
+	id := o.LeaderID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	switch o.LeaderElection {
+	case "":
+		return nil, nil
+	case "kubernetes":
+		client, err := kube.InCluster()
+		if err != nil {
+			return nil, err
+		}
+		return leader.NewLease(client, o.LeaderNamespace, o.LeaderName, id), nil
+	case "redis":
+		if o.RedisAddr == "" {
+			return nil, fmt.Errorf("REDIS_ADDR is required for redis leader election")
+		}
+		return leader.NewRedis(o.RedisAddr, o.RedisPassword, o.LeaderName, id), nil
+	}
+	return nil, fmt.Errorf("unknown leader election %q, use kubernetes or redis", o.LeaderElection)
+}
+
+// elect takes part in the election until the process is stopped, renewing
+// leadership well within its TTL. The first attempt is made before it
+// returns, so the first cycle knows whether to run. When the process is
+// stopped, leadership is released by stop.
+func (d *daemon) elect(e leader.Elector) {
+	d.elector = e
+	d.renewLeadership(false)
+
+	go func() {
+		ticker := time.NewTicker(leaderTTL / 3)
+		defer ticker.Stop()
+		for range ticker.C {
+			d.renewLeadership(true)
+		}
+	}()
+}
+
+// stopOnSignal exits on SIGINT or SIGTERM, first releasing leadership for
+// another replica to take over at once and sending the notification
+// digests collected so far, which would otherwise be lost.
+func (d *daemon) stopOnSignal() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stop
+		if d.elector != nil && d.leading.Load() {
+			if err := d.elector.Release(); err != nil {
+				fmt.Println("⚠️  Failed to release leadership:", err)
+			}
+		}
+		notify.Flush()
+		exit(exitOK)
+	}()
+}
+
+// renewLeadership takes part in one round of the election. A replica that
+// becomes the leader runs a cycle straight away if runNow is set.
+func (d *daemon) renewLeadership(runNow bool) {
+	leading, err := d.elector.Acquire(leaderTTL)
+	if err != nil {
+		// without a confirmed renewal another replica may take over once
+		// the TTL runs out, so stop making changes now
+		fmt.Println("⚠️  Leader election failed:", err)
+		leading = false
+	}
+	if d.leading.Swap(leading) != leading {
+		if leading {
+			fmt.Println("👑 Elected leader")
+			if runNow {
+				d.requestRun()
+			}
+		} else {
+			fmt.Println("💤 No longer the leader, standing by")
+		}
+	}
+}
+
+// isLeader reports whether this replica may make changes.
+func (d *daemon) isLeader() bool {
+	return d.elector == nil || d.leading.Load()
+}
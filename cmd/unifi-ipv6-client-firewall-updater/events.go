@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// eventDebounce delays the cycle triggered by a controller event, so bursts
+// of events (e.g. a client reconnecting to several APs) cause a single run.
+const eventDebounce = 5 * time.Second
+
+// watchEvents listens to the controller's event WebSocket and requests a
+// cycle whenever a tracked client connects, roams, or is reported with
+// addresses that don't include the one last published. The scheduled
+// cycles keep running as a safety net. It reconnects with backoff forever.
+func (d *daemon) watchEvents() {
+	var armed atomic.Bool
+	trigger := func(reason string) {
+		if armed.Swap(true) {
+			return
+		}
+		fmt.Println("⚡ Controller event:", reason)
+		time.AfterFunc(eventDebounce, func() {
+			armed.Store(false)
+			d.requestRun()
+		})
+	}
+
+	backoff := 5 * time.Second
+	for {
+		events, err := d.ctrl.Events()
+		if err != nil {
+			fmt.Printf("⚠️  Failed to connect to controller events (retrying in %v): %v\n", backoff, err)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, 5*time.Minute)
+			continue
+		}
+		fmt.Println("✅ Listening for controller events")
+		backoff = 5 * time.Second
+
+		for {
+			ev, err := events.Next(2 * time.Minute)
+			if err != nil {
+				fmt.Println("⚠️  Controller events connection lost:", err)
+				break
+			}
+			if reason := d.eventReason(ev); reason != "" {
+				trigger(reason)
+			}
+		}
+		events.Close()
+		time.Sleep(backoff)
+	}
+}
+
+// eventReason returns why ev warrants a cycle, or "" if it doesn't.
+func (d *daemon) eventReason(ev unifi.Event) string {
+	st := d.status()
+	for _, item := range ev.Data {
+		mac := item.MAC
+		if mac == "" {
+			mac = item.User
+		}
+		i := slices.IndexFunc(st.Clients, func(c updater.ClientStatus) bool { return strings.EqualFold(c.MAC, mac) })
+		if i < 0 {
+			continue
+		}
+		c := st.Clients[i]
+
+		switch ev.Meta.Message {
+		case "events":
+			if strings.HasSuffix(item.Key, "_Connected") || strings.Contains(item.Key, "_Roam") {
+				return fmt.Sprintf("%s for %s", item.Key, c.Label())
+			}
+		case "sta:sync":
+			if len(item.IPv6Addresses) > 0 && !slices.Contains(item.IPv6Addresses, c.IPv6) {
+				return fmt.Sprintf("new addresses reported for %s", c.Label())
+			}
+		}
+	}
+	return ""
+}
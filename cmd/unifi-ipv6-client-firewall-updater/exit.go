@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// Process exit codes used in one-shot mode so cron/systemd can tell apart
+// the different ways a run can fail.
+const (
+	exitOK      = 0
+	exitFailure = 1 // controller unreachable or any other unexpected error
+	exitConfig  = 2 // missing settings or unreadable/invalid config file
+	exitAuth    = 3 // controller rejected the API key
+	exitPartial = 4 // some clients failed to update
+)
+
+// exitCode maps the error returned by a cycle to a process exit code.
+// Authentication failures take precedence wherever they occurred.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if unifi.IsAuthError(err) {
+		return exitAuth
+	}
+	var ce *updater.ConfigError
+	if errors.As(err, &ce) {
+		return exitConfig
+	}
+	var pe *updater.PartialError
+	if errors.As(err, &pe) {
+		return exitPartial
+	}
+	return exitFailure
+}
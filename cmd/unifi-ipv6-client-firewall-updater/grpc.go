@@ -0,0 +1,153 @@
+package main
+
+//go:generate sh -c "cd ../.. && buf generate"
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	updaterv1 "github.com/brendann993/unifi-ipv6-client-firewall-updater/proto/updater/v1"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+)
+
+// grpcServer exposes the daemon's control surface over gRPC.
+type grpcServer struct {
+	updaterv1.UnimplementedUpdaterServiceServer
+	d *daemon
+}
+
+// serveGRPC serves the gRPC control API on addr. Calls must carry the admin
+// token as "authorization: Bearer <token>" metadata when one is configured.
+func (d *daemon) serveGRPC(addr string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println("❌ gRPC listener failed:", err)
+		return
+	}
+
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := d.checkGRPCToken(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := d.checkGRPCToken(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	updaterv1.RegisterUpdaterServiceServer(srv, &grpcServer{d: d})
+
+	fmt.Println("✅ gRPC API listening on", addr)
+	if err := srv.Serve(lis); err != nil {
+		fmt.Println("❌ gRPC listener failed:", err)
+	}
+}
+
+func (d *daemon) checkGRPCToken(ctx context.Context) error {
+	if d.o.AdminToken == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		got, _ := strings.CutPrefix(v, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(d.o.AdminToken)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+func (s *grpcServer) GetStatus(ctx context.Context, _ *updaterv1.GetStatusRequest) (*updaterv1.GetStatusResponse, error) {
+	st := s.d.status()
+	resp := &updaterv1.GetStatusResponse{
+		DurationMs: st.DurationMS,
+		Success:    st.Success,
+		Summary: &updaterv1.Summary{
+			Checked: int32(st.Summary.Checked),
+			Found:   int32(st.Summary.Found),
+			Missing: int32(st.Summary.Missing),
+			NoIpv6:  int32(st.Summary.NoIPv6),
+			Changed: int32(st.Summary.Changed),
+			Updated: int32(st.Summary.Updated),
+			Paused:  int32(st.Summary.Paused),
+			Errors:  int32(st.Summary.Errors),
+		},
+		Errors: st.Errors,
+	}
+	if !st.Timestamp.IsZero() {
+		resp.Timestamp = timestamppb.New(st.Timestamp)
+	}
+	for _, c := range st.Clients {
+		cs := &updaterv1.ClientStatus{
+			Mac:          c.MAC,
+			GroupId:      c.GroupID,
+			Ipv6:         c.IPv6,
+			PreviousIpv6: c.PreviousIPv6,
+			Result:       c.Result,
+			Error:        c.Error,
+			Paused:       c.Paused,
+		}
+		if !c.LastChanged.IsZero() {
+			cs.LastChanged = timestamppb.New(c.LastChanged)
+		}
+		resp.Clients = append(resp.Clients, cs)
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) ListClients(ctx context.Context, _ *updaterv1.ListClientsRequest) (*updaterv1.ListClientsResponse, error) {
+	cfg, err := s.d.store.Load()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &updaterv1.ListClientsResponse{}
+	for _, c := range cfg.Clients {
+		resp.Clients = append(resp.Clients, &updaterv1.ClientEntry{Mac: c.MAC, GroupId: c.GroupID, LastIpv6: c.LastIPv6})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) Reconcile(ctx context.Context, _ *updaterv1.ReconcileRequest) (*updaterv1.ReconcileResponse, error) {
+	s.d.requestRun()
+	return &updaterv1.ReconcileResponse{}, nil
+}
+
+func (s *grpcServer) StreamEvents(_ *updaterv1.StreamEventsRequest, stream grpc.ServerStreamingServer[updaterv1.Event]) error {
+	events, cancel := notify.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-events:
+			err := stream.Send(&updaterv1.Event{
+				Kind:     ev.Kind,
+				Severity: ev.Severity,
+				Message:  ev.Message,
+				Mac:      ev.MAC,
+				GroupId:  ev.GroupID,
+				OldIpv6:  ev.OldIPv6,
+				NewIpv6:  ev.NewIPv6,
+				Time:     timestamppb.New(ev.Time),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
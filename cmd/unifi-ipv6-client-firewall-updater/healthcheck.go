@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdHealthcheck checks that the last cycle completed successfully within
+// the allowed age, reading it from the status file or, without one, from
+// the admin API. It is meant for a Dockerfile HEALTHCHECK, which reserves
+// exit codes other than 0 and 1, so every failure exits with 1.
+func cmdHealthcheck(o *options) int {
+	var st *updater.Status
+	var err error
+	switch {
+	case o.StatusFile != "":
+		st, err = updater.LoadStatus(o.StatusFile)
+	case o.AdminAddr != "":
+		st, err = fetchStatus(o.AdminAddr, o.AdminToken)
+	default:
+		err = errors.New("STATUS_FILE or ADMIN_ADDR (--status-file or --admin-addr) is required")
+	}
+	if err != nil {
+		fmt.Println("❌ Unhealthy:", err)
+		return exitFailure
+	}
+
+	maxAge := time.Duration(o.HealthcheckMaxAge) * time.Second
+	if maxAge <= 0 {
+		maxAge = 2 * o.interval()
+	}
+	age := time.Since(st.Timestamp).Round(time.Second)
+	switch {
+	case st.Timestamp.IsZero():
+		fmt.Println("❌ Unhealthy: no cycle has completed yet")
+	case age > maxAge:
+		fmt.Printf("❌ Unhealthy: the last cycle ran %s ago\n", age)
+	case !st.Success:
+		fmt.Printf("❌ Unhealthy: the last cycle %s ago failed: %s\n", age, st.Summary)
+	default:
+		fmt.Printf("✅ Healthy: the last cycle %s ago succeeded\n", age)
+		return exitOK
+	}
+	return exitFailure
+}
+
+// fetchStatus gets the last cycle's status from the admin API listening on
+// addr, connecting to the local host when addr has no host.
+func fetchStatus(addr, token string) (*updater.Status, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	req, err := http.NewRequest("GET", "http://"+net.JoinHostPort(host, port)+"/api/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin API: HTTP %d", resp.StatusCode)
+	}
+	var st updater.Status
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
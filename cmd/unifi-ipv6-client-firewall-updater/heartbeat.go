@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// heartbeat is notified around every cycle so external monitors can alert
+// when the updater fails or stops running altogether.
+type heartbeat interface {
+	start()
+	finish(err error)
+}
+
+// heartbeats returns the configured heartbeat targets.
+func (o *options) heartbeats() []heartbeat {
+	var hbs []heartbeat
+	if o.HealthcheckURL != "" {
+		hbs = append(hbs, healthcheck{url: o.HealthcheckURL})
+	}
+	if o.UptimeKumaURL != "" {
+		hbs = append(hbs, &uptimeKuma{url: o.UptimeKumaURL})
+	}
+	if sd := newSystemd(); sd != nil {
+		hbs = append(hbs, sd)
+	}
+	return hbs
+}
+
+// healthcheck pings a healthchecks.io style check around every cycle so a
+// stalled or crashed updater is noticed by the absence of pings.
+type healthcheck struct {
+	url string
+}
+
+// start signals that a cycle has begun (<url>/start).
+func (h healthcheck) start() {
+	h.ping("/start", "")
+}
+
+// finish signals success (<url>) or failure (<url>/fail) of a cycle. On
+// failure the error text is sent as the request body so it shows up in the
+// check's event log.
+func (h healthcheck) finish(err error) {
+	if err != nil {
+		h.ping("/fail", err.Error())
+		return
+	}
+	h.ping("", "")
+}
+
+func (h healthcheck) ping(suffix, body string) {
+	url := strings.TrimRight(h.url, "/") + suffix
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		fmt.Println("⚠️  Healthcheck ping failed:", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Printf("⚠️  Healthcheck ping failed: HTTP %d\n", resp.StatusCode)
+	}
+}
+
+// uptimeKuma reports each cycle to an Uptime Kuma push monitor. The push URL
+// is used as given, with its status, msg and ping query parameters replaced.
+type uptimeKuma struct {
+	url     string
+	started time.Time
+}
+
+func (k *uptimeKuma) start() {
+	k.started = time.Now()
+}
+
+func (k *uptimeKuma) finish(err error) {
+	u, perr := url.Parse(k.url)
+	if perr != nil {
+		fmt.Println("⚠️  Invalid Uptime Kuma push URL:", perr)
+		return
+	}
+
+	q := u.Query()
+	q.Set("status", "up")
+	q.Set("msg", "OK")
+	if err != nil {
+		q.Set("status", "down")
+		q.Set("msg", err.Error())
+	}
+	q.Set("ping", fmt.Sprint(time.Since(k.started).Milliseconds()))
+	u.RawQuery = q.Encode()
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		fmt.Println("⚠️  Uptime Kuma push failed:", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Printf("⚠️  Uptime Kuma push failed: HTTP %d\n", resp.StatusCode)
+	}
+}
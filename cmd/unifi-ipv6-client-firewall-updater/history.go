@@ -0,0 +1,133 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+)
+
+// cmdHistory prints the changes recorded in HISTORY_FILE, optionally only
+// those of one client or within a time range, as a table or exported as CSV
+// or JSON.
+func cmdHistory(o *options) int {
+	h := o.history()
+	if h == nil {
+		fmt.Println("❌ HISTORY_FILE (--history-file) is required")
+		return exitConfig
+	}
+	switch o.HistoryFormat {
+	case "table", "csv", "json":
+	default:
+		fmt.Printf("❌ Unknown format %q, use table, csv or json\n", o.HistoryFormat)
+		return exitConfig
+	}
+	q, err := o.historyQuery()
+	if err != nil {
+		fmt.Println("❌", err)
+		return exitConfig
+	}
+	entries, err := h.Read(q)
+	if err != nil {
+		fmt.Println("❌ Failed to read history:", err)
+		return exitFailure
+	}
+
+	switch o.HistoryFormat {
+	case "csv":
+		err = history.WriteCSV(os.Stdout, entries)
+	case "json":
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to export history:", err)
+		return exitFailure
+	}
+	if o.HistoryFormat != "table" {
+		return exitOK
+	}
+	if len(entries) == 0 {
+		fmt.Println("No changes recorded")
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tCLIENT\tENTRY\tCHANGE\tRESULT")
+	for _, e := range entries {
+		result := e.Result
+		if e.Error != "" {
+			result += ": " + e.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s → %s\t%s\n", e.Time.Local().Format(time.DateTime), e.Action,
+			cmp.Or(historyLabel(e), "-"), e.Target+" "+e.Ref,
+			cmp.Or(strings.Join(e.Old, ", "), "none"), cmp.Or(strings.Join(e.New, ", "), "none"), result)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// record adds e to the history, if kept, the error err making it a failed
+// change.
+func (o *options) record(e history.Entry, err error) {
+	h := o.history()
+	if h == nil {
+		return
+	}
+	if err != nil {
+		e.Result, e.Error = history.ResultFailed, err.Error()
+	}
+	if err := h.Record(e); err != nil {
+		fmt.Println("⚠️  Failed to record history:", err)
+	}
+}
+
+// historyQuery returns the query selecting the changes asked for.
+func (o *options) historyQuery() (history.Query, error) {
+	q := history.Query{Client: o.HistoryClient}
+	var err error
+	if q.Since, err = parseTime(o.HistorySince); err != nil {
+		return q, fmt.Errorf("invalid --since: %w", err)
+	}
+	if q.Until, err = parseTime(o.HistoryUntil); err != nil {
+		return q, fmt.Errorf("invalid --until: %w", err)
+	}
+	return q, nil
+}
+
+// parseTime parses s as an RFC 3339 time, a local date with or without a
+// time of day, or a duration before now. Empty is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a time, a date nor a duration", s)
+}
+
+// historyLabel returns the name and MAC of the entry's client, if any.
+func historyLabel(e history.Entry) string {
+	if e.Name == "" {
+		return e.MAC
+	}
+	return e.Name + " (" + e.MAC + ")"
+}
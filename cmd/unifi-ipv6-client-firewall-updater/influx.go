@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// Escapers for the InfluxDB line protocol: of tag keys and values, and of
+// string field values.
+var (
+	tagEscaper   = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+	fieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// influx writes each cycle to an InfluxDB 2 bucket as line protocol: a
+// unifi_ipv6_run point with the cycle's duration and summary, and a
+// unifi_ipv6_address_change point for every client whose address changed.
+type influx struct {
+	url    string
+	token  string
+	org    string
+	bucket string
+}
+
+func (f influx) report(st updater.Status) error {
+	var body bytes.Buffer
+	writeLines(&body, st)
+
+	q := url.Values{"org": {f.org}, "bucket": {f.bucket}, "precision": {"s"}}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(f.url, "/")+"/api/v2/write?"+q.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Token "+f.token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeLines writes the points of the cycle st as line protocol.
+func writeLines(b *bytes.Buffer, st updater.Status) {
+	fmt.Fprintf(b, "unifi_ipv6_run,success=%t duration_seconds=%.3f", st.Success, float64(st.DurationMS)/1000)
+	for _, o := range summaryOutcomes(st.Summary) {
+		fmt.Fprintf(b, ",%s=%di", o.name, o.n)
+	}
+	fmt.Fprintf(b, " %d\n", st.Timestamp.Unix())
+
+	for _, c := range changedClients(st) {
+		fmt.Fprintf(b, "unifi_ipv6_address_change,mac=%s", tagEscaper.Replace(strings.ToLower(c.MAC)))
+		// empty tag values aren't allowed
+		if c.GroupID != "" {
+			fmt.Fprintf(b, ",group_id=%s", tagEscaper.Replace(c.GroupID))
+		}
+		if c.Name != "" {
+			fmt.Fprintf(b, ",name=%s", tagEscaper.Replace(c.Name))
+		}
+		fmt.Fprintf(b, ` ipv6="%s",previous_ipv6="%s" %d`+"\n",
+			fieldEscaper.Replace(c.IPv6), fieldEscaper.Replace(c.PreviousIPv6), c.LastChanged.Unix())
+	}
+}
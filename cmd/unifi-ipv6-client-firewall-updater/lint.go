@@ -0,0 +1,184 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdLint reports the config's problems with their severity and line:
+// values not matching the schema, those found in the file alone, then,
+// with a controller to ask, the
+// controllers that can't be reached and the firewall groups that don't
+// exist or can't hold IPv6 addresses.
+func cmdLint(o *options) int {
+	if o.LintFormat != "text" && o.LintFormat != "json" {
+		fmt.Printf("❌ Unknown format %q: use text or json\n", o.LintFormat)
+		return exitConfig
+	}
+	data, err := os.ReadFile(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Invalid config:", err)
+		return exitConfig
+	}
+
+	// entries that don't match the schema may still load, ignored or
+	// zero, so the config is linted as far as it loads
+	diags := updater.CheckSchema(data)
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	switch {
+	case err == nil:
+		lint := append(updater.Lint(cfg), o.lintControllers(cfg)...)
+		updater.Locate(data, lint)
+		diags = append(diags, lint...)
+	case len(diags) == 0:
+		fmt.Println("❌ Invalid config:", err)
+		return exitConfig
+	default:
+		cfg = &updater.Config{}
+	}
+	slices.SortStableFunc(diags, func(a, b updater.Diagnostic) int { return cmp.Compare(a.Line, b.Line) })
+
+	var errs, warnings int
+	for _, d := range diags {
+		switch d.Severity {
+		case updater.SeverityError:
+			errs++
+		case updater.SeverityWarning:
+			warnings++
+		}
+	}
+	code := exitOK
+	if errs > 0 || o.LintStrict && warnings > 0 {
+		code = exitConfig
+	}
+
+	if o.LintFormat == "json" {
+		if diags == nil {
+			diags = []updater.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diags); err != nil {
+			fmt.Println("❌ Failed to write diagnostics:", err)
+			return exitFailure
+		}
+		return code
+	}
+
+	for _, d := range diags {
+		icon := "ℹ️ "
+		switch d.Severity {
+		case updater.SeverityError:
+			icon = "❌"
+		case updater.SeverityWarning:
+			icon = "⚠️ "
+		}
+		at := o.ConfigPath
+		if d.Line > 0 {
+			at = fmt.Sprintf("%s:%d", o.ConfigPath, d.Line)
+		}
+		if d.Path != "" {
+			at += ": " + d.Path
+		}
+		fmt.Printf("%s %s: %s\n", icon, at, d.Message)
+	}
+	if errs == 0 && warnings == 0 {
+		fmt.Printf("✅ %s has no problems (%d clients)\n", o.ConfigPath, len(cfg.Clients))
+	} else {
+		fmt.Printf("%d errors, %d warnings\n", errs, warnings)
+	}
+	return code
+}
+
+// lintControllers checks that the controllers are reachable and that the
+// clients' firewall groups exist on their sites and are IPv6 groups. The
+// updater's own controller is only checked when UNIFI_HOST and
+// UNIFI_API_KEY are set.
+func (o *options) lintControllers(cfg *updater.Config) []updater.Diagnostic {
+	var diags []updater.Diagnostic
+	add := func(severity, path, format string, args ...any) {
+		diags = append(diags, updater.Diagnostic{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// firewall groups by controller and site, nil where they can't be read
+	siteGroups := map[string][]unifi.FirewallGroup{}
+	unreachable := map[string]bool{}
+	if o.Host == "" || !o.hasCredentials() {
+		add(updater.SeverityInfo, "", "UNIFI_HOST and UNIFI_API_KEY or a login are not set, so the updater's own controller is not checked")
+		unreachable[""] = true
+	} else if groups, err := o.controller().FirewallGroups(); err != nil {
+		msg, _ := explainControllerError(o.Host, err)
+		add(updater.SeverityError, "", "%s", msg)
+		unreachable[""] = true
+	} else {
+		siteGroups["/"] = groups
+	}
+	for i, cc := range cfg.Controllers {
+		if cc.Name == "" || cc.URL() == "" || cc.APIKey == "" {
+			continue // reported by Lint
+		}
+		path := fmt.Sprintf("controllers[%d]", i)
+		if !slices.ContainsFunc(cfg.Clients, func(c updater.ClientConfig) bool { return c.Controller == cc.Name }) {
+			add(updater.SeverityInfo, path, "no client is on controller %q", cc.Name)
+		}
+		ctrl, err := o.siteController(&cfg.Controllers[i], "")
+		if err == nil {
+			var groups []unifi.FirewallGroup
+			if groups, err = ctrl.FirewallGroups(); err == nil {
+				siteGroups[cc.Name+"/"] = groups
+			}
+		}
+		if err != nil {
+			msg, _ := explainControllerError(cc.URL(), err)
+			add(updater.SeverityError, path, "controller %q is unreachable: %s", cc.Name, msg)
+			unreachable[cc.Name] = true
+		}
+	}
+
+	for i, c := range cfg.Clients {
+		if unreachable[c.Controller] || c.Controller != "" && !slices.ContainsFunc(cfg.Controllers, func(cc updater.ControllerConfig) bool { return cc.Name == c.Controller }) {
+			continue // reported above, or by Lint
+		}
+		for j, d := range c.Destinations() {
+			if d.Target != updater.DefaultTarget || d.Ref == "" {
+				continue
+			}
+			path := fmt.Sprintf("clients[%d].group_id", i)
+			if j > 0 {
+				path = fmt.Sprintf("clients[%d].also[%d].ref", i, j-1)
+			}
+			c := c.At(d)
+			key := c.Controller + "/" + c.Site
+			groups, ok := siteGroups[key]
+			if !ok {
+				ctrl, err := o.clientController(cfg, c)
+				if err == nil {
+					groups, err = ctrl.FirewallGroups()
+				}
+				if err != nil {
+					add(updater.SeverityError, path, "cannot read firewall groups of site %q of %s: %v", cmp.Or(c.Site, "default"), cmp.Or(c.Controller, o.Host), err)
+				}
+				siteGroups[key] = groups
+			}
+			if groups == nil {
+				continue
+			}
+			k := slices.IndexFunc(groups, func(g unifi.FirewallGroup) bool { return g.ID == d.Ref })
+			switch {
+			case k < 0:
+				add(updater.SeverityError, path, "firewall group %s does not exist on site %q", d.Ref, cmp.Or(c.Site, "default"))
+			case groups[k].Type == unifi.GroupTypeIPv4:
+				add(updater.SeverityError, path, "firewall group %s (%s) is an IPv4 address group, which can't hold %s's addresses: use an IPv6 address group", groups[k].Name, d.Ref, c.Label())
+			case groups[k].Type != unifi.GroupTypeIPv6:
+				add(updater.SeverityError, path, "firewall group %s (%s) is a %s, not an IPv6 address group", groups[k].Name, d.Ref, groups[k].Type)
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// serveListener accepts DynDNS-style address updates pushed by the tracked
+// clients themselves on addr. The client is identified by the token it
+// sends, and its address is taken from the myip parameter or, failing that,
+// the IPv6 source address of the request. Each accepted update runs a cycle.
+func (d *daemon) serveListener(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nic/update", d.handlePush)
+	mux.HandleFunc("/update", d.handlePush)
+
+	fmt.Println("✅ Update listener listening on", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Println("❌ Update listener failed:", err)
+	}
+}
+
+// handlePush answers in the dyndns2 protocol's plain text, which DDNS
+// clients such as ddclient and inadyn understand.
+func (d *daemon) handlePush(w http.ResponseWriter, r *http.Request) {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, pass, ok := r.BasicAuth(); ok {
+		token = pass
+	}
+	if token == "" {
+		token = r.FormValue("token")
+	}
+
+	cfg, err := d.store.Load()
+	if err != nil {
+		http.Error(w, "911", http.StatusInternalServerError)
+		return
+	}
+	var client *updater.ClientConfig
+	for i, c := range cfg.Clients {
+		if token != "" && c.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+			client = &cfg.Clients[i]
+			break
+		}
+	}
+	if client == nil {
+		http.Error(w, "badauth", http.StatusUnauthorized)
+		return
+	}
+
+	var addrs []string
+	for _, key := range []string{"myip", "myipv6", "ipv6"} {
+		for _, v := range strings.Split(r.FormValue(key), ",") {
+			addrs = append(addrs, strings.TrimSpace(v))
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		addrs = append(addrs, host)
+	}
+	ipv6, err := updater.GlobalIPv6(addrs)
+	if err != nil {
+		http.Error(w, "noip", http.StatusBadRequest)
+		return
+	}
+
+	if ipv6 == client.LastIPv6 {
+		fmt.Fprintln(w, "nochg", ipv6)
+		return
+	}
+	fmt.Printf("📥 %s pushed %s\n", client.Label(), ipv6)
+	d.pushed.Push(client.MAC, []string{ipv6})
+	d.requestRun()
+	fmt.Fprintln(w, "good", ipv6)
+}
@@ -0,0 +1,24 @@
+//go:build unix || windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// openLock opens the file lockState locks for the config file at path:
+// path+".lock", which stays the same file however the config is saved.
+// Where that can't be created, e.g. for a config file mounted on its own
+// into a read-only directory, the config file itself is locked instead.
+func openLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err == nil || !errors.Is(err, fs.ErrPermission) && !errors.Is(err, syscall.EROFS) {
+		return f, err
+	}
+	fmt.Printf("⚠️  Can't create %s.lock (%v), locking the config file itself\n", path, err)
+	return os.Open(path)
+}
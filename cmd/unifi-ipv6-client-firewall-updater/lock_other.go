@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// lockState is a no-op where neither flock nor LockFileEx is available.
+func lockState(path string) (*os.File, error) {
+	return nil, nil
+}
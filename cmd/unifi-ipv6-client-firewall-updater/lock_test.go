@@ -0,0 +1,46 @@
+//go:build unix || windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+func TestLockState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	if err := updater.SaveConfig(path, &updater.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := lockState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock holds across saves and the file being replaced
+	if err := updater.SaveConfig(path, &updater.Config{Clients: []updater.ClientConfig{{MAC: "aa:bb:cc:dd:ee:01", GroupID: "g1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockState(path); err == nil {
+		t.Fatal("second lock succeeded after a save")
+	}
+	if err := os.Rename(path, path+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := updater.SaveConfig(path, &updater.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockState(path); err == nil {
+		t.Fatal("second lock succeeded after the config was replaced")
+	}
+
+	lock.Close()
+	again, err := lockState(path)
+	if err != nil {
+		t.Fatalf("lock after the first was released: %v", err)
+	}
+	again.Close()
+}
@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockState takes an exclusive advisory lock for the config file, which
+// also holds the cached addresses, so a second copy started against the same
+// file by mistake refuses to run instead of interleaving its updates with
+// ours. The lock is on a file of its own next to it, see openLock, which
+// stays the same file when the config is replaced, e.g. by an editor. The
+// lock lasts until the returned file is closed or the process exits, so a
+// crash never leaves it behind.
+func lockState(path string) (*os.File, error) {
+	f, err := openLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("another instance is already running with %s", path)
+		}
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// flushLogs waits for everything printed so far to reach the log
+// destinations. It is replaced by redirectLogs and must run before the
+// process exits, see exit.
+var flushLogs = func() {}
+
+// exit flushes the logs and exits with code.
+func exit(code int) {
+	flushLogs()
+	os.Exit(code)
+}
+
+// logLine is a line the process printed.
+type logLine struct {
+	time   time.Time
+	text   string // with its newline
+	level  string
+	stderr bool
+}
+
+// lineLevel returns the severity of a printed line, going by its emoji.
+func lineLevel(text string) string {
+	switch {
+	case strings.HasPrefix(text, "❌"):
+		return updater.SeverityError
+	case strings.HasPrefix(text, "⚠️"):
+		return updater.SeverityWarning
+	}
+	return updater.SeverityInfo
+}
+
+// severities ranks the levels, least severe first.
+var severities = []string{updater.SeverityInfo, updater.SeverityWarning, updater.SeverityError}
+
+// logWriter is a destination of the log.
+type logWriter interface {
+	writeLine(l logLine, text string) error
+	Close() error
+}
+
+// logDest is a destination with the format its lines are written in and
+// the least severe line it gets.
+type logDest struct {
+	name   string
+	w      logWriter
+	format string
+	level  int
+}
+
+// render returns l as d writes it.
+func (d logDest) render(l logLine) string {
+	if d.format != updater.LogJSON {
+		return l.text
+	}
+	data, _ := json.Marshal(struct {
+		Time  time.Time `json:"time"`
+		Level string    `json:"level"`
+		Msg   string    `json:"msg"`
+	}{l.time, l.level, strings.TrimSpace(l.text)})
+	return string(data) + "\n"
+}
+
+// console writes lines to the stream they were printed to.
+type console struct{ stdout, stderr *os.File }
+
+func (c console) writeLine(l logLine, text string) error {
+	if l.stderr {
+		_, err := c.stderr.WriteString(text)
+		return err
+	}
+	_, err := c.stdout.WriteString(text)
+	return err
+}
+
+func (console) Close() error { return nil }
+
+// fileLog writes lines to a rotating file.
+type fileLog struct{ *rotatingFile }
+
+func (f fileLog) writeLine(_ logLine, text string) error {
+	_, err := f.Write([]byte(text))
+	return err
+}
+
+// logDests returns the destinations of the log section of the config and
+// LOG_FILE. The console gets every line as printed unless the section
+// says otherwise.
+func (o *options) logDests(cfg []updater.LogConfig, stdout, stderr *os.File) []logDest {
+	dests := []logDest{{name: "console", w: console{stdout, stderr}}}
+	if o.LogFile != "" {
+		cfg = append(cfg, updater.LogConfig{Output: updater.LogFile, Path: o.LogFile})
+	}
+	seenConsole := false
+	for _, c := range cfg {
+		d := logDest{name: c.Output, format: c.Format, level: max(slices.Index(severities, c.Level), 0)}
+		switch c.Output {
+		case updater.LogConsole:
+			if !seenConsole {
+				seenConsole = true
+				d.w = dests[0].w
+				dests[0] = d
+			}
+			continue
+		case updater.LogFile:
+			f := &rotatingFile{path: c.Path, maxSize: int64(o.LogMaxSize) << 20, backups: o.LogMaxBackups,
+				maxAge: time.Duration(o.LogMaxAge) * 24 * time.Hour}
+			if err := f.open(); err != nil {
+				fmt.Fprintln(stdout, "⚠️  Failed to open log file:", err)
+				continue
+			}
+			d.name, d.w = c.Path, fileLog{f}
+		case updater.LogSyslog:
+			w, err := dialSyslog(c.Address)
+			if err != nil {
+				fmt.Fprintln(stdout, "⚠️  Failed to connect to syslog:", err)
+				continue
+			}
+			d.w = w
+		default:
+			fmt.Fprintf(stdout, "⚠️  Unknown log output %q\n", c.Output)
+			continue
+		}
+		dests = append(dests, d)
+	}
+	return dests
+}
+
+// redirectLogs sends everything the process prints to stdout and stderr
+// to the destinations of the log section of the config and LOG_FILE, each
+// in its own format and from its own level, when there are any besides
+// the console.
+func (o *options) redirectLogs() {
+	var cfg []updater.LogConfig
+	if c, err := (updater.FileStore{Path: o.ConfigPath}).Load(); err == nil {
+		cfg = c.Log
+	}
+	if o.LogFile == "" && len(cfg) == 0 {
+		return
+	}
+	dests := o.logDests(cfg, os.Stdout, os.Stderr)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex // keeps lines from both streams whole
+	var closers []io.Closer
+	for _, std := range []**os.File{&os.Stdout, &os.Stderr} {
+		r, w, err := os.Pipe()
+		if err != nil {
+			fmt.Println("⚠️  Failed to redirect logs:", err)
+			return
+		}
+		stderr := *std == os.Stderr
+		*std = w
+		closers = append(closers, w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lines := bufio.NewReader(r)
+			for {
+				text, err := lines.ReadString('\n')
+				if text != "" {
+					mu.Lock()
+					writeLine(dests, logLine{time: time.Now(), text: text, level: lineLevel(text), stderr: stderr})
+					mu.Unlock()
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	flushLogs = func() {
+		for _, c := range closers {
+			c.Close()
+		}
+		wg.Wait()
+		for _, d := range dests {
+			d.w.Close()
+		}
+	}
+}
+
+// writeLine writes l to the destinations it is severe enough for. Failures
+// are reported on the console.
+func writeLine(dests []logDest, l logLine) {
+	level := slices.Index(severities, l.level)
+	for _, d := range dests {
+		if level < d.level {
+			continue
+		}
+		if err := d.w.writeLine(l, d.render(l)); err != nil && d.name != "console" {
+			dests[0].w.writeLine(logLine{}, fmt.Sprintf("⚠️  Failed to write the log to %s: %v\n", d.name, err))
+		}
+	}
+}
+
+// rotatingFile is a log file that is moved aside to path.1, path.1 to
+// path.2 and so on, once it would grow beyond maxSize. Backups beyond the
+// number kept or older than maxAge, if set, are deleted.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+	maxAge  time.Duration
+
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error { return r.f.Close() }
+
+// rotate moves the file aside, shifting and pruning the backups, and
+// starts a new one.
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", r.path, i) }
+	os.Remove(backup(r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(backup(i), backup(i+1))
+	}
+	if r.backups > 0 {
+		os.Rename(r.path, backup(1))
+	} else {
+		os.Remove(r.path)
+	}
+	if r.maxAge > 0 {
+		old, _ := filepath.Glob(r.path + ".*")
+		for _, b := range old {
+			info, err := os.Stat(b)
+			if err == nil && strings.Trim(strings.TrimPrefix(b, r.path+"."), "0123456789") == "" &&
+				time.Since(info.ModTime()) > r.maxAge {
+				os.Remove(b)
+			}
+		}
+	}
+	return r.open()
+}
@@ -0,0 +1,208 @@
+// +ko-build
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+const usage = `Usage: unifi-ipv6-client-firewall-updater [command] [flags]
+
+Commands:
+  serve     run the updater on a schedule (default)
+  once      run a single cycle and exit with a status code
+  agent     run on the client itself, publishing its own interfaces'
+            addresses as soon as they change
+  operator  run in Kubernetes with the clients declared as
+            ClientFirewallEntry resources instead of in the config file
+  validate  check the configuration file and the controller it refers to
+  lint      report config entries that are wrong or work against each
+            other, with their severity and line
+  schema    print the JSON Schema of the configuration file
+  migrate   rewrite the configuration file in the current layout, keeping
+            a backup of the original
+  list      list the tracked clients and their cached addresses
+  list-clients
+            list all clients known to the controller with their addresses
+  list-groups
+            list all firewall groups with their IDs and members
+  list-consoles
+            list the consoles a Site Manager API key can reach, with
+            their IDs
+  discover  list the UniFi devices on the local network and the consoles
+            among them
+  import    print a starter config built from the existing firewall groups
+  cleanup   remove members no tracked client has from their firewall groups
+  restore   list the snapshots of a firewall group or put one back
+  history   show the recorded changes, per client or time range
+  report    report on the clients tracked on every controller, their last
+            change and what is failing
+  mock      serve a fake controller to try the updater out against
+  status    show the result of the last cycle from the status file
+  healthcheck
+            exit 0 only if the last cycle was recent and successful, for
+            Docker and compose healthchecks
+  service   install, uninstall, start or stop the Windows service
+  version   print the version
+
+Every setting can be given as a flag or as the environment variable shown
+in the flag's help. Run "<command> -h" for the flags.
+`
+
+func main() {
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	if len(args) > 0 && (args[0] == "--version" || args[0] == "-version") {
+		cmd, args = "version", args[1:]
+	}
+
+	o := optionsFromEnv()
+	fs := o.flagSet(cmd)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fmt.Fprintf(fs.Output(), "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var run func(*options) int
+	switch cmd {
+	case "serve":
+		run = cmdServe
+	case "once":
+		o.RunOnce = true
+		run = cmdServe
+	case "agent":
+		run = cmdAgent
+	case "operator":
+		o.Operator = true
+		run = cmdServe
+	case "validate":
+		run = cmdValidate
+	case "lint":
+		run = cmdLint
+	case "schema":
+		run = cmdSchema
+	case "migrate":
+		run = cmdMigrate
+	case "list":
+		run = cmdList
+	case "list-clients":
+		run = cmdListClients
+	case "list-groups":
+		run = cmdListGroups
+	case "list-consoles":
+		run = cmdListConsoles
+	case "discover":
+		run = cmdDiscover
+	case "import":
+		run = cmdImport
+	case "cleanup":
+		run = cmdCleanup
+	case "restore":
+		run = cmdRestore
+	case "history":
+		run = cmdHistory
+	case "report":
+		run = cmdReport
+	case "mock":
+		run = cmdMock
+	case "status":
+		run = cmdStatus
+	case "healthcheck":
+		run = cmdHealthcheck
+	case "service":
+		run = cmdService
+	case "version":
+		run = cmdVersion
+	case "help":
+		fs.Usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", cmd)
+		fs.Usage()
+		exit(exitConfig)
+	}
+	fs.Parse(args)
+	if o.Host == "" && o.ConsoleID != "" {
+		o.Host = unifi.ConsoleURL(o.ConsoleID)
+	}
+	exit(runService(&o, run))
+}
+
+// cmdServe runs the updater on a schedule, or once when RunOnce is set.
+func cmdServe(o *options) int {
+	o.redirectLogs()
+	o.startupDelay()
+	o.requireController()
+	fmt.Println("🚀", versionString())
+	interval := o.interval()
+
+	flushSentry := initSentry(o.SentryDSN, o.SentryEnvironment)
+	defer flushSentry()
+
+	// with leader election the replicas take turns by themselves, and an
+	// operator keeps its state in the cluster
+	if o.LeaderElection == "" && !o.Operator {
+		lock, err := lockState(o.ConfigPath)
+		if err != nil {
+			fmt.Println("❌", err)
+			return exitFailure
+		}
+		defer lock.Close()
+	}
+
+	d := newDaemon(o)
+	if code := checkController(o, d.ctrl); code != exitOK {
+		return code
+	}
+	e, err := o.elector()
+	if err != nil {
+		fmt.Println("❌ Leader election:", err)
+		return exitConfig
+	}
+	if e != nil {
+		d.elect(e)
+	}
+	if o.RunOnce {
+		err := d.runCycle()
+		notify.Flush()
+		if o.PushgatewayURL != "" {
+			if perr := d.pushMetrics(o.PushgatewayURL, o.PushgatewayJob); perr != nil {
+				fmt.Println("⚠️  Pushing metrics to the Pushgateway failed:", perr)
+			}
+		}
+		return exitCode(err)
+	}
+	d.stopOnSignal()
+	d.dumpOnSignal()
+
+	if o.AdminAddr != "" {
+		go d.serveAdmin(o.AdminAddr)
+	}
+	if o.GRPCAddr != "" {
+		go d.serveGRPC(o.GRPCAddr)
+	}
+	if o.ListenAddr != "" {
+		go d.serveListener(o.ListenAddr)
+	}
+	if o.WatchPrefix {
+		go d.watchPrefix(time.Duration(o.PrefixCheckInterval) * time.Second)
+	}
+	if o.WatchEvents {
+		go d.watchEvents()
+	}
+	if d.resources != nil {
+		go d.watchResources(resourcePollInterval)
+	}
+
+	fmt.Printf("✅ Running updater every %v\n", interval)
+	d.run(interval)
+	return exitOK
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// labelEscaper escapes Prometheus label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// handleMetrics serves the gauges of writeMetrics.
+func (d *daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	d.writeMetrics(w)
+}
+
+// metricsContentType is the Prometheus text format written by writeMetrics.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// writeMetrics writes per-client gauges in the Prometheus text format:
+// when each client's address last changed and how long it has had it, for
+// graphing how often the ISP renumbers. Clients not seen changing since the
+// status file was started have neither. How the last cycle went and whether
+// the controller is reachable are written too.
+func (d *daemon) writeMetrics(w io.Writer) {
+	st := d.status()
+	now := time.Now()
+
+	var changed, age strings.Builder
+	for _, c := range st.Clients {
+		if c.LastChanged.IsZero() {
+			continue
+		}
+		labels := fmt.Sprintf(`mac="%s",name="%s",group_id="%s"`,
+			labelEscaper.Replace(strings.ToLower(c.MAC)), labelEscaper.Replace(c.Name), labelEscaper.Replace(c.GroupID))
+		fmt.Fprintf(&changed, "unifi_ipv6_client_last_change_timestamp_seconds{%s} %d\n", labels, c.LastChanged.Unix())
+		fmt.Fprintf(&age, "unifi_ipv6_client_address_age_seconds{%s} %.0f\n", labels, now.Sub(c.LastChanged).Seconds())
+	}
+
+	fmt.Fprintln(w, "# HELP unifi_ipv6_client_last_change_timestamp_seconds When the client's published address last changed.")
+	fmt.Fprintln(w, "# TYPE unifi_ipv6_client_last_change_timestamp_seconds gauge")
+	fmt.Fprint(w, changed.String())
+	fmt.Fprintln(w, "# HELP unifi_ipv6_client_address_age_seconds How long the client has had its published address.")
+	fmt.Fprintln(w, "# TYPE unifi_ipv6_client_address_age_seconds gauge")
+	fmt.Fprint(w, age.String())
+
+	if !st.Timestamp.IsZero() {
+		success := 0
+		if st.Success {
+			success = 1
+		}
+		fmt.Fprintln(w, "# HELP unifi_ipv6_last_run_timestamp_seconds When the last cycle ran.")
+		fmt.Fprintln(w, "# TYPE unifi_ipv6_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "unifi_ipv6_last_run_timestamp_seconds %d\n", st.Timestamp.Unix())
+		fmt.Fprintln(w, "# HELP unifi_ipv6_last_run_duration_seconds How long the last cycle took.")
+		fmt.Fprintln(w, "# TYPE unifi_ipv6_last_run_duration_seconds gauge")
+		fmt.Fprintf(w, "unifi_ipv6_last_run_duration_seconds %.3f\n", float64(st.DurationMS)/1000)
+		fmt.Fprintln(w, "# HELP unifi_ipv6_last_run_success Whether the last cycle succeeded.")
+		fmt.Fprintln(w, "# TYPE unifi_ipv6_last_run_success gauge")
+		fmt.Fprintf(w, "unifi_ipv6_last_run_success %d\n", success)
+		fmt.Fprintln(w, "# HELP unifi_ipv6_last_run_clients What happened to the clients during the last cycle, by outcome.")
+		fmt.Fprintln(w, "# TYPE unifi_ipv6_last_run_clients gauge")
+		for _, o := range summaryOutcomes(st.Summary) {
+			fmt.Fprintf(w, "unifi_ipv6_last_run_clients{outcome=\"%s\"} %d\n", o.name, o.n)
+		}
+	}
+
+	up, since := d.controllerUp()
+	fmt.Fprintln(w, "# HELP unifi_ipv6_controller_up Whether the controller is reachable.")
+	fmt.Fprintln(w, "# TYPE unifi_ipv6_controller_up gauge")
+	if up {
+		fmt.Fprintln(w, "unifi_ipv6_controller_up 1")
+		return
+	}
+	fmt.Fprintln(w, "unifi_ipv6_controller_up 0")
+	fmt.Fprintln(w, "# HELP unifi_ipv6_controller_unreachable_since_timestamp_seconds When the controller became unreachable.")
+	fmt.Fprintln(w, "# TYPE unifi_ipv6_controller_unreachable_since_timestamp_seconds gauge")
+	fmt.Fprintf(w, "unifi_ipv6_controller_unreachable_since_timestamp_seconds %d\n", since.Unix())
+}
+
+// metricsSink receives the outcome of every cycle, for pushing it to a
+// time series database.
+type metricsSink interface {
+	report(st updater.Status) error
+}
+
+// metricsSinks returns the configured metrics sinks, by name.
+func (o *options) metricsSinks() map[string]metricsSink {
+	sinks := map[string]metricsSink{}
+	if o.InfluxURL != "" {
+		sinks["InfluxDB"] = influx{url: o.InfluxURL, token: o.InfluxToken, org: o.InfluxOrg, bucket: o.InfluxBucket}
+	}
+	if o.OTLPEndpoint != "" {
+		sinks["OTLP"] = newOTLP(o.OTLPEndpoint, o.OTLPHeaders, o.OTLPService)
+	}
+	if o.StatsDAddr != "" {
+		sinks["StatsD"] = statsd{addr: o.StatsDAddr, prefix: o.StatsDPrefix, tags: o.DogStatsD}
+	}
+	return sinks
+}
+
+// changedClients returns the clients whose address changed in the cycle st.
+func changedClients(st updater.Status) []updater.ClientStatus {
+	var changed []updater.ClientStatus
+	for _, c := range st.Clients {
+		if !c.LastChanged.IsZero() && !c.LastChanged.Before(st.Timestamp) {
+			changed = append(changed, c)
+		}
+	}
+	return changed
+}
+
+// outcome is one of the counts of a cycle's summary.
+type outcome struct {
+	name string
+	n    int
+}
+
+// summaryOutcomes returns the counts of s, named as in its JSON.
+func summaryOutcomes(s updater.Summary) []outcome {
+	return []outcome{
+		{"checked", s.Checked}, {"found", s.Found}, {"missing", s.Missing},
+		{"no_ipv6", s.NoIPv6}, {"changed", s.Changed}, {"updated", s.Updated},
+		{"paused", s.Paused}, {"errors", s.Errors}, {"drifted", s.Drifted},
+	}
+}
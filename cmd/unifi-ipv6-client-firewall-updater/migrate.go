@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdMigrate rewrites the config file in the current layout, keeping the
+// original next to it. The updater must not be running with the file.
+func cmdMigrate(o *options) int {
+	lock, err := lockState(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌", err)
+		return exitFailure
+	}
+	defer lock.Close()
+
+	data, err := os.ReadFile(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Failed to load config:", err)
+		return exitConfig
+	}
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Failed to load config:", err)
+		return exitConfig
+	}
+	changes, err := updater.Migrate(cfg)
+	if err != nil {
+		fmt.Println("❌", err)
+		return exitConfig
+	}
+	if len(changes) == 0 {
+		fmt.Printf("✅ %s is already version %d\n", o.ConfigPath, updater.ConfigVersion)
+		return exitOK
+	}
+
+	verb := "Migrated"
+	if o.DryRun {
+		verb = "Would migrate"
+	}
+	for _, c := range changes {
+		fmt.Printf("🔄 %s %s\n", verb, c)
+	}
+	if o.DryRun {
+		return exitOK
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", o.ConfigPath, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backup, data, 0o600); err != nil {
+		fmt.Println("❌ Failed to back up config:", err)
+		return exitFailure
+	}
+	if err := updater.SaveConfig(o.ConfigPath, cfg); err != nil {
+		fmt.Println("❌ Failed to save config:", err)
+		return exitFailure
+	}
+	fmt.Printf("✅ %s is now version %d, the original is kept in %s\n", o.ConfigPath, updater.ConfigVersion, backup)
+	return exitOK
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/mock"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdMock serves a fake controller until stopped, with the clients and
+// groups of --state or, without it, made up from the config file.
+func cmdMock(o *options) int {
+	var st *mock.State
+	var err error
+	if o.MockState != "" {
+		st, err = mock.LoadState(o.MockState)
+	} else {
+		var cfg *updater.Config
+		if cfg, err = updater.LoadConfig(o.ConfigPath); err == nil {
+			st = mockState(cfg)
+		}
+	}
+	if err != nil {
+		fmt.Println("❌ Failed to load mock state:", err)
+		return exitConfig
+	}
+
+	ctrl := mock.New(*st)
+	ctrl.APIKey = o.APIKey
+	ctrl.Log = log.New(os.Stdout, "", 0)
+	fmt.Printf("🧪 Mock controller with %d clients and %d firewall groups listening on %s\n", len(st.Clients), len(st.Groups), o.MockAddr)
+	fmt.Printf("   Point the updater at it with --host http://%s --api-key %s\n", o.MockAddr, cmp.Or(o.APIKey, "anything"))
+	if err := http.ListenAndServe(o.MockAddr, ctrl); err != nil {
+		fmt.Println("❌ Mock controller failed:", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// mockState makes up a controller for the config's clients: each one is
+// connected with its last published addresses, or a made-up one, and each
+// of their firewall groups has the addresses last published to it. Sites
+// and controllers are all served alike.
+func mockState(cfg *updater.Config) *mock.State {
+	st := &mock.State{WAN: []string{"2001:db8:ffff::1"}}
+	for i, c := range cfg.Clients {
+		addrs := c.Published()
+		if len(addrs) == 0 {
+			addrs = []string{fmt.Sprintf("2001:db8::%x", 0x100+i)}
+		}
+		if !slices.ContainsFunc(st.Clients, func(s unifi.Station) bool { return s.MAC == c.MAC }) {
+			st.Clients = append(st.Clients, unifi.Station{MAC: c.MAC, Name: c.Name, Network: "Default", IPv6Addresses: addrs})
+		}
+
+		for _, d := range c.Destinations() {
+			if d.Target != updater.DefaultTarget {
+				continue
+			}
+			j := slices.IndexFunc(st.Groups, func(g unifi.FirewallGroup) bool { return g.ID == d.Ref })
+			if j < 0 {
+				st.Groups = append(st.Groups, unifi.FirewallGroup{ID: d.Ref, Name: cmp.Or(c.Name, c.MAC) + " IPv6", Type: unifi.GroupTypeIPv6, Members: []string{}})
+				j = len(st.Groups) - 1
+			}
+			st.Groups[j].Members = append(st.Groups[j].Members, c.Published()...)
+		}
+	}
+	return st
+}
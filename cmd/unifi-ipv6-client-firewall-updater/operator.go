@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// resourcePollInterval is how often the operator lists the
+// ClientFirewallEntry resources for changes.
+const resourcePollInterval = 15 * time.Second
+
+// watchResources lists the ClientFirewallEntry resources and requests a
+// cycle whenever one is created, deleted or has its spec changed, so edits
+// applied to the cluster take effect without waiting for the next check.
+func (d *daemon) watchResources(every time.Duration) {
+	var last map[string]int64
+	for {
+		entries, err := d.resources.List()
+		if err != nil {
+			fmt.Println("⚠️  Failed to list ClientFirewallEntry resources:", err)
+		} else {
+			generations := make(map[string]int64, len(entries))
+			for _, e := range entries {
+				generations[e.Metadata.Name] = e.Metadata.Generation
+			}
+			if last != nil && !maps.Equal(generations, last) {
+				fmt.Println("⚡ ClientFirewallEntry resources changed")
+				d.requestRun()
+			}
+			last = generations
+		}
+		time.Sleep(every)
+	}
+}
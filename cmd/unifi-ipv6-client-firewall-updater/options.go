@@ -0,0 +1,725 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/backup"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// options holds the runtime settings. Defaults come from the environment
+// and every setting can be overridden by the matching command line flag.
+type options struct {
+	Host              string
+	APIKey            string
+	Site              string
+	ConfigPath        string
+	CheckInterval     int
+	RunAt             string
+	Timezone          string
+	VerifySSL         bool
+	RunOnce           bool
+	RunOnStart        bool
+	StartupDelay      int
+	StatusFile        string
+	StateDumpFile     string
+	LogFile           string
+	LogMaxSize        int
+	LogMaxBackups     int
+	LogMaxAge         int
+	HealthcheckURL    string
+	UptimeKumaURL     string
+	PushgatewayURL    string
+	PushgatewayJob    string
+	InfluxURL         string
+	InfluxToken       string
+	InfluxOrg         string
+	InfluxBucket      string
+	StatsDAddr        string
+	StatsDPrefix      string
+	DogStatsD         bool
+	OTLPEndpoint      string
+	OTLPHeaders       string
+	OTLPService       string
+	SentryDSN         string
+	SentryEnvironment string
+	AdminAddr         string
+	AdminToken        string
+	GRPCAddr          string
+	ListenAddr        string
+	LocalNeighbors    bool
+	NeighborInterface string
+	SSHHost           string
+	SSHUser           string
+	SSHPassword       string
+	SSHKeyFile        string
+	SSHKnownHosts     string
+	LeaderElection    string
+	LeaderID          string
+	LeaderName        string
+	LeaderNamespace   string
+	RedisAddr         string
+	RedisPassword     string
+	WatchEvents       bool
+	WatchPrefix       bool
+	IncludeOffline    bool
+	Concurrency       int
+	RateLimit         float64
+	RateBurst         int
+
+	// AddressPollInterval is how often the agent reads the local
+	// addresses, in seconds.
+	AddressPollInterval int
+	// PrefixCheckInterval is how often the WAN prefix is checked, in
+	// seconds.
+	PrefixCheckInterval int
+
+	// Operator keeps the clients in ClientFirewallEntry resources in
+	// WatchNamespace, the pod's own when empty, instead of the config file.
+	Operator       bool
+	WatchNamespace string
+
+	// AddressPreference, AllowULA and MaxAddresses choose which of a
+	// client's addresses are published, unless the client has its own.
+	AddressPreference string
+	AllowULA          bool
+	MaxAddresses      int
+	// ResolveHostnames looks up the names of new addresses.
+	ResolveHostnames bool
+	// VerifyReachable checks clients' new addresses before publishing
+	// them: ping, tcp:<port> or off.
+	VerifyReachable string
+	// DriftPolicy is what is done about firewall groups changed outside
+	// the updater, unless their clients have their own.
+	DriftPolicy string
+	// MaxGroupMembers caps the members of firewall groups without a limit
+	// in the config, 0 for no cap.
+	MaxGroupMembers int
+	// AddressOverlap is how long, in seconds, a replaced address stays
+	// published alongside the new one, unless the client has its own.
+	AddressOverlap int
+
+	// BackupDir, if set, is where firewall groups are snapshotted before
+	// each change, keeping BackupKeep snapshots per group.
+	BackupDir  string
+	BackupKeep int
+	// HistoryFile, if set, is where every change is recorded for the
+	// history command.
+	HistoryFile string
+
+	// Username and Password log in to the console in place of an API key,
+	// with TOTPSecret generating the 2FA code for accounts that need one.
+	Username   string
+	Password   string
+	TOTPSecret string
+
+	// ConsoleID reaches the console through the Site Manager with a Site
+	// Manager API key, in place of UNIFI_HOST.
+	ConsoleID string
+
+	// RecordFile, if set, is where every controller API exchange is
+	// recorded, and ReplayFile a recording answering them instead of the
+	// controllers.
+	RecordFile string
+	ReplayFile string
+	recording  *unifi.Recording
+	replayer   *unifi.Replayer
+
+	// HistoryClient, HistorySince and HistoryUntil select the changes the
+	// history command shows, and HistoryFormat is table, csv or json.
+	HistoryClient string
+	HistorySince  string
+	HistoryUntil  string
+	HistoryFormat string
+
+	// ReportFormat is table, csv or json.
+	ReportFormat string
+
+	// LintFormat is text or json, and LintStrict makes lint fail on
+	// warnings too.
+	LintFormat string
+	LintStrict bool
+
+	// DryRun makes cleanup, restore and migrate only print what they would
+	// change.
+	DryRun bool
+	// RestoreGroup and RestoreSnapshot choose the group and snapshot
+	// restore puts back.
+	RestoreGroup    string
+	RestoreSnapshot string
+
+	// MockAddr is where the mock command listens, and MockState the file
+	// with the clients and groups it serves.
+	MockAddr  string
+	MockState string
+
+	// HealthcheckMaxAge is how old, in seconds, the last cycle may be for
+	// the healthcheck command to pass; twice the check interval when 0.
+	HealthcheckMaxAge int
+}
+
+// optionsFromEnv reads the settings from the environment.
+func optionsFromEnv() options {
+	o := options{
+		Host:              os.Getenv("UNIFI_HOST"),
+		APIKey:            os.Getenv("UNIFI_API_KEY"),
+		Site:              "default",
+		ConfigPath:        "/app/clients.json",
+		CheckInterval:     3600,
+		Concurrency:       4,
+		RateBurst:         5,
+		VerifySSL:         true,
+		RunOnStart:        true,
+		StatusFile:        os.Getenv("STATUS_FILE"),
+		StateDumpFile:     os.Getenv("STATE_DUMP_FILE"),
+		RunAt:             os.Getenv("RUN_AT"),
+		Timezone:          os.Getenv("TZ"),
+		LogFile:           os.Getenv("LOG_FILE"),
+		HealthcheckURL:    os.Getenv("HEALTHCHECK_URL"),
+		UptimeKumaURL:     os.Getenv("UPTIME_KUMA_PUSH_URL"),
+		PushgatewayURL:    os.Getenv("PUSHGATEWAY_URL"),
+		PushgatewayJob:    cmp.Or(os.Getenv("PUSHGATEWAY_JOB"), defaultPushgatewayJob),
+		InfluxURL:         os.Getenv("INFLUX_URL"),
+		InfluxToken:       os.Getenv("INFLUX_TOKEN"),
+		InfluxOrg:         os.Getenv("INFLUX_ORG"),
+		InfluxBucket:      os.Getenv("INFLUX_BUCKET"),
+		StatsDAddr:        os.Getenv("STATSD_ADDR"),
+		StatsDPrefix:      cmp.Or(os.Getenv("STATSD_PREFIX"), "unifi_ipv6"),
+		OTLPEndpoint:      os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		OTLPHeaders:       os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"),
+		OTLPService:       cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), "unifi-ipv6-client-firewall-updater"),
+		SentryDSN:         os.Getenv("SENTRY_DSN"),
+		SentryEnvironment: os.Getenv("SENTRY_ENVIRONMENT"),
+		AdminAddr:         os.Getenv("ADMIN_ADDR"),
+		AdminToken:        os.Getenv("ADMIN_TOKEN"),
+		GRPCAddr:          os.Getenv("GRPC_ADDR"),
+		ListenAddr:        os.Getenv("LISTEN_ADDR"),
+		NeighborInterface: os.Getenv("NEIGHBOR_INTERFACE"),
+		SSHHost:           os.Getenv("SSH_HOST"),
+		SSHUser:           os.Getenv("SSH_USER"),
+		SSHPassword:       os.Getenv("SSH_PASSWORD"),
+		SSHKeyFile:        os.Getenv("SSH_KEY_FILE"),
+		SSHKnownHosts:     os.Getenv("SSH_KNOWN_HOSTS"),
+		LeaderElection:    os.Getenv("LEADER_ELECTION"),
+		LeaderID:          os.Getenv("LEADER_ID"),
+		LeaderName:        "unifi-ipv6-client-firewall-updater",
+		LeaderNamespace:   os.Getenv("LEADER_NAMESPACE"),
+		RedisAddr:         os.Getenv("REDIS_ADDR"),
+		RedisPassword:     os.Getenv("REDIS_PASSWORD"),
+
+		WatchNamespace: os.Getenv("WATCH_NAMESPACE"),
+
+		BackupDir:  os.Getenv("BACKUP_DIR"),
+		BackupKeep: backup.DefaultKeep,
+
+		Username:   os.Getenv("UNIFI_USERNAME"),
+		Password:   os.Getenv("UNIFI_PASSWORD"),
+		TOTPSecret: os.Getenv("UNIFI_TOTP_SECRET"),
+
+		ConsoleID:   os.Getenv("UNIFI_CONSOLE_ID"),
+		HistoryFile: os.Getenv("HISTORY_FILE"),
+		RecordFile:  os.Getenv("RECORD_FILE"),
+		ReplayFile:  os.Getenv("REPLAY_FILE"),
+
+		AddressPreference: updater.PreferFirst,
+		AllowULA:          true,
+		DriftPolicy:       updater.DriftAlert,
+		VerifyReachable:   os.Getenv("VERIFY_REACHABLE"),
+
+		AddressPollInterval: 10,
+		PrefixCheckInterval: 60,
+
+		LogMaxSize:    10,
+		LogMaxBackups: 5,
+
+		MockAddr: "127.0.0.1:8443",
+	}
+	if v := os.Getenv("LEADER_NAME"); v != "" {
+		o.LeaderName = v
+	}
+	if v := os.Getenv("UNIFI_SITE"); v != "" {
+		o.Site = v
+	}
+	if v := os.Getenv("CONFIG_PATH"); v != "" {
+		o.ConfigPath = v
+	}
+	if v := os.Getenv("VERIFY_SSL"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.VerifySSL = parsed
+		}
+	}
+	if v := os.Getenv("RUN_ONCE"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.RunOnce = parsed
+		}
+	}
+	if v := os.Getenv("STARTUP_DELAY"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			o.StartupDelay = seconds
+		}
+	}
+	if v := os.Getenv("RUN_ON_START"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.RunOnStart = parsed
+		}
+	}
+	if v := os.Getenv("WATCH_EVENTS"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.WatchEvents = parsed
+		}
+	}
+	if v := os.Getenv("LOG_MAX_SIZE"); v != "" {
+		if mb, err := strconv.Atoi(v); err == nil && mb >= 0 {
+			o.LogMaxSize = mb
+		}
+	}
+	if v := os.Getenv("LOG_MAX_BACKUPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			o.LogMaxBackups = n
+		}
+	}
+	if v := os.Getenv("LOG_MAX_AGE"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			o.LogMaxAge = days
+		}
+	}
+	if v := os.Getenv("DOGSTATSD"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.DogStatsD = parsed
+		}
+	}
+	if v := os.Getenv("WATCH_PREFIX"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.WatchPrefix = parsed
+		}
+	}
+	if v := os.Getenv("PREFIX_CHECK_INTERVAL"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			o.PrefixCheckInterval = seconds
+		}
+	}
+	if v := os.Getenv("RESOLVE_HOSTNAMES"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.ResolveHostnames = parsed
+		}
+	}
+	if v := os.Getenv("INCLUDE_OFFLINE"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.IncludeOffline = parsed
+		}
+	}
+	if v := os.Getenv("ADDRESS_PREFERENCE"); v != "" {
+		if updater.ValidPreference(v) {
+			o.AddressPreference = v
+		} else {
+			fmt.Println("⚠️  Invalid ADDRESS_PREFERENCE, using first")
+		}
+	}
+	if v := os.Getenv("DRIFT_POLICY"); v != "" {
+		if updater.ValidDriftPolicy(v) {
+			o.DriftPolicy = v
+		} else {
+			fmt.Println("⚠️  Invalid DRIFT_POLICY, using alert")
+		}
+	}
+	if v := os.Getenv("MAX_ADDRESSES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			o.MaxAddresses = n
+		}
+	}
+	if v := os.Getenv("ALLOW_ULA"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.AllowULA = parsed
+		}
+	}
+	if v := os.Getenv("LOCAL_NEIGHBORS"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			o.LocalNeighbors = parsed
+		}
+	}
+	if v := os.Getenv("CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			o.Concurrency = n
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT"); v != "" {
+		if rps, err := strconv.ParseFloat(v, 64); err == nil && rps >= 0 {
+			o.RateLimit = rps
+		}
+	}
+	if v := os.Getenv("RATE_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			o.RateBurst = n
+		}
+	}
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			o.BackupKeep = n
+		}
+	}
+	if v := os.Getenv("HEALTHCHECK_MAX_AGE"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			o.HealthcheckMaxAge = seconds
+		}
+	}
+	if v := os.Getenv("MAX_GROUP_MEMBERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			o.MaxGroupMembers = n
+		}
+	}
+	if v := os.Getenv("ADDRESS_OVERLAP"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			o.AddressOverlap = seconds
+		}
+	}
+	if v := os.Getenv("ADDRESS_POLL_INTERVAL"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			o.AddressPollInterval = seconds
+		}
+	}
+	// Interval in seconds (default 3600 = 1h)
+	if v := os.Getenv("CHECK_INTERVAL"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			o.CheckInterval = seconds
+		} else {
+			fmt.Println("⚠️  Invalid CHECK_INTERVAL, using default 1h")
+		}
+	}
+	return o
+}
+
+// flagSet returns a flag set for the named command with a flag for every
+// setting, defaulting to the values already in o.
+func (o *options) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&o.Host, "host", o.Host, "URL of the UniFi controller (UNIFI_HOST)")
+	fs.Var(secret{&o.APIKey}, "api-key", "API `key` for the UniFi controller, or the current key and the next separated by a comma (UNIFI_API_KEY)")
+	fs.StringVar(&o.Username, "username", o.Username, "`user` to log in to the console as, in place of an API key (UNIFI_USERNAME)")
+	fs.Var(secret{&o.Password}, "password", "`password` of the console user (UNIFI_PASSWORD)")
+	fs.Var(secret{&o.TOTPSecret}, "totp-secret", "base32 `secret` generating the console user's 2FA codes (UNIFI_TOTP_SECRET)")
+	fs.StringVar(&o.ConsoleID, "console-id", o.ConsoleID, "`ID` of the console to reach through the Site Manager in place of --host, with a Site Manager API key (UNIFI_CONSOLE_ID)")
+	fs.StringVar(&o.Site, "site", o.Site, "controller site of clients that don't name one (UNIFI_SITE)")
+	fs.StringVar(&o.ConfigPath, "config", o.ConfigPath, "path to the configuration file (CONFIG_PATH)")
+	fs.IntVar(&o.CheckInterval, "check-interval", o.CheckInterval, "seconds between checks (CHECK_INTERVAL)")
+	fs.StringVar(&o.RunAt, "run-at", o.RunAt, "run at these times of day instead of every interval, e.g. 06:00,18:00 (RUN_AT)")
+	fs.StringVar(&o.Timezone, "timezone", o.Timezone, "time zone of --run-at, e.g. Europe/London (TZ)")
+	fs.BoolVar(&o.VerifySSL, "verify-ssl", o.VerifySSL, "verify the controller's TLS certificate (VERIFY_SSL)")
+	fs.BoolVar(&o.RunOnce, "run-once", o.RunOnce, "run a single cycle and exit (RUN_ONCE)")
+	fs.IntVar(&o.StartupDelay, "startup-delay", o.StartupDelay, "seconds to wait at startup before contacting the controller (STARTUP_DELAY)")
+	fs.BoolVar(&o.RunOnStart, "run-on-start", o.RunOnStart, "run a cycle at startup rather than waiting for the first scheduled one (RUN_ON_START)")
+	fs.BoolVar(&o.WatchEvents, "watch-events", o.WatchEvents, "also run a cycle when the controller reports a tracked client connecting (WATCH_EVENTS)")
+	fs.BoolVar(&o.WatchPrefix, "watch-prefix", o.WatchPrefix, "rewrite all entries as soon as the gateway's WAN prefix changes (WATCH_PREFIX)")
+	fs.IntVar(&o.PrefixCheckInterval, "prefix-check-interval", o.PrefixCheckInterval, "seconds between checks of the WAN prefix (PREFIX_CHECK_INTERVAL)")
+	fs.BoolVar(&o.IncludeOffline, "include-offline", o.IncludeOffline, "use the last known addresses of offline clients instead of reporting them not found (INCLUDE_OFFLINE)")
+	fs.StringVar(&o.AddressPreference, "address-preference", o.AddressPreference, "which of a client's addresses to publish: first, stable, temporary or all (ADDRESS_PREFERENCE)")
+	fs.IntVar(&o.MaxAddresses, "max-addresses", o.MaxAddresses, "most addresses published per client with the all preference, 0 for no limit (MAX_ADDRESSES)")
+	fs.BoolVar(&o.AllowULA, "allow-ula", o.AllowULA, "also publish unique local addresses (fc00::/7) (ALLOW_ULA)")
+	fs.BoolVar(&o.ResolveHostnames, "resolve-hostnames", o.ResolveHostnames, "look up the names new addresses resolve back to, for logs, notifications and the status (RESOLVE_HOSTNAMES)")
+	fs.StringVar(&o.VerifyReachable, "verify-reachable", o.VerifyReachable, "check clients' new addresses before publishing them: ping, tcp:<port> or off (VERIFY_REACHABLE)")
+	fs.IntVar(&o.MaxGroupMembers, "max-group-members", o.MaxGroupMembers, "most members of a firewall group, evicting those ranked last over it, 0 for no limit (MAX_GROUP_MEMBERS)")
+	fs.IntVar(&o.AddressOverlap, "address-overlap", o.AddressOverlap, "seconds a replaced address stays published alongside the new one (ADDRESS_OVERLAP)")
+	fs.StringVar(&o.DriftPolicy, "drift-policy", o.DriftPolicy, "what to do about firewall groups changed outside the updater: alert, repair or respect (DRIFT_POLICY)")
+	fs.IntVar(&o.Concurrency, "concurrency", o.Concurrency, "number of clients reconciled in parallel (CONCURRENCY)")
+	fs.Float64Var(&o.RateLimit, "rate-limit", o.RateLimit, "maximum controller API calls per second, 0 for no limit (RATE_LIMIT)")
+	fs.IntVar(&o.RateBurst, "rate-burst", o.RateBurst, "controller API calls allowed in a burst above the rate limit (RATE_BURST)")
+	if name == "agent" {
+		fs.IntVar(&o.AddressPollInterval, "address-poll-interval", o.AddressPollInterval, "seconds between reads of the local addresses (ADDRESS_POLL_INTERVAL)")
+	}
+	if name == "healthcheck" {
+		fs.IntVar(&o.HealthcheckMaxAge, "max-age", o.HealthcheckMaxAge, "seconds since the last cycle after which it fails, default twice the check interval (HEALTHCHECK_MAX_AGE)")
+	}
+	if name == "cleanup" {
+		fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "only print the members that would be removed")
+	}
+	if name == "restore" {
+		fs.StringVar(&o.RestoreGroup, "group", o.RestoreGroup, "`ID` of the firewall group to list or restore the snapshots of")
+		fs.StringVar(&o.RestoreSnapshot, "snapshot", o.RestoreSnapshot, "snapshot to restore: its `name`, latest, or the path of a snapshot file")
+		fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "only print what would be restored")
+	}
+	if name == "history" {
+		fs.StringVar(&o.HistoryClient, "client", o.HistoryClient, "only show changes to the client with this MAC or name")
+		fs.StringVar(&o.HistorySince, "since", o.HistorySince, "only show changes since this `time`: RFC 3339, a date, or a duration ago such as 24h")
+		fs.StringVar(&o.HistoryUntil, "until", o.HistoryUntil, "only show changes before this `time`, given like --since")
+		fs.StringVar(&o.HistoryFormat, "format", "table", "output `format`: table, or csv or json to export the changes")
+	}
+	if name == "migrate" {
+		fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "only print what would be changed")
+	}
+	if name == "report" {
+		fs.StringVar(&o.ReportFormat, "format", "table", "output `format`: table, or csv or json for monitoring tools")
+	}
+	if name == "lint" {
+		fs.StringVar(&o.LintFormat, "format", "text", "output `format`: text, or json for editors and CI")
+		fs.BoolVar(&o.LintStrict, "strict", o.LintStrict, "fail on warnings too, not only errors")
+	}
+	if name == "mock" {
+		fs.StringVar(&o.MockAddr, "listen", o.MockAddr, "listen `address` of the mock controller")
+		fs.StringVar(&o.MockState, "state", o.MockState, "JSON `file` with the clients and firewall groups to serve, default made up from the config file")
+	}
+	if name == "operator" {
+		fs.StringVar(&o.WatchNamespace, "watch-namespace", o.WatchNamespace, "namespace of the ClientFirewallEntry resources, default the pod's (WATCH_NAMESPACE)")
+	}
+	fs.BoolVar(&o.LocalNeighbors, "local-neighbors", o.LocalNeighbors, "look clients the controller has no address for up in this machine's IPv6 neighbour cache (LOCAL_NEIGHBORS)")
+	fs.StringVar(&o.NeighborInterface, "neighbor-interface", o.NeighborInterface, "only use neighbours on this interface (NEIGHBOR_INTERFACE)")
+	fs.StringVar(&o.SSHHost, "ssh-host", o.SSHHost, "gateway to read the IPv6 neighbour table from over SSH when the controller has no address for a client (SSH_HOST)")
+	fs.StringVar(&o.SSHUser, "ssh-user", o.SSHUser, "SSH user on the gateway, default root (SSH_USER)")
+	fs.Var(secret{&o.SSHPassword}, "ssh-password", "SSH `password` for the gateway (SSH_PASSWORD)")
+	fs.StringVar(&o.SSHKeyFile, "ssh-key-file", o.SSHKeyFile, "SSH private key file for the gateway (SSH_KEY_FILE)")
+	fs.StringVar(&o.SSHKnownHosts, "ssh-known-hosts", o.SSHKnownHosts, "known_hosts file to verify the gateway's host key with (SSH_KNOWN_HOSTS)")
+	fs.StringVar(&o.LeaderElection, "leader-election", o.LeaderElection, "elect one of several replicas to run cycles: kubernetes or redis (LEADER_ELECTION)")
+	fs.StringVar(&o.LeaderID, "leader-id", o.LeaderID, "this replica's identity in the election, default the hostname (LEADER_ID)")
+	fs.StringVar(&o.LeaderName, "leader-name", o.LeaderName, "name of the Lease or Redis key used for the election (LEADER_NAME)")
+	fs.StringVar(&o.LeaderNamespace, "leader-namespace", o.LeaderNamespace, "namespace of the Lease, default the pod's (LEADER_NAMESPACE)")
+	fs.StringVar(&o.RedisAddr, "redis-addr", o.RedisAddr, "Redis server for the election, host[:port] (REDIS_ADDR)")
+	fs.Var(secret{&o.RedisPassword}, "redis-password", "Redis `password` (REDIS_PASSWORD)")
+	fs.StringVar(&o.BackupDir, "backup-dir", o.BackupDir, "directory to snapshot firewall groups to before each change (BACKUP_DIR)")
+	fs.IntVar(&o.BackupKeep, "backup-keep", o.BackupKeep, "snapshots kept per firewall group (BACKUP_KEEP)")
+	fs.StringVar(&o.HistoryFile, "history-file", o.HistoryFile, "file recording every change made, for the history command (HISTORY_FILE)")
+	fs.StringVar(&o.RecordFile, "record", o.RecordFile, "`file` to record every controller API request and response to, with the API keys redacted (RECORD_FILE)")
+	fs.StringVar(&o.ReplayFile, "replay", o.ReplayFile, "answer controller API requests from a `file` recorded with --record instead of the controllers (REPLAY_FILE)")
+	fs.StringVar(&o.StatusFile, "status-file", o.StatusFile, "path of the JSON status file (STATUS_FILE)")
+	fs.StringVar(&o.StateDumpFile, "state-dump-file", o.StateDumpFile, "file to write the in-memory state to on SIGUSR2 instead of the log (STATE_DUMP_FILE)")
+	fs.StringVar(&o.LogFile, "log-file", o.LogFile, "file to write the log to as well as the console (LOG_FILE)")
+	fs.IntVar(&o.LogMaxSize, "log-max-size", o.LogMaxSize, "size in MB at which the log file is rotated, 0 for never (LOG_MAX_SIZE)")
+	fs.IntVar(&o.LogMaxBackups, "log-max-backups", o.LogMaxBackups, "rotated log files kept (LOG_MAX_BACKUPS)")
+	fs.IntVar(&o.LogMaxAge, "log-max-age", o.LogMaxAge, "days rotated log files are kept, 0 for no limit (LOG_MAX_AGE)")
+	fs.StringVar(&o.HealthcheckURL, "healthcheck-url", o.HealthcheckURL, "healthchecks.io ping URL (HEALTHCHECK_URL)")
+	fs.StringVar(&o.UptimeKumaURL, "uptime-kuma-push-url", o.UptimeKumaURL, "Uptime Kuma push monitor URL (UPTIME_KUMA_PUSH_URL)")
+	fs.StringVar(&o.PushgatewayURL, "pushgateway-url", o.PushgatewayURL, "Prometheus Pushgateway `URL` to push the metrics of --run-once runs to (PUSHGATEWAY_URL)")
+	fs.StringVar(&o.PushgatewayJob, "pushgateway-job", o.PushgatewayJob, "job label of the metrics pushed to the Pushgateway (PUSHGATEWAY_JOB)")
+	fs.StringVar(&o.InfluxURL, "influx-url", o.InfluxURL, "InfluxDB 2 `URL` to write the outcome of every cycle to (INFLUX_URL)")
+	fs.Var(secret{&o.InfluxToken}, "influx-token", "InfluxDB API `token` (INFLUX_TOKEN)")
+	fs.StringVar(&o.InfluxOrg, "influx-org", o.InfluxOrg, "InfluxDB organization (INFLUX_ORG)")
+	fs.StringVar(&o.InfluxBucket, "influx-bucket", o.InfluxBucket, "InfluxDB bucket (INFLUX_BUCKET)")
+	fs.StringVar(&o.StatsDAddr, "statsd-addr", o.StatsDAddr, "StatsD `host:port` to send the counters and timings of every cycle to over UDP (STATSD_ADDR)")
+	fs.StringVar(&o.StatsDPrefix, "statsd-prefix", o.StatsDPrefix, "prefix of the StatsD metric names (STATSD_PREFIX)")
+	fs.BoolVar(&o.DogStatsD, "dogstatsd", o.DogStatsD, "tag StatsD metrics the DogStatsD way (DOGSTATSD)")
+	fs.StringVar(&o.OTLPEndpoint, "otlp-endpoint", o.OTLPEndpoint, "OpenTelemetry collector `URL` to export metrics to over OTLP/HTTP (OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.Var(secret{&o.OTLPHeaders}, "otlp-headers", "`key=value` pairs, separated by commas, sent with every OTLP export (OTEL_EXPORTER_OTLP_HEADERS)")
+	fs.StringVar(&o.OTLPService, "otel-service-name", o.OTLPService, "service.name of the exported metrics (OTEL_SERVICE_NAME)")
+	fs.Var(secret{&o.SentryDSN}, "sentry-dsn", "Sentry `DSN` for error reporting (SENTRY_DSN)")
+	fs.StringVar(&o.SentryEnvironment, "sentry-environment", o.SentryEnvironment, "Sentry environment name (SENTRY_ENVIRONMENT)")
+	fs.StringVar(&o.AdminAddr, "admin-addr", o.AdminAddr, "listen address of the admin web UI, e.g. :8080 (ADMIN_ADDR)")
+	fs.Var(secret{&o.AdminToken}, "admin-token", "bearer `token` required by the admin and gRPC APIs (ADMIN_TOKEN)")
+	fs.StringVar(&o.GRPCAddr, "grpc-addr", o.GRPCAddr, "listen address of the gRPC control API, e.g. :9090 (GRPC_ADDR)")
+	fs.StringVar(&o.ListenAddr, "listen-addr", o.ListenAddr, "listen address for addresses pushed by clients, e.g. :8245 (LISTEN_ADDR)")
+	return fs
+}
+
+// interval returns the check interval, falling back to 1h if the flag
+// value is invalid.
+func (o *options) interval() time.Duration {
+	if o.CheckInterval <= 0 {
+		fmt.Println("⚠️  Invalid check interval, using default 1h")
+		return time.Hour
+	}
+	return time.Duration(o.CheckInterval) * time.Second
+}
+
+// startupDelay waits STARTUP_DELAY seconds, for the controller and network
+// to come up when the updater starts along with them, extending systemd's
+// start timeout to cover it.
+func (o *options) startupDelay() {
+	if o.StartupDelay <= 0 {
+		return
+	}
+	delay := time.Duration(o.StartupDelay) * time.Second
+	fmt.Printf("⏳ Waiting %v for the network to come up\n", delay)
+	extendStart(delay)
+	time.Sleep(delay)
+}
+
+// requireController exits with a configuration error unless the controller
+// host and API key or login are set, looking for the controller on the
+// local network when only the host isn't. When replaying they needn't be.
+func (o *options) requireController() {
+	if o.ReplayFile != "" {
+		o.Host = cmp.Or(o.Host, "http://replay.invalid")
+		o.APIKey = cmp.Or(o.APIKey, redactedKey)
+	}
+	if o.Host == "" && o.hasCredentials() {
+		o.discoverHost()
+	}
+	if o.Host == "" || !o.hasCredentials() {
+		fmt.Println("❌ UNIFI_HOST or UNIFI_CONSOLE_ID, and UNIFI_API_KEY or UNIFI_USERNAME and UNIFI_PASSWORD, are required")
+		exit(exitConfig)
+	}
+}
+
+// hasCredentials reports whether an API key or a login is set for the
+// controller.
+func (o *options) hasCredentials() bool {
+	return o.APIKey != "" || (o.Username != "" && o.Password != "")
+}
+
+// login makes c log in with Username and Password, if set, instead of the
+// API key. The 2FA code comes from TOTPSecret or, without one, is asked for
+// on the terminal.
+func (o *options) login(c *unifi.Client) {
+	if o.Username == "" || o.ReplayFile != "" {
+		return
+	}
+	code := promptCode
+	if o.TOTPSecret != "" {
+		var err error
+		if code, err = unifi.TOTPCode(o.TOTPSecret); err != nil {
+			fmt.Println("❌ UNIFI_TOTP_SECRET:", err)
+			exit(exitConfig)
+		}
+	}
+	c.SetLogin(o.Username, o.Password, code)
+}
+
+// promptCode asks for a 2FA code on the terminal. Without one, e.g. when
+// running as a service, it fails, as the code would be needed again each
+// time the session expires.
+func promptCode() (string, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return "", fmt.Errorf("%w: set UNIFI_TOTP_SECRET", unifi.ErrMFARequired)
+	}
+	fmt.Print("🔑 2FA code: ")
+	var code string
+	if _, err := fmt.Scanln(&code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// controller returns an API client for the configured controller.
+func (o *options) controller() *unifi.Client {
+	c := unifi.New(o.Host, o.APIKey, o.VerifySSL)
+	c.UserAgent = userAgent()
+	c.Failover = logFailover
+	c.KeyRotated = logKeyRotated
+	if o.Site != "" {
+		c.Site = o.Site
+	}
+	o.login(c)
+	o.snapshots(c)
+	o.transport(c, o.APIKey)
+	c.SetRateLimit(o.RateLimit, o.RateBurst)
+	return c
+}
+
+// redactedKey stands in for the API key when replaying.
+const redactedKey = "REDACTED"
+
+// transport makes c record its exchanges to RecordFile, redacting the
+// secrets and its API key, or answer them from ReplayFile, if set.
+func (o *options) transport(c *unifi.Client, apiKey string) {
+	if o.ReplayFile != "" {
+		if o.replayer == nil {
+			r, err := unifi.LoadReplayer(o.ReplayFile)
+			if err != nil {
+				fmt.Println("❌ Failed to load recording:", err)
+				exit(exitConfig)
+			}
+			o.replayer = r
+		}
+		c.WrapTransport(func(http.RoundTripper) http.RoundTripper { return o.replayer })
+		return
+	}
+	if o.RecordFile != "" {
+		if o.recording == nil {
+			o.recording = &unifi.Recording{Path: o.RecordFile}
+		}
+		c.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+			secrets := append(unifi.SplitKeys(apiKey), unifi.SplitKeys(o.APIKey)...)
+			return o.recording.Transport(next, append(secrets, o.Password, o.TOTPSecret, o.SSHPassword, o.AdminToken)...)
+		})
+	}
+}
+
+// snapshots makes c snapshot firewall groups to BackupDir before changing
+// them, if set.
+func (o *options) snapshots(c *unifi.Client) {
+	if o.BackupDir != "" {
+		c.Snapshot = backup.Dir{Path: o.BackupDir, Keep: o.BackupKeep}.Save
+	}
+}
+
+// history returns the change history, or nil when HistoryFile is unset.
+func (o *options) history() *history.File {
+	if o.HistoryFile == "" {
+		return nil
+	}
+	return &history.File{Path: o.HistoryFile}
+}
+
+// siteController returns an API client for site of the config's
+// controller cc, or of UNIFI_HOST when cc is nil. An empty site is the
+// controller's default one.
+func (o *options) siteController(cc *updater.ControllerConfig, site string) (*unifi.Client, error) {
+	if site == "" {
+		site = "default"
+	}
+	if cc == nil {
+		c := o.controller()
+		c.Site = site
+		return c, nil
+	}
+	if cc.URL() == "" || cc.APIKey == "" {
+		return nil, fmt.Errorf("controller %q needs a host or console_id, and an api_key", cc.Name)
+	}
+	c := unifi.New(cc.URL(), cc.APIKey, !cc.Insecure)
+	c.UserAgent = userAgent()
+	c.Failover = logFailover
+	c.KeyRotated = logKeyRotated
+	c.Site = site
+	c.SetRateLimit(o.RateLimit, o.RateBurst)
+	o.snapshots(c)
+	o.transport(c, cc.APIKey)
+	return c, nil
+}
+
+// clientController returns an API client for the site and controller
+// the client is on.
+func (o *options) clientController(cfg *updater.Config, c updater.ClientConfig) (*unifi.Client, error) {
+	if c.Controller == "" && c.Site == "" {
+		return o.controller(), nil
+	}
+	var cc *updater.ControllerConfig
+	if c.Controller != "" {
+		i := slices.IndexFunc(cfg.Controllers, func(cc updater.ControllerConfig) bool { return cc.Name == c.Controller })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown controller %q", c.Controller)
+		}
+		cc = &cfg.Controllers[i]
+	}
+	return o.siteController(cc, c.Site)
+}
+
+// logKeyRotated logs a client moving to its next API key, by position so
+// the keys stay out of the logs.
+func logKeyRotated(from, to int) {
+	fmt.Printf("🔑 Controller rejected API key %d, switching to key %d; drop the rejected key from the settings once every updater has switched\n", from+1, to+1)
+}
+
+// logFailover reports a controller's requests moving to another of its
+// URLs.
+func logFailover(from, to string, err error) {
+	if err == nil {
+		fmt.Printf("🔄 Controller reachable at %s again, switching back from %s\n", to, from)
+		return
+	}
+	fmt.Printf("🔄 Controller unreachable at %s (%v), failing over to %s\n", from, err, to)
+}
+
+// secret is a string flag whose value is kept out of the help output.
+type secret struct{ p *string }
+
+func (s secret) String() string { return "" }
+
+func (s secret) Set(v string) error {
+	*s.p = v
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// durationBounds are the upper bounds, in seconds, of the buckets of the
+// cycle duration histogram.
+var durationBounds = []float64{0.5, 1, 2, 5, 10, 30, 60, 120}
+
+// otlp exports cumulative metrics to an OpenTelemetry collector over
+// OTLP/HTTP with JSON encoding after every cycle: the runs by whether they
+// succeeded, a histogram of their durations, and the address changes.
+type otlp struct {
+	url     string
+	headers map[string]string
+	service string
+
+	mu        sync.Mutex
+	start     time.Time
+	runs      map[bool]int64
+	changes   int64
+	durations []int64 // per bucket of durationBounds, and one above them
+	count     int64
+	sum       float64
+}
+
+// newOTLP returns an exporter to the collector at endpoint, the base URL
+// /v1/metrics is appended to, sending headers with every export, given as
+// key=value pairs separated by commas.
+func newOTLP(endpoint, headers, service string) *otlp {
+	o := &otlp{
+		url:       strings.TrimRight(endpoint, "/") + "/v1/metrics",
+		headers:   map[string]string{},
+		service:   service,
+		start:     time.Now(),
+		runs:      map[bool]int64{},
+		durations: make([]int64, len(durationBounds)+1),
+	}
+	for h := range strings.SplitSeq(headers, ",") {
+		if k, v, ok := strings.Cut(h, "="); ok {
+			o.headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return o
+}
+
+func (o *otlp) report(st updater.Status) error {
+	body, err := json.Marshal(o.record(st))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// record adds the cycle st to the totals and returns them as an OTLP
+// export request.
+func (o *otlp) record(st updater.Status) map[string]any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	seconds := float64(st.DurationMS) / 1000
+	o.runs[st.Success]++
+	o.changes += int64(len(changedClients(st)))
+	o.durations[bucket(durationBounds, seconds)]++
+	o.count++
+	o.sum += seconds
+
+	start, now := nanos(o.start), nanos(time.Now())
+	point := func(attrs []any, n int64) map[string]any {
+		return map[string]any{"attributes": attrs, "startTimeUnixNano": start, "timeUnixNano": now, "asInt": strconv.FormatInt(n, 10)}
+	}
+	sum := func(name, desc string, points ...map[string]any) map[string]any {
+		return map[string]any{"name": name, "description": desc, "unit": "1",
+			"sum": map[string]any{"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": points}}
+	}
+	buckets := make([]string, len(o.durations))
+	for i, n := range o.durations {
+		buckets[i] = strconv.FormatInt(n, 10)
+	}
+
+	metrics := []any{
+		sum("unifi_ipv6.runs", "Cycles run, by whether they succeeded.",
+			point([]any{attribute("success", "true")}, o.runs[true]),
+			point([]any{attribute("success", "false")}, o.runs[false])),
+		map[string]any{"name": "unifi_ipv6.run.duration", "description": "How long cycles took.", "unit": "s",
+			"histogram": map[string]any{"aggregationTemporality": 2, "dataPoints": []any{map[string]any{
+				"startTimeUnixNano": start, "timeUnixNano": now,
+				"count": strconv.FormatInt(o.count, 10), "sum": o.sum,
+				"bucketCounts": buckets, "explicitBounds": durationBounds,
+			}}}},
+		sum("unifi_ipv6.address_changes", "Clients whose published address changed.", point([]any{}, o.changes)),
+	}
+	return map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource": map[string]any{"attributes": []any{attribute("service.name", o.service)}},
+		"scopeMetrics": []any{map[string]any{
+			"scope":   map[string]any{"name": "github.com/brendann993/unifi-ipv6-client-firewall-updater"},
+			"metrics": metrics,
+		}},
+	}}}
+}
+
+// bucket returns the bucket of bounds that v falls into.
+func bucket(bounds []float64, v float64) int {
+	i, _ := slices.BinarySearch(bounds, v)
+	return i
+}
+
+// attribute returns an OTLP string attribute.
+func attribute(key, value string) map[string]any {
+	return map[string]any{"key": key, "value": map[string]any{"stringValue": value}}
+}
+
+// nanos returns t in Unix nanoseconds, as OTLP's JSON encoding wants them.
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
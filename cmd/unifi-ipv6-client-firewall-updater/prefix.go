@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// prefixFollowUps are the delays, after a WAN prefix change, of the cycles
+// that pick up clients as they move to addresses in the new prefix.
+var prefixFollowUps = []time.Duration{30 * time.Second, 2 * time.Minute, 5 * time.Minute}
+
+// watchPrefix polls the gateway's WAN addresses and, when they change,
+// rewrites every tracked entry straight away rather than at the next
+// scheduled cycle: a cycle runs immediately and again shortly after, while
+// clients renumber into the new prefix.
+func (d *daemon) watchPrefix(every time.Duration) {
+	var last []string
+	for {
+		addrs, err := d.ctrl.WANAddresses()
+		switch {
+		case err != nil:
+			fmt.Println("⚠️  Failed to read WAN addresses:", err)
+		case len(addrs) == 0:
+			// no IPv6 on the WAN right now, e.g. while the gateway
+			// reconnects; keep comparing against the last known prefix
+		case last == nil:
+			last = addrs
+		case !slices.Equal(addrs, last):
+			fmt.Printf("🌐 WAN prefix changed: %s → %s, rewriting all entries\n", strings.Join(last, ", "), strings.Join(addrs, ", "))
+			last = addrs
+			d.requestRun()
+			for _, delay := range prefixFollowUps {
+				time.AfterFunc(delay, d.requestRun)
+			}
+		}
+		time.Sleep(every)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// preflightTimeout bounds the startup check, after which the updater starts
+// anyway and leaves the controller to its cycles.
+const preflightTimeout = 30 * time.Second
+
+// checkController makes lightweight authenticated calls before the first
+// cycle, reading the firewall groups and checking that the credentials may
+// change them, and explains what went wrong, returning the exit code to
+// stop with or exitOK to carry on. A wrong host, an untrusted certificate or a
+// rejected API key won't fix themselves, so they stop the updater; the
+// controller being unreachable is only a warning, since it may still be
+// starting, e.g. after a power cut.
+func checkController(o *options, ctrl *unifi.Client) int {
+	for _, host := range unifi.SplitHosts(o.Host) {
+		if u, err := url.Parse(host); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fmt.Printf("❌ UNIFI_HOST %q is not a URL like https://192.168.1.1\n", host)
+			return exitConfig
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ctrl.FirewallGroups()
+		if err == nil {
+			err = ctrl.CanWrite()
+		}
+		done <- err
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(preflightTimeout):
+		fmt.Printf("⚠️  Controller %s did not answer within %v, carrying on\n", o.Host, preflightTimeout)
+		return exitOK
+	}
+	if err == nil {
+		fmt.Printf("✅ Controller %s accepted the credentials\n", ctrl.Host())
+		return exitOK
+	}
+
+	msg, code := explainControllerError(o.Host, err)
+	if code == exitOK {
+		fmt.Println("⚠️ ", msg+", carrying on")
+		return exitOK
+	}
+	fmt.Println("❌", msg)
+	return code
+}
+
+// explainControllerError describes a failed controller call in terms of
+// what to fix, with the exit code it warrants, or exitOK if retrying later
+// may succeed.
+func explainControllerError(host string, err error) (string, int) {
+	switch {
+	case errors.Is(err, unifi.ErrReadOnly):
+		return readOnlyMessage(host, err), exitAuth
+	case errors.Is(err, unifi.ErrMFARequired):
+		return fmt.Sprintf("The console user needs a 2FA code to log in to %s: set UNIFI_TOTP_SECRET to the secret shown when 2FA was set up", host), exitAuth
+	case errors.Is(err, unifi.ErrLoginRejected):
+		return fmt.Sprintf("Controller %s rejected the login (%v): check UNIFI_USERNAME, UNIFI_PASSWORD and UNIFI_TOTP_SECRET, and that the clock is right for 2FA codes", host, err), exitAuth
+	}
+
+	var apiErr *unifi.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized:
+			return fmt.Sprintf("Controller %s rejected the API key (HTTP 401): check UNIFI_API_KEY, and that the key was created on this console and not revoked", host), exitAuth
+		case http.StatusForbidden:
+			return readOnlyMessage(host, err), exitAuth
+		case http.StatusNotFound:
+			return fmt.Sprintf("No UniFi Network application found at %s (HTTP 404): UNIFI_HOST should be the console's address, e.g. https://192.168.1.1, without a path", host), exitConfig
+		}
+		if apiErr.StatusCode >= 500 {
+			return fmt.Sprintf("Controller %s returned HTTP %d, it may still be starting", host, apiErr.StatusCode), exitOK
+		}
+		return fmt.Sprintf("Controller %s answered HTTP %d: %s", host, apiErr.StatusCode, apiErr.Body), exitFailure
+	}
+
+	var unknownCA x509.UnknownAuthorityError
+	var badHost x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var notTLS tls.RecordHeaderError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &unknownCA), errors.As(err, &badHost), errors.As(err, &invalid):
+		return fmt.Sprintf("The TLS certificate of %s could not be verified (%v): consoles use a self-signed certificate unless one was installed, so set VERIFY_SSL=false or install a trusted one", host, err), exitConfig
+	case errors.As(err, &notTLS):
+		return fmt.Sprintf("%s does not speak HTTPS on that port: check the scheme and port in UNIFI_HOST", host), exitConfig
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return fmt.Sprintf("The host name in UNIFI_HOST (%s) does not resolve: check it for typos", host), exitConfig
+	}
+	return fmt.Sprintf("Cannot reach controller %s: %v", host, err), exitOK
+}
+
+// readOnlyMessage explains that the credentials may not change the site's
+// settings, be it the controller refusing a call (HTTP 403) or their role.
+func readOnlyMessage(host string, err error) string {
+	return fmt.Sprintf("The API key's role is not allowed to manage the Network application on %s (%v): use a key of an admin allowed to change its settings", host, err)
+}
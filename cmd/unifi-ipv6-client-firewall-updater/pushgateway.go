@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultPushgatewayJob is the job label metrics are pushed under unless
+// PUSHGATEWAY_JOB names another.
+const defaultPushgatewayJob = "unifi-ipv6-client-firewall-updater"
+
+// pushMetrics replaces the metrics of the job on the Pushgateway at
+// gateway with those of writeMetrics, for one-shot runs that exit before
+// anything could scrape them.
+func (d *daemon) pushMetrics(gateway, job string) error {
+	var body bytes.Buffer
+	d.writeMetrics(&body)
+
+	u := strings.TrimRight(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequest(http.MethodPut, u, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", metricsContentType)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
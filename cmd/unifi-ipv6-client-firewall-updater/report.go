@@ -0,0 +1,328 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// tenantReport is what the report says about one controller and the
+// clients tracked on it.
+type tenantReport struct {
+	// Tenant is the controller's name among the config's controllers, or
+	// UNIFI_HOST for the updater's own.
+	Tenant string `json:"tenant"`
+	Host   string `json:"host"`
+	// Error is why the controller couldn't be read, if it couldn't.
+	Error   string         `json:"error,omitempty"`
+	Tracked int            `json:"tracked"`
+	Online  int            `json:"online"`
+	InSync  int            `json:"in_sync"`
+	Failed  int            `json:"failed"`
+	Clients []clientReport `json:"clients"`
+	// LastChange is the latest change of any of its clients' addresses.
+	LastChange time.Time `json:"last_change,omitzero"`
+}
+
+// clientReport is what the report says about one tracked client.
+type clientReport struct {
+	MAC       string   `json:"mac"`
+	Name      string   `json:"name,omitempty"`
+	Site      string   `json:"site"`
+	GroupID   string   `json:"group_id"`
+	Addresses []string `json:"addresses"`
+	Online    bool     `json:"online"`
+	// InSync is whether the client's firewall group has the addresses last
+	// published for it; always true for other targets.
+	InSync      bool      `json:"in_sync"`
+	LastChanged time.Time `json:"last_changed,omitzero"`
+	// Result is the client's result in the last cycle, from the status
+	// file.
+	Result string `json:"result,omitempty"`
+	// Problems are why the client counts as failed.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// cmdReport reads every controller clients are tracked on and prints a
+// report per controller of the clients tracked, their last change and
+// what is failing, for monitoring many sites at once. The last changes
+// and results come from STATUS_FILE and HISTORY_FILE when set. It exits
+// with exitPartial when anything failed, and exitFailure when no
+// controller could be read.
+func cmdReport(o *options) int {
+	switch o.ReportFormat {
+	case "table", "csv", "json":
+	default:
+		fmt.Printf("❌ Unknown format %q, use table, csv or json\n", o.ReportFormat)
+		return exitConfig
+	}
+	cfg, err := updater.LoadConfig(o.ConfigPath)
+	if err != nil {
+		fmt.Println("❌ Failed to load config:", err)
+		return exitConfig
+	}
+
+	// what the updater last did, by client
+	statuses := map[string]updater.ClientStatus{}
+	if o.StatusFile != "" {
+		if st, err := updater.LoadStatus(o.StatusFile); err == nil {
+			for _, c := range st.Clients {
+				statuses[strings.ToLower(c.MAC)+"|"+c.GroupID] = c
+			}
+		} else if !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "⚠️  Failed to read status file:", err)
+		}
+	}
+	changed := map[string]time.Time{}
+	if h := o.history(); h != nil {
+		entries, err := h.Read(history.Query{})
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "⚠️  Failed to read history:", err)
+		}
+		for _, e := range entries {
+			if e.Action == history.ActionChange && e.Result == history.ResultOK {
+				key := strings.ToLower(e.MAC) + "|" + e.Ref
+				changed[key] = later(changed[key], e.Time)
+			}
+		}
+	}
+
+	tenants := o.report(cfg, statuses, changed)
+	var failed, unreachable int
+	for _, t := range tenants {
+		if t.Error != "" {
+			unreachable++
+		}
+		if t.Error != "" || t.Failed > 0 {
+			failed++
+		}
+	}
+
+	switch o.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(tenants)
+	case "csv":
+		err = writeReportCSV(tenants)
+	default:
+		printReport(tenants)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to write report:", err)
+		return exitFailure
+	}
+
+	switch {
+	case len(tenants) > 0 && unreachable == len(tenants):
+		return exitFailure
+	case failed > 0:
+		return exitPartial
+	}
+	return exitOK
+}
+
+// report reads the controllers and sites the config's clients are on and
+// reports on each controller, in the order of the config's controllers
+// after the updater's own.
+func (o *options) report(cfg *updater.Config, statuses map[string]updater.ClientStatus, changed map[string]time.Time) []tenantReport {
+	tenants := []tenantReport{}
+	tenant := func(c updater.ClientConfig) *tenantReport {
+		name, host := c.Controller, ""
+		if i := slices.IndexFunc(cfg.Controllers, func(cc updater.ControllerConfig) bool { return cc.Name == c.Controller }); i >= 0 {
+			host = cfg.Controllers[i].URL()
+		} else if c.Controller == "" {
+			name, host = cmp.Or(o.Host, "UNIFI_HOST"), o.Host
+		}
+		i := slices.IndexFunc(tenants, func(t tenantReport) bool { return t.Tenant == name })
+		if i < 0 {
+			tenants = append(tenants, tenantReport{Tenant: name, Host: host, Clients: []clientReport{}})
+			i = len(tenants) - 1
+		}
+		return &tenants[i]
+	}
+	// the updater's own controller first, then the others in config order
+	for _, c := range cfg.Clients {
+		if c.Controller == "" {
+			tenant(c)
+			break
+		}
+	}
+	for _, cc := range cfg.Controllers {
+		if slices.ContainsFunc(cfg.Clients, func(c updater.ClientConfig) bool { return c.Controller == cc.Name }) {
+			tenant(updater.ClientConfig{Controller: cc.Name})
+		}
+	}
+
+	// what each site has, by controller and site
+	type site struct {
+		stations []unifi.Station
+		groups   []unifi.FirewallGroup
+		err      error
+	}
+	sites := map[string]*site{}
+	for _, c := range cfg.Clients {
+		if !c.IsEnabled() {
+			continue
+		}
+		t := tenant(c)
+		t.Tracked++
+		if t.Error != "" {
+			continue
+		}
+		key := c.Controller + "/" + c.Site
+		s, ok := sites[key]
+		if !ok {
+			s = &site{}
+			sites[key] = s
+			var ctrl *unifi.Client
+			if c.Controller == "" && o.Host == "" {
+				s.err = fmt.Errorf("UNIFI_HOST is not set")
+			} else if ctrl, s.err = o.clientController(cfg, c); s.err == nil {
+				if s.stations, s.err = ctrl.Stations(); s.err == nil {
+					s.groups, s.err = ctrl.FirewallGroups()
+				}
+			}
+			// the controller itself can't be read, not only the site
+			if s.err != nil && (unifi.IsAuthError(s.err) || !isAPIError(s.err)) {
+				t.Error = s.err.Error()
+				continue
+			}
+		}
+
+		r := clientReport{MAC: c.MAC, Name: c.Name, Site: cmp.Or(c.Site, "default"), GroupID: c.GroupID, Addresses: c.Published(), InSync: true}
+		if r.Addresses == nil {
+			r.Addresses = []string{}
+		}
+		key = strings.ToLower(c.MAC) + "|" + c.GroupID
+		if st, ok := statuses[key]; ok {
+			r.Result, r.LastChanged = st.Result, st.LastChanged
+			if st.Error != "" {
+				r.Problems = append(r.Problems, st.Error)
+			}
+		}
+		r.LastChanged = later(r.LastChanged, changed[key])
+		if s.err != nil {
+			r.Problems = append(r.Problems, fmt.Sprintf("site %s: %v", r.Site, s.err))
+		} else {
+			r.Online = slices.ContainsFunc(s.stations, func(st unifi.Station) bool { return strings.EqualFold(st.MAC, c.MAC) })
+			for _, d := range c.Destinations() {
+				if d.Target != updater.DefaultTarget {
+					continue
+				}
+				i := slices.IndexFunc(s.groups, func(g unifi.FirewallGroup) bool { return g.ID == d.Ref })
+				if i < 0 {
+					r.InSync = false
+					r.Problems = append(r.Problems, fmt.Sprintf("firewall group %s does not exist", d.Ref))
+					continue
+				}
+				for _, a := range r.Addresses {
+					if !slices.ContainsFunc(s.groups[i].Members, func(m string) bool { return sameIP(m, a) }) {
+						r.InSync = false
+						r.Problems = append(r.Problems, fmt.Sprintf("firewall group %s (%s) is missing %s", s.groups[i].Name, d.Ref, a))
+					}
+				}
+			}
+		}
+
+		if r.Online {
+			t.Online++
+		}
+		if r.InSync {
+			t.InSync++
+		}
+		if r.Result == updater.ResultFailed || len(r.Problems) > 0 {
+			t.Failed++
+		}
+		t.LastChange = later(t.LastChange, r.LastChanged)
+		t.Clients = append(t.Clients, r)
+	}
+	return tenants
+}
+
+// isAPIError reports whether err is an error response from a controller,
+// which was reached.
+func isAPIError(err error) bool {
+	var apiErr *unifi.APIError
+	return errors.As(err, &apiErr)
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// sameIP reports whether the group member m is the address a.
+func sameIP(m, a string) bool {
+	ip := net.ParseIP(strings.TrimSuffix(m, "/128"))
+	return ip != nil && ip.Equal(net.ParseIP(a)) || m == a
+}
+
+func printReport(tenants []tenantReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tTRACKED\tONLINE\tIN SYNC\tFAILED\tLAST CHANGE")
+	for _, t := range tenants {
+		last := "-"
+		if !t.LastChange.IsZero() {
+			last = t.LastChange.Local().Format(time.DateTime)
+		}
+		if t.Error != "" {
+			fmt.Fprintf(w, "%s\t%d\t-\t-\t-\t%s\n", t.Tenant, t.Tracked, last)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", t.Tenant, t.Tracked, t.Online, t.InSync, t.Failed, last)
+	}
+	w.Flush()
+
+	for _, t := range tenants {
+		if t.Error != "" {
+			fmt.Printf("❌ %s: %s\n", t.Tenant, t.Error)
+		}
+		for _, c := range t.Clients {
+			who := c.MAC
+			if c.Name != "" {
+				who = c.Name + " (" + c.MAC + ")"
+			}
+			for _, p := range c.Problems {
+				fmt.Printf("⚠️  %s: %s: %s\n", t.Tenant, who, p)
+			}
+		}
+	}
+}
+
+// writeReportCSV writes a row per client, or per controller that couldn't
+// be read, for importing into monitoring tools.
+func writeReportCSV(tenants []tenantReport) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"tenant", "host", "mac", "name", "site", "group_id", "addresses", "online", "in_sync", "last_changed", "result", "problems"})
+	for _, t := range tenants {
+		if t.Error != "" {
+			w.Write([]string{t.Tenant, t.Host, "", "", "", "", "", "", "", "", updater.ResultFailed, t.Error})
+		}
+		for _, c := range t.Clients {
+			last := ""
+			if !c.LastChanged.IsZero() {
+				last = c.LastChanged.UTC().Format(time.RFC3339)
+			}
+			w.Write([]string{t.Tenant, t.Host, c.MAC, c.Name, c.Site, c.GroupID, strings.Join(c.Addresses, " "),
+				strconv.FormatBool(c.Online), strconv.FormatBool(c.InSync), last, c.Result, strings.Join(c.Problems, "; ")})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/backup"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdRestore lists the snapshots of a firewall group in BACKUP_DIR, or
+// writes the chosen one back to the controller it was taken on. The group
+// is snapshotted again first, so a restore can be undone too.
+func cmdRestore(o *options) int {
+	var snapshot *backup.Snapshot
+	if strings.ContainsRune(o.RestoreSnapshot, os.PathSeparator) || strings.HasSuffix(o.RestoreSnapshot, ".json") {
+		s, err := backup.Load(o.RestoreSnapshot)
+		if err != nil {
+			fmt.Println("❌ Failed to read snapshot:", err)
+			return exitConfig
+		}
+		snapshot = s
+	} else {
+		if o.BackupDir == "" || o.RestoreGroup == "" {
+			fmt.Println("❌ Usage: restore --backup-dir DIR --group ID [--snapshot NAME|latest], or restore --snapshot FILE")
+			return exitConfig
+		}
+		snapshots, err := backup.Dir{Path: o.BackupDir}.Snapshots(o.RestoreGroup)
+		if err != nil {
+			fmt.Println("❌ Failed to read snapshots:", err)
+			return exitConfig
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("❌ No snapshots of firewall group %s in %s\n", o.RestoreGroup, o.BackupDir)
+			return exitConfig
+		}
+
+		switch o.RestoreSnapshot {
+		case "":
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SNAPSHOT\tTAKEN\tNAME\tMEMBERS")
+			for _, s := range snapshots {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Name, s.Time.Local().Format(time.DateTime), s.GroupName(), strings.Join(s.Members(), ", "))
+			}
+			w.Flush()
+			return exitOK
+		case "latest":
+			snapshot = &snapshots[0]
+		default:
+			i := slices.IndexFunc(snapshots, func(s backup.Snapshot) bool { return s.Name == o.RestoreSnapshot })
+			if i < 0 {
+				fmt.Printf("❌ No snapshot %s of firewall group %s, run without --snapshot to list them\n", o.RestoreSnapshot, o.RestoreGroup)
+				return exitConfig
+			}
+			snapshot = &snapshots[i]
+		}
+	}
+
+	desc := fmt.Sprintf("%s (%s) on %s as of %s: %s", snapshot.GroupName(), snapshot.GroupID(), snapshot.Host,
+		snapshot.Time.Local().Format(time.DateTime), strings.Join(snapshot.Members(), ", "))
+	if o.DryRun {
+		fmt.Println("♻️  Would restore", desc)
+		return exitOK
+	}
+
+	ctrl, err := o.hostController(snapshot.Host, snapshot.Site)
+	if err != nil {
+		fmt.Println("❌", err)
+		return exitConfig
+	}
+	var old []string
+	if groups, err := ctrl.FirewallGroups(); err == nil {
+		if i := slices.IndexFunc(groups, func(g unifi.FirewallGroup) bool { return g.ID == snapshot.GroupID() }); i >= 0 {
+			old = groups[i].Members
+		}
+	}
+	err = ctrl.ReplaceFirewallGroup(snapshot.GroupID(), snapshot.Group)
+	o.record(history.Entry{Action: history.ActionRestore, Target: updater.DefaultTarget, Ref: snapshot.GroupID(), Old: old, New: snapshot.Members()}, err)
+	if err != nil {
+		fmt.Println("❌ Failed to restore firewall group:", err)
+		return exitCode(err)
+	}
+	fmt.Println("♻️  Restored", desc)
+	return exitOK
+}
+
+// hostController returns an API client for site of the controller at
+// host: UNIFI_HOST, or one of the config file's controllers.
+func (o *options) hostController(host, site string) (*unifi.Client, error) {
+	if o.Host != "" && slices.Contains(unifi.SplitHosts(o.Host), host) {
+		return o.siteController(nil, site)
+	}
+	if cfg, err := updater.LoadConfig(o.ConfigPath); err == nil {
+		for _, cc := range cfg.Controllers {
+			if slices.Contains(unifi.SplitHosts(cc.URL()), host) {
+				return o.siteController(&cc, site)
+			}
+		}
+	}
+	return nil, fmt.Errorf("the snapshot was taken on %s, which is neither UNIFI_HOST nor one of the config file's controllers", host)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // for TZ in images without a zoneinfo database
+)
+
+// runAt is a schedule of cycles at fixed wall-clock times of day.
+type runAt struct {
+	times []time.Duration // since midnight, sorted
+	loc   *time.Location
+}
+
+// parseRunAt parses RUN_AT, times of day such as "06:00,18:00", in the time
+// zone tz, or the local one when tz is empty.
+func parseRunAt(s, tz string) (*runAt, error) {
+	loc := time.Local
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", tz, err)
+		}
+	}
+	r := &runAt{loc: loc}
+	for t := range strings.SplitSeq(s, ",") {
+		clock, err := time.Parse("15:04", strings.TrimSpace(t))
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of day %q, use HH:MM", strings.TrimSpace(t))
+		}
+		r.times = append(r.times, time.Duration(clock.Hour())*time.Hour+time.Duration(clock.Minute())*time.Minute)
+	}
+	slices.Sort(r.times)
+	r.times = slices.Compact(r.times)
+	return r, nil
+}
+
+// next returns the first of the times after now. Times skipped by a
+// daylight saving change fall after it, as time.Date normalizes them.
+func (r *runAt) next(now time.Time) time.Time {
+	now = now.In(r.loc)
+	for day := 0; ; day++ {
+		y, m, d := now.AddDate(0, 0, day).Date()
+		for _, t := range r.times {
+			at := time.Date(y, m, d, int(t/time.Hour), int(t%time.Hour/time.Minute), 0, 0, r.loc)
+			if at.After(now) {
+				return at
+			}
+		}
+	}
+}
+
+// String lists the times with the time zone, for the log.
+func (r *runAt) String() string {
+	times := make([]string, len(r.times))
+	for i, t := range r.times {
+		times[i] = fmt.Sprintf("%02d:%02d", int(t/time.Hour), int(t%time.Hour/time.Minute))
+	}
+	return strings.Join(times, ", ") + " " + r.loc.String()
+}
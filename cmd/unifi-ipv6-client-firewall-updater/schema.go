@@ -0,0 +1,20 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// cmdSchema prints the JSON Schema of the config file.
+func cmdSchema(o *options) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(updater.Schema()); err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Failed to write schema:", err)
+		return exitFailure
+	}
+	return exitOK
+}
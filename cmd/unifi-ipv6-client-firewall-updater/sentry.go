@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// initSentry enables Sentry error reporting when dsn is set. The returned
+// function flushes buffered events and should be deferred.
+func initSentry(dsn, environment string) func() {
+	if dsn == "" {
+		return func() {}
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+	})
+	if err != nil {
+		fmt.Println("⚠️  Failed to initialise Sentry:", err)
+		return func() {}
+	}
+
+	fmt.Println("✅ Sentry error reporting enabled")
+	return func() { sentry.Flush(5 * time.Second) }
+}
+
+// reportError sends err to Sentry tagged with the client and group it
+// concerns; empty values are left out. It is a no-op when Sentry is disabled.
+func reportError(err error, mac, groupID string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		if mac != "" {
+			scope.SetTag("client_mac", mac)
+		}
+		if groupID != "" {
+			scope.SetTag("group_id", groupID)
+		}
+		sentry.CaptureException(err)
+	})
+}
+
+// reportPanic sends a recovered panic to Sentry, tagged like reportError.
+func reportPanic(v any, mac, groupID string) {
+	sentry.WithScope(func(scope *sentry.Scope) {
+		if mac != "" {
+			scope.SetTag("client_mac", mac)
+		}
+		if groupID != "" {
+			scope.SetTag("group_id", groupID)
+		}
+		sentry.CurrentHub().Recover(v)
+	})
+}
+
+// recoverPanic reports a panic to Sentry and then re-raises it. It must be
+// deferred directly.
+func recoverPanic() {
+	if r := recover(); r != nil {
+		sentry.CurrentHub().Recover(r)
+		sentry.Flush(5 * time.Second)
+		panic(r)
+	}
+}
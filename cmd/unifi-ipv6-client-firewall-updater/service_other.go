@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// runService runs the command; services only exist on Windows.
+func runService(o *options, run func(*options) int) int {
+	return run(o)
+}
+
+func cmdService(o *options) int {
+	fmt.Println("❌ The service command is only available on Windows")
+	return exitConfig
+}
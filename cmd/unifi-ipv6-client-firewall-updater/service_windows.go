@@ -0,0 +1,191 @@
+//go:build windows
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	serviceName        = "unifi-ipv6-client-firewall-updater"
+	serviceDisplayName = "UniFi IPv6 Client Firewall Updater"
+)
+
+// runService runs the command under the service control manager when
+// started as a Windows service, with its output sent to the event log, and
+// directly otherwise.
+func runService(o *options, run func(*options) int) int {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return run(o)
+	}
+
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return exitFailure
+	}
+	defer elog.Close()
+	logToEventLog(elog)
+
+	h := &serviceHandler{o: o, run: run}
+	if err := svc.Run(serviceName, h); err != nil {
+		elog.Error(1, "service failed: "+err.Error())
+		return exitFailure
+	}
+	return h.code
+}
+
+// logToEventLog sends everything written to stdout and stderr to the event
+// log, one entry per line, with errors and warnings at their own level.
+func logToEventLog(elog *eventlog.Log) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	os.Stdout, os.Stderr = w, w
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "❌"):
+				elog.Error(1, line)
+			case strings.HasPrefix(line, "⚠️"):
+				elog.Warning(1, line)
+			default:
+				elog.Info(1, line)
+			}
+		}
+	}()
+}
+
+// serviceHandler answers the service control manager while the command
+// runs.
+type serviceHandler struct {
+	o    *options
+	run  func(*options) int
+	code int
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan int, 1)
+	go func() { done <- h.run(h.o) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.code = <-done:
+			status <- svc.Status{State: svc.StopPending}
+			return false, uint32(h.code)
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				fmt.Println("👋 Service stopping")
+				status <- svc.Status{State: svc.StopPending}
+				return false, 0
+			}
+		}
+	}
+}
+
+// cmdService installs, removes, starts or stops the Windows service. The
+// arguments after "install" are what the service is started with, e.g.
+// "service install serve --config C:\updater\clients.json".
+func cmdService(o *options) int {
+	if len(os.Args) < 3 {
+		fmt.Println("❌ Usage: service install|uninstall|start|stop [command] [flags]")
+		return exitConfig
+	}
+	var err error
+	switch action := os.Args[2]; action {
+	case "install":
+		err = installService(os.Args[3:])
+	case "uninstall":
+		err = uninstallService()
+	case "start":
+		err = withService(func(s *mgr.Service) error { return s.Start() })
+	case "stop":
+		err = withService(func(s *mgr.Service) error {
+			_, err := s.Control(svc.Stop)
+			return err
+		})
+	default:
+		fmt.Printf("❌ Unknown service action %q, use install, uninstall, start or stop\n", action)
+		return exitConfig
+	}
+	if err != nil {
+		fmt.Println("❌ Service:", err)
+		return exitFailure
+	}
+	fmt.Printf("✅ Service %s: %s\n", serviceName, os.Args[2])
+	return exitOK
+}
+
+func installService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: "Keeps firewall groups up to date with the IPv6 addresses of UniFi clients.",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	err = eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil {
+		s.Delete()
+		return fmt.Errorf("registering the event log source: %w", err)
+	}
+	return nil
+}
+
+func uninstallService() error {
+	err := withService(func(s *mgr.Service) error {
+		// stop it first, or it is only removed once it stops by itself
+		if _, err := s.Control(svc.Stop); err == nil {
+			time.Sleep(2 * time.Second)
+		}
+		return s.Delete()
+	})
+	if err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+// withService calls fn with the installed service.
+func withService(fn func(*mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return fn(s)
+}
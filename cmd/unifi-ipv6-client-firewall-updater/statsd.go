@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// statsdPacket bounds the size of the UDP packets sent to StatsD, so they
+// aren't fragmented.
+const statsdPacket = 1400
+
+// tagReplacer drops the characters DogStatsD tags can't hold.
+var tagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", " ")
+
+// statsd sends each cycle to a StatsD server: a run counter and timing, the
+// summary counts as counters and a counter of the address changes. With
+// tags, the DogStatsD extension, runs are tagged with whether they
+// succeeded and each address change with its client instead.
+type statsd struct {
+	addr   string
+	prefix string
+	tags   bool
+}
+
+func (s statsd) report(st updater.Status) error {
+	conn, err := net.Dial("udp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var lines []string
+	metric := func(name, value, typ string, tags ...string) {
+		line := fmt.Sprintf("%s.%s:%s|%s", s.prefix, name, value, typ)
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+		lines = append(lines, line)
+	}
+
+	if s.tags {
+		metric("runs", "1", "c", fmt.Sprintf("success:%t", st.Success))
+	} else if st.Success {
+		metric("runs.succeeded", "1", "c")
+	} else {
+		metric("runs.failed", "1", "c")
+	}
+	metric("run.duration", fmt.Sprint(st.DurationMS), "ms")
+	for _, o := range summaryOutcomes(st.Summary) {
+		metric("clients."+o.name, fmt.Sprint(o.n), "c")
+	}
+	changed := changedClients(st)
+	if !s.tags {
+		metric("address_changes", fmt.Sprint(len(changed)), "c")
+	} else {
+		for _, c := range changed {
+			tags := []string{"mac:" + strings.ToLower(c.MAC)}
+			if c.Name != "" {
+				tags = append(tags, "name:"+tagReplacer.Replace(c.Name))
+			}
+			metric("address_changes", "1", "c", tags...)
+		}
+	}
+
+	// lines are sent several to a packet, separated by newlines
+	var packet string
+	for _, l := range lines {
+		if packet != "" && len(packet)+1+len(l) > statsdPacket {
+			if _, err := conn.Write([]byte(packet)); err != nil {
+				return err
+			}
+			packet = ""
+		}
+		if packet != "" {
+			packet += "\n"
+		}
+		packet += l
+	}
+	_, err = conn.Write([]byte(packet))
+	return err
+}
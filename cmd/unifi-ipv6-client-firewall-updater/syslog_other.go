@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// dialSyslog fails where there is no syslog client.
+func dialSyslog(addr string) (logWriter, error) {
+	return nil, errors.New("syslog is not available on this platform")
+}
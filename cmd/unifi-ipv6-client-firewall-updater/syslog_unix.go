@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"log/syslog"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// syslogLog sends lines to syslog, with the priority of their level.
+type syslogLog struct{ w *syslog.Writer }
+
+// dialSyslog connects to the syslog server at addr, e.g.
+// "udp://192.168.1.10:514", or to the local one when addr is empty.
+func dialSyslog(addr string) (logWriter, error) {
+	network, raddr, _ := strings.Cut(addr, "://")
+	w, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_DAEMON, "unifi-ipv6-client-firewall-updater")
+	if err != nil {
+		return nil, err
+	}
+	return syslogLog{w}, nil
+}
+
+func (s syslogLog) writeLine(l logLine, text string) error {
+	switch l.level {
+	case updater.SeverityError:
+		return s.w.Err(text)
+	case updater.SeverityWarning:
+		return s.w.Warning(text)
+	}
+	return s.w.Info(text)
+}
+
+func (s syslogLog) Close() error { return s.w.Close() }
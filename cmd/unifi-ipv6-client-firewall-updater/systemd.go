@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// systemd reports to systemd when the updater runs as a Type=notify
+// service: READY once the first cycle succeeds, or at startup when
+// RUN_ON_START puts it off, the outcome of each cycle
+// as the unit's status, and, with WatchdogSec set, watchdog pings for as
+// long as no cycle is stuck.
+type systemd struct {
+	socket   string
+	watchdog time.Duration
+	ready    bool
+	// started is when the running cycle began, in Unix nanoseconds, or 0
+	// between cycles.
+	started atomic.Int64
+}
+
+// newSystemd returns the systemd heartbeat, or nil when not started by
+// systemd with NOTIFY_SOCKET set.
+func newSystemd() *systemd {
+	sd := &systemd{socket: os.Getenv("NOTIFY_SOCKET")}
+	if sd.socket == "" {
+		return nil
+	}
+	usec, _ := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	pid := os.Getenv("WATCHDOG_PID")
+	if usec > 0 && (pid == "" || pid == strconv.Itoa(os.Getpid())) {
+		sd.watchdog = time.Duration(usec) * time.Microsecond
+		go sd.pingWatchdog()
+	}
+	return sd
+}
+
+func (sd *systemd) start() {
+	sd.started.Store(time.Now().UnixNano())
+}
+
+func (sd *systemd) finish(err error) {
+	sd.started.Store(0)
+	status := "STATUS=Last cycle succeeded"
+	if err != nil {
+		status = "STATUS=Last cycle failed: " + err.Error()
+	} else if !sd.ready {
+		sd.ready = true
+		status = "READY=1\n" + status
+	}
+	if sd.watchdog > 0 {
+		status += "\nWATCHDOG=1"
+	}
+	sd.notify(status)
+}
+
+// skip reports the service as started without running a cycle, when
+// RUN_ON_START puts the first one off until next.
+func (sd *systemd) skip(next time.Time) {
+	if sd.ready {
+		return
+	}
+	sd.ready = true
+	sd.notify("READY=1\nSTATUS=First cycle at " + next.Format(time.DateTime))
+}
+
+// extendStart asks systemd, when started as a Type=notify service, to
+// wait delay longer for the service to start, on top of its default start
+// timeout for the first cycle, so that STARTUP_DELAY doesn't time it out.
+func extendStart(delay time.Duration) {
+	sd := &systemd{socket: os.Getenv("NOTIFY_SOCKET")}
+	if sd.socket == "" {
+		return
+	}
+	sd.notify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d\nSTATUS=Waiting %v at startup", (delay + 90*time.Second).Microseconds(), delay))
+}
+
+// pingWatchdog pings the watchdog twice per interval, unless a cycle has
+// been running for longer than the interval, in which case systemd is left
+// to restart the service.
+func (sd *systemd) pingWatchdog() {
+	ticker := time.NewTicker(sd.watchdog / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		started := sd.started.Load()
+		if started != 0 && time.Since(time.Unix(0, started)) > sd.watchdog {
+			continue
+		}
+		sd.notify("WATCHDOG=1")
+	}
+}
+
+func (sd *systemd) notify(state string) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: sd.socket, Net: "unixgram"})
+	if err != nil {
+		fmt.Println("⚠️  systemd notification failed:", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		fmt.Println("⚠️  systemd notification failed:", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// Build information, set at build time with
+//
+//	-ldflags "-X main.version=v1.2.3 -X main.commit=... -X main.date=..."
+//
+// Whatever is left unset is taken from the module version and the VCS
+// details the go command records in the binary.
+var (
+	version = "dev"
+	commit  string
+	date    string
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && commit == "":
+			commit = s.Value
+		case s.Key == "vcs.time" && date == "":
+			date = s.Value
+		}
+	}
+}
+
+// versionString describes the build, e.g.
+// "unifi-ipv6-client-firewall-updater v1.2.3 (commit 0123abc, 2025-01-02T03:04:05Z)".
+func versionString() string {
+	s := "unifi-ipv6-client-firewall-updater " + version
+	switch {
+	case commit != "" && date != "":
+		s += fmt.Sprintf(" (commit %.7s, %s)", commit, date)
+	case commit != "":
+		s += fmt.Sprintf(" (commit %.7s)", commit)
+	}
+	return s
+}
+
+// userAgent identifies this build in the controller's logs.
+func userAgent() string {
+	return unifi.DefaultUserAgent + "/" + version
+}
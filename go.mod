@@ -1,3 +1,19 @@
 module github.com/brendann993/unifi-ipv6-client-firewall-updater
 
 go 1.24.1
+
+require (
+	github.com/getsentry/sentry-go v0.36.0
+	golang.org/x/crypto v0.40.0
+	golang.org/x/sync v0.17.0
+	golang.org/x/sys v0.34.0
+	golang.org/x/time v0.12.0
+	google.golang.org/grpc v1.76.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/text v0.27.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250804133106-a7a43d27e69b // indirect
+)
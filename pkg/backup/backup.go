@@ -0,0 +1,168 @@
+// Package backup keeps snapshots of firewall groups taken right before the
+// updater changes them, so a bad update can be undone by hand or with the
+// restore command.
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultKeep is how many snapshots of each group are kept by default.
+const DefaultKeep = 20
+
+// timeFormat names snapshot files, sorting them oldest first.
+const timeFormat = "20060102T150405.000000000Z"
+
+// Snapshot is a firewall group as it was before a change.
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	// Host and Site are the controller and site the group is on.
+	Host  string          `json:"host"`
+	Site  string          `json:"site"`
+	Group json.RawMessage `json:"group"`
+
+	// Name identifies the snapshot among its group's, e.g.
+	// "20250102T030405.000000000Z"; set when it is read.
+	Name string `json:"-"`
+}
+
+// group holds the fields of a snapshot's group shown to the user.
+type group struct {
+	ID      string   `json:"_id"`
+	Name    string   `json:"name"`
+	Members []string `json:"group_members"`
+}
+
+func (s Snapshot) group() group {
+	var g group
+	json.Unmarshal(s.Group, &g)
+	return g
+}
+
+// GroupID returns the ID of the snapshot's group.
+func (s Snapshot) GroupID() string { return s.group().ID }
+
+// GroupName returns the name of the snapshot's group.
+func (s Snapshot) GroupName() string { return s.group().Name }
+
+// Members returns the members the snapshot's group had.
+func (s Snapshot) Members() []string { return s.group().Members }
+
+// Dir keeps snapshots as JSON files in a directory per group.
+type Dir struct {
+	Path string
+	// Keep is how many snapshots of each group are kept, the oldest being
+	// removed first; DefaultKeep when 0.
+	Keep int
+}
+
+// safeID keeps group IDs from escaping the directory.
+var safeID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Save writes a snapshot of group, taken now, and removes the group's
+// oldest snapshots beyond Keep.
+func (d Dir) Save(host, site string, group json.RawMessage) error {
+	s := Snapshot{Time: time.Now().UTC(), Host: host, Site: site, Group: group}
+	id := s.GroupID()
+	if !safeID.MatchString(id) {
+		return fmt.Errorf("unexpected firewall group ID %q", id)
+	}
+	dir := filepath.Join(d.Path, id)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, s.Time.Format(timeFormat)+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return d.rotate(dir)
+}
+
+// Snapshots returns the snapshots of the group with ID id, newest first.
+func (d Dir) Snapshots(id string) ([]Snapshot, error) {
+	if !safeID.MatchString(id) {
+		return nil, fmt.Errorf("invalid firewall group ID %q", id)
+	}
+	dir := filepath.Join(d.Path, id)
+	names, err := snapshotFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []Snapshot
+	for _, name := range slices.Backward(names) {
+		s, err := Load(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *s)
+	}
+	return snapshots, nil
+}
+
+// Load reads the snapshot file at path.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.GroupID() == "" {
+		return nil, fmt.Errorf("%s: not a firewall group snapshot", path)
+	}
+	s.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+	return &s, nil
+}
+
+func (d Dir) rotate(dir string) error {
+	keep := d.Keep
+	if keep <= 0 {
+		keep = DefaultKeep
+	}
+	names, err := snapshotFiles(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names[:max(len(names)-keep, 0)] {
+		errs = append(errs, os.Remove(filepath.Join(dir, name)))
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotFiles returns the names of the snapshot files in dir, oldest
+// first.
+func snapshotFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
@@ -0,0 +1,155 @@
+// Package history keeps a log of every address change and firewall group
+// change the updater makes, so it can later be told when a client's
+// access changed and whether it worked.
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What an entry records.
+const (
+	// ActionChange is a client's addresses being published.
+	ActionChange = "change"
+	// ActionClear is a client's entries being emptied.
+	ActionClear = "clear"
+	// ActionCleanup is stale members being removed from a group.
+	ActionCleanup = "cleanup"
+	// ActionRestore is a group being put back from a snapshot.
+	ActionRestore = "restore"
+	// ActionRepair is a group changed by someone else being put back to
+	// the addresses last published.
+	ActionRepair = "repair"
+	// ActionPrune is a client's replaced addresses being removed once
+	// their overlap ended.
+	ActionPrune = "prune"
+)
+
+// Entry results.
+const (
+	ResultOK     = "ok"
+	ResultFailed = "failed"
+)
+
+// Entry is a single change.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	// MAC and Name are the client changed, if it was a single one.
+	MAC  string `json:"mac,omitempty"`
+	Name string `json:"name,omitempty"`
+	// Target and Ref are the entry changed, e.g. a firewall group ID.
+	Target string `json:"target,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	// Old and New are the addresses or members before and after.
+	Old    []string `json:"old,omitempty"`
+	New    []string `json:"new,omitempty"`
+	Result string   `json:"result"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// File keeps the history as JSON lines appended to a file, oldest first.
+type File struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Record appends e, stamping it with the current time if it has none.
+func (f *File) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Result == "" {
+		e.Result = ResultOK
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(append(data, '\n')); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Query selects entries; zero fields match everything.
+type Query struct {
+	// Client matches a client's MAC or name, ignoring case.
+	Client string
+	// Since and Until bound the time of the entries, Until excluded.
+	Since, Until time.Time
+}
+
+// Matches reports whether e is selected by q.
+func (q Query) Matches(e Entry) bool {
+	if q.Client != "" && !strings.EqualFold(q.Client, e.MAC) && !strings.EqualFold(q.Client, e.Name) {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	return q.Until.IsZero() || e.Time.Before(q.Until)
+}
+
+// Read returns the entries selected by q, oldest first. A history that
+// doesn't exist yet is empty.
+func (f *File) Read(q Query) ([]Entry, error) {
+	in, err := os.Open(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(in)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", f.Path, n, err)
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
+
+// WriteCSV writes entries as CSV with a header row. Times are RFC 3339 in
+// UTC, and addresses are separated by spaces.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "action", "mac", "name", "target", "ref", "old", "new", "result", "error"})
+	for _, e := range entries {
+		cw.Write([]string{e.Time.UTC().Format(time.RFC3339), e.Action, e.MAC, e.Name, e.Target, e.Ref,
+			strings.Join(e.Old, " "), strings.Join(e.New, " "), e.Result, e.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,15 @@
+// Package leader elects one of several updater replicas to make changes,
+// so the updater can run redundantly without replicas racing each other.
+package leader
+
+import "time"
+
+// Elector takes part in an election for a single leader.
+type Elector interface {
+	// Acquire takes or renews leadership for ttl, reporting whether this
+	// instance is the leader. It is called repeatedly, well within ttl.
+	Acquire(ttl time.Duration) (bool, error)
+	// Release gives up leadership, if held, so another replica can take
+	// over without waiting for it to expire.
+	Release() error
+}
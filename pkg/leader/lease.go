@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/kube"
+)
+
+// microTime is the format of Lease timestamps.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// Lease elects a leader through a coordination.k8s.io/v1 Lease, as
+// Kubernetes controllers do. The service account needs get, create and
+// update on leases in the namespace.
+type Lease struct {
+	client    *kube.Client
+	namespace string
+	name      string
+	id        string
+}
+
+// NewLease returns an elector using the Lease name in namespace (the pod's
+// own when empty), identifying this replica as id.
+func NewLease(client *kube.Client, namespace, name, id string) *Lease {
+	if namespace == "" {
+		namespace = client.Namespace()
+	}
+	return &Lease{client: client, namespace: namespace, name: name, id: id}
+}
+
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace,omitempty"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       *string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          *string `json:"acquireTime,omitempty"`
+		RenewTime            *string `json:"renewTime,omitempty"`
+		LeaseTransitions     *int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+func (l *Lease) path() string {
+	return fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", url.PathEscape(l.namespace))
+}
+
+func (l *Lease) Acquire(ttl time.Duration) (bool, error) {
+	now := time.Now().UTC().Format(microTime)
+	seconds := max(int(ttl.Seconds()), 1)
+
+	var cur lease
+	err := l.client.Do("GET", l.path()+"/"+url.PathEscape(l.name), nil, &cur)
+	if kube.IsNotFound(err) {
+		cur = lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		cur.Metadata.Name = l.name
+		cur.Spec.HolderIdentity, cur.Spec.LeaseDurationSeconds = &l.id, &seconds
+		cur.Spec.AcquireTime, cur.Spec.RenewTime = &now, &now
+		err := l.client.Do("POST", l.path(), cur, nil)
+		if kube.IsConflict(err) {
+			return false, nil // another replica created it first
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	held := cur.Spec.HolderIdentity != nil && *cur.Spec.HolderIdentity == l.id
+	if !held && !expired(cur) {
+		return false, nil
+	}
+	if !held {
+		transitions := 1
+		if cur.Spec.LeaseTransitions != nil {
+			transitions += *cur.Spec.LeaseTransitions
+		}
+		cur.Spec.HolderIdentity, cur.Spec.AcquireTime, cur.Spec.LeaseTransitions = &l.id, &now, &transitions
+	}
+	cur.Spec.LeaseDurationSeconds, cur.Spec.RenewTime = &seconds, &now
+
+	// the resourceVersion makes this fail if another replica got there
+	// first
+	err = l.client.Do("PUT", l.path()+"/"+url.PathEscape(l.name), cur, nil)
+	if kube.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// expired reports whether the holder of l failed to renew it in time, or
+// released it.
+func expired(l lease) bool {
+	if l.Spec.HolderIdentity == nil || *l.Spec.HolderIdentity == "" || l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	renewed, err := time.Parse(microTime, *l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(renewed) > time.Duration(*l.Spec.LeaseDurationSeconds)*time.Second
+}
+
+func (l *Lease) Release() error {
+	var cur lease
+	if err := l.client.Do("GET", l.path()+"/"+url.PathEscape(l.name), nil, &cur); err != nil {
+		return err
+	}
+	if cur.Spec.HolderIdentity == nil || *cur.Spec.HolderIdentity != l.id {
+		return nil
+	}
+	empty := ""
+	cur.Spec.HolderIdentity = &empty
+	return l.client.Do("PUT", l.path()+"/"+url.PathEscape(l.name), cur, nil)
+}
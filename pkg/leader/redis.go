@@ -0,0 +1,115 @@
+package leader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripts that only touch the key while this replica holds it.
+const (
+	renewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+// Redis elects a leader through a key in Redis holding the leader's ID
+// with an expiry. A connection is made per call, which is plenty for an
+// election renewed every few seconds.
+type Redis struct {
+	addr     string
+	password string
+	key      string
+	id       string
+}
+
+// NewRedis returns an elector using key on the Redis server at addr,
+// identifying this replica as id.
+func NewRedis(addr, password, key, id string) *Redis {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "6379")
+	}
+	return &Redis{addr: addr, password: password, key: key, id: id}
+}
+
+func (r *Redis) Acquire(ttl time.Duration) (bool, error) {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	reply, err := r.do([]string{"SET", r.key, r.id, "NX", "PX", ms})
+	if err != nil {
+		return false, err
+	}
+	if reply == "OK" {
+		return true, nil
+	}
+	reply, err = r.do([]string{"EVAL", renewScript, "1", r.key, r.id, ms})
+	if err != nil {
+		return false, err
+	}
+	return reply == "1", nil
+}
+
+func (r *Redis) Release() error {
+	_, err := r.do([]string{"EVAL", releaseScript, "1", r.key, r.id})
+	return err
+}
+
+// do runs a command, after authenticating if needed, and returns its
+// reply: the text of a simple string, integer or bulk string, or "" for a
+// null.
+func (r *Redis) do(cmd []string) (string, error) {
+	conn, err := net.DialTimeout("tcp", r.addr, 10*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	rd := bufio.NewReader(conn)
+	if r.password != "" {
+		if _, err := roundTrip(conn, rd, []string{"AUTH", r.password}); err != nil {
+			return "", fmt.Errorf("redis AUTH: %w", err)
+		}
+	}
+	return roundTrip(conn, rd, cmd)
+}
+
+func roundTrip(conn net.Conn, rd *bufio.Reader, cmd []string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(cmd))
+	for _, arg := range cmd {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", err
+	}
+
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return "", err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return "", fmt.Errorf("redis: unexpected reply %q", line)
+}
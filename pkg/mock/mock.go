@@ -0,0 +1,215 @@
+// Package mock is a fake UniFi Network controller serving the part of the
+// API the updater uses, for trying configurations and features out without
+// touching a live controller.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// State is what the fake controller serves, on every site.
+type State struct {
+	// Clients are the connected clients.
+	Clients []unifi.Station `json:"clients"`
+	// Known are clients that are offline, with their last addresses.
+	Known []unifi.Station `json:"known,omitempty"`
+	// Groups are the firewall groups, changed by the updater as on a real
+	// controller.
+	Groups []unifi.FirewallGroup `json:"groups"`
+	// WAN are the gateway's WAN addresses.
+	WAN []string `json:"wan,omitempty"`
+}
+
+// LoadState reads a State from the JSON file at path.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &st, nil
+}
+
+// Controller serves State over the controller API.
+type Controller struct {
+	// APIKey, if set, is the only key accepted.
+	APIKey string
+	// Log, if set, receives a line for every change made.
+	Log *log.Logger
+
+	mu    sync.Mutex
+	state State
+}
+
+// New returns a controller serving st.
+func New(st State) *Controller {
+	return &Controller{state: st}
+}
+
+// State returns a copy of what the controller serves now.
+func (c *Controller) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.state
+	st.Groups = slices.Clone(c.state.Groups)
+	return st
+}
+
+// ServeHTTP answers the controller API under /proxy/network, for any site.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.APIKey != "" && r.Header.Get("X-API-KEY") != c.APIKey {
+		writeError(w, http.StatusUnauthorized, "api.err.Invalid")
+		return
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/proxy/network")
+	if !ok {
+		writeError(w, http.StatusNotFound, "api.err.NotFound")
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && match(parts, "v2", "api", "site", "*", "clients", "active"):
+		type active struct {
+			MAC           string   `json:"mac"`
+			Name          string   `json:"name"`
+			Hostname      string   `json:"hostname"`
+			NetworkName   string   `json:"network_name"`
+			IP            string   `json:"ip"`
+			IPv6Addresses []string `json:"ipv6_addresses"`
+		}
+		clients := []active{}
+		for _, s := range c.state.Clients {
+			clients = append(clients, active{s.MAC, s.Name, s.Hostname, s.Network, s.IP, s.IPv6Addresses})
+		}
+		writeJSON(w, clients)
+	case r.Method == http.MethodGet && match(parts, "api", "s", "*", "stat", "sta"):
+		writeData(w, c.state.Clients)
+	case r.Method == http.MethodGet && match(parts, "api", "s", "*", "rest", "user"):
+		type user struct {
+			unifi.Station
+			LastIPv6 []string `json:"last_ipv6"`
+		}
+		users := []user{}
+		for _, s := range append(slices.Clone(c.state.Clients), c.state.Known...) {
+			users = append(users, user{Station: unifi.Station{MAC: s.MAC, Name: s.Name, Hostname: s.Hostname}, LastIPv6: s.IPv6Addresses})
+		}
+		writeData(w, users)
+	case r.Method == http.MethodGet && match(parts, "api", "s", "*", "stat", "device"):
+		type wan struct {
+			IPv6 []string `json:"ipv6"`
+		}
+		devices := []map[string]any{}
+		if len(c.state.WAN) > 0 {
+			devices = append(devices, map[string]any{"type": "udm", "wan1": wan{c.state.WAN}})
+		}
+		writeData(w, devices)
+	case r.Method == http.MethodGet && match(parts, "api", "s", "*", "rest", "firewallgroup"):
+		writeData(w, c.state.Groups)
+	case match(parts, "api", "s", "*", "rest", "firewallgroup", "*"):
+		i := slices.IndexFunc(c.state.Groups, func(g unifi.FirewallGroup) bool { return g.ID == parts[5] })
+		if i < 0 {
+			writeError(w, http.StatusBadRequest, "api.err.IdInvalid")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeData(w, c.state.Groups[i:i+1])
+		case http.MethodPut:
+			c.putGroup(w, r, i)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "api.err.MethodNotAllowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "api.err.NotFound")
+	}
+}
+
+// putGroup replaces the members of the i-th group, refusing members a
+// controller would.
+func (c *Controller) putGroup(w http.ResponseWriter, r *http.Request, i int) {
+	var body unifi.FirewallGroup
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "api.err.InvalidPayload")
+		return
+	}
+	g := &c.state.Groups[i]
+	for _, m := range body.Members {
+		if !validMember(g.Type, m) {
+			writeError(w, http.StatusBadRequest, "api.err.FirewallGroupAddressInvalid")
+			return
+		}
+	}
+	if c.Log != nil {
+		c.Log.Printf("🧪 Firewall group %s (%s): %s → %s\n", g.Name, g.ID, strings.Join(g.Members, ", "), strings.Join(body.Members, ", "))
+	}
+	g.Members = body.Members
+	writeData(w, c.state.Groups[i:i+1])
+}
+
+// validMember reports whether m is an address or network of the kind a
+// group of type typ holds.
+func validMember(typ, m string) bool {
+	ip := net.ParseIP(m)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(m); err != nil {
+			return false
+		}
+	}
+	switch typ {
+	case unifi.GroupTypeIPv6:
+		return ip.To4() == nil
+	case unifi.GroupTypeIPv4:
+		return ip.To4() != nil
+	}
+	return false
+}
+
+// match reports whether parts are the path segments pattern, "*" matching
+// any one.
+func match(parts []string, pattern ...string) bool {
+	if len(parts) != len(pattern) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeData answers in the legacy API's envelope.
+func writeData[T any](w http.ResponseWriter, data []T) {
+	if data == nil {
+		data = []T{}
+	}
+	writeJSON(w, map[string]any{"meta": map[string]string{"rc": "ok"}, "data": data})
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"meta": map[string]string{"rc": "error", "msg": msg}, "data": []any{}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,35 @@
+package neighbor
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Local reads this machine's own IPv6 neighbour cache, for running on the
+// same link as the clients, e.g. on the router itself or a host on the
+// clients' VLAN. The cache holds the neighbours the kernel has exchanged
+// traffic with recently, so it doesn't depend on how fresh the
+// controller's client data is. It runs the ip command from iproute2.
+type Local struct {
+	// Interface limits the lookup to one interface; empty means all.
+	Interface string
+}
+
+func (l Local) Name() string { return "local neighbour cache" }
+
+func (l Local) Addresses() (map[string][]string, error) {
+	args := []string{"-6", "neigh", "show"}
+	if l.Interface != "" {
+		args = append(args, "dev", l.Interface)
+	}
+	cmd := exec.Command("ip", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ip %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return Parse(string(out)), nil
+}
@@ -0,0 +1,55 @@
+// Package neighbor implements updater sources that read IPv6 neighbour
+// tables, which know every address a device has recently used on the
+// link, including devices the controller reports no IPv6 for.
+package neighbor
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+)
+
+// fresh are the neighbour states confirmed recently; their addresses are
+// listed before stale ones.
+var fresh = []string{"REACHABLE", "DELAY", "PROBE", "PERMANENT", "NOARP"}
+
+// Parse reads the output of `ip -6 neigh show` into the addresses of each
+// neighbour, keyed by lower-case MAC. Entries without a link-layer address
+// (failed or incomplete ones) are skipped.
+func Parse(out string) map[string][]string {
+	type entry struct {
+		ip    string
+		fresh bool
+	}
+	entries := map[string][]entry{}
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		// 2001:db8::10 dev br0 lladdr 98:b0:37:cd:5a:e4 router REACHABLE
+		fields := strings.Fields(sc.Text())
+		i := slices.Index(fields, "lladdr")
+		if len(fields) < 2 || i < 0 || i+1 >= len(fields) {
+			continue
+		}
+		mac := strings.ToLower(fields[i+1])
+		state := fields[len(fields)-1]
+		entries[mac] = append(entries[mac], entry{ip: fields[0], fresh: slices.Contains(fresh, state)})
+	}
+
+	addrs := make(map[string][]string, len(entries))
+	for mac, list := range entries {
+		slices.SortStableFunc(list, func(a, b entry) int {
+			switch {
+			case a.fresh == b.fresh:
+				return 0
+			case a.fresh:
+				return -1
+			}
+			return 1
+		})
+		for _, e := range list {
+			addrs[mac] = append(addrs[mac], e.ip)
+		}
+	}
+	return addrs
+}
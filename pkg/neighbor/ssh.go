@@ -0,0 +1,107 @@
+package neighbor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSH reads the neighbour table of a UniFi gateway (UDM, UCG, USG) or any
+// other Linux router over SSH.
+type SSH struct {
+	addr    string
+	config  *ssh.ClientConfig
+	command string
+}
+
+// SSHConfig describes how to reach the gateway.
+type SSHConfig struct {
+	// Addr is the gateway's host, with an optional port (default 22).
+	Addr string
+	// User defaults to root, the SSH user of UniFi gateways.
+	User string
+	// Password and KeyFile authenticate the user; either or both may be
+	// set.
+	Password string
+	KeyFile  string
+	// KnownHosts is a known_hosts file to check the gateway's host key
+	// against. Without one, any host key is accepted.
+	KnownHosts string
+}
+
+// NewSSH returns a source reading the neighbour table of the gateway
+// described by c.
+func NewSSH(c SSHConfig) (*SSH, error) {
+	addr := c.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	user := c.User
+	if user == "" {
+		user = "root"
+	}
+
+	var auth []ssh.AuthMethod
+	if c.KeyFile != "" {
+		key, err := os.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", c.KeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		auth = append(auth, ssh.Password(c.Password))
+	}
+	if len(auth) == 0 {
+		return nil, errors.New("an SSH password or key file is required")
+	}
+
+	hostKey := ssh.InsecureIgnoreHostKey()
+	if c.KnownHosts != "" {
+		var err error
+		if hostKey, err = knownhosts.New(c.KnownHosts); err != nil {
+			return nil, err
+		}
+	}
+
+	return &SSH{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            auth,
+			HostKeyCallback: hostKey,
+			Timeout:         15 * time.Second,
+		},
+		command: "ip -6 neigh show",
+	}, nil
+}
+
+func (s *SSH) Name() string { return "gateway neighbour table" }
+
+func (s *SSH) Addresses() (map[string][]string, error) {
+	client, err := ssh.Dial("tcp", s.addr, s.config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	out, err := session.Output(s.command)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.command, err)
+	}
+	return Parse(string(out)), nil
+}
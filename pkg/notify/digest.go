@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KindDigest is the kind of the summary a notifier with a Digest sends in
+// place of the events it collected.
+const KindDigest = "digest"
+
+// ValidDigest reports whether s is a digest schedule: a time of day such
+// as "08:00", sending daily at that time, or a period of at least a minute
+// such as "6h", or empty for none.
+func ValidDigest(s string) bool {
+	if s == "" {
+		return true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d >= time.Minute
+	}
+	_, err := time.Parse("15:04", s)
+	return err == nil
+}
+
+// ValidQuietHours reports whether s is a range of times of day such as
+// "22:00-07:00", or empty for none.
+func ValidQuietHours(s string) bool {
+	if s == "" {
+		return true
+	}
+	from, to, ok := strings.Cut(s, "-")
+	_, err1 := time.Parse("15:04", from)
+	_, err2 := time.Parse("15:04", to)
+	return ok && err1 == nil && err2 == nil && from != to
+}
+
+// quiet reports whether t is within the notifier's quiet hours, and if so
+// when they end.
+func (n Config) quiet(t time.Time) (bool, time.Time) {
+	from, to, ok := strings.Cut(n.QuietHours, "-")
+	if !ok {
+		return false, time.Time{}
+	}
+	end := nextDigest(to, t)
+	// the next start after t comes after the next end unless t is within
+	return nextDigest(from, t).After(end), end
+}
+
+// nextDigest returns when the digest with schedule s, started at now, is
+// sent: the next time of day s after now, or now plus the period s.
+func nextDigest(s string, now time.Time) time.Time {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d)
+	}
+	t, _ := time.Parse("15:04", s)
+	at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !at.After(now) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// digest is the events collected for a notifier until its digest is sent.
+type digest struct {
+	n      Config
+	events []Event
+	timer  *time.Timer
+}
+
+var (
+	digestsMu sync.Mutex
+	digests   = map[string]*digest{} // by notifier, see Config.key
+)
+
+// key identifies the notifier across reloads of the config.
+func (n Config) key() string { return n.Type + "|" + n.URL + "|" + n.Topic }
+
+// collect adds ev to the notifier's digest, starting one sent at at if
+// none is.
+func (n Config) collect(ev Event, at time.Time) {
+	digestsMu.Lock()
+	defer digestsMu.Unlock()
+	key := n.key()
+	d, ok := digests[key]
+	if !ok {
+		d = &digest{}
+		d.timer = time.AfterFunc(time.Until(at), func() { sendDigest(key) })
+		digests[key] = d
+	}
+	d.n = n // the latest config, should it have changed
+	d.events = append(d.events, ev)
+}
+
+// sendDigest sends the digest of the notifier key, if it has one.
+func sendDigest(key string) {
+	digestsMu.Lock()
+	d, ok := digests[key]
+	delete(digests, key)
+	digestsMu.Unlock()
+	if !ok {
+		return
+	}
+	d.timer.Stop()
+	if err := d.n.send(summarize(d.events)); err != nil {
+		fmt.Printf("⚠️  Failed to send %s digest of %d events: %v\n", d.n.Type, len(d.events), err)
+	}
+}
+
+// Flush sends every digest collected so far straight away, e.g. before the
+// process exits, since digests are only kept in memory.
+func Flush() {
+	digestsMu.Lock()
+	keys := make([]string, 0, len(digests))
+	for key := range digests {
+		keys = append(keys, key)
+	}
+	digestsMu.Unlock()
+	for _, key := range keys {
+		sendDigest(key)
+	}
+}
+
+// summarize returns the digest event of events: counts by kind, followed
+// by each event's message, and the events themselves. Its severity is the
+// highest of theirs.
+func summarize(events []Event) Event {
+	counts := map[string]int{}
+	var kinds []string
+	severity := severities[0]
+	for _, ev := range events {
+		if counts[ev.Kind] == 0 {
+			kinds = append(kinds, ev.Kind)
+		}
+		counts[ev.Kind]++
+		if slices.Index(severities, ev.Severity) > slices.Index(severities, severity) {
+			severity = ev.Severity
+		}
+	}
+	var parts []string
+	for _, k := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[k], k))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📋 %d events since %s: %s", len(events), events[0].Time.Format(time.DateTime), strings.Join(parts, ", "))
+	for _, ev := range events {
+		fmt.Fprintf(&b, "\n%s %s", ev.Time.Format(time.TimeOnly), ev.Message)
+	}
+	return Event{Kind: KindDigest, Severity: severity, Message: b.String(), Time: time.Now(), Events: events}
+}
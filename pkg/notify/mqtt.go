@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// mqttPublish connects to the broker at rawURL (mqtt:// or mqtts://, with
+// optional user:password), publishes payload to topic at QoS 0 and
+// disconnects. Notifications are infrequent, so a connection per message
+// keeps things simple.
+func mqttPublish(rawURL, topic string, payload []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := u.Host
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "1883")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "mqtts", "ssl", "tls":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "8883")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return fmt.Errorf("unsupported MQTT scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// CONNECT (MQTT 3.1.1, clean session)
+	var vh bytes.Buffer
+	writeMQTTString(&vh, "MQTT")
+	vh.WriteByte(4)
+	flags := byte(0x02)
+	if u.User != nil {
+		flags |= 0x80
+		if _, ok := u.User.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	vh.WriteByte(flags)
+	binary.Write(&vh, binary.BigEndian, uint16(30))
+	writeMQTTString(&vh, fmt.Sprintf("unifi-ipv6-updater-%d", time.Now().UnixNano()))
+	if u.User != nil {
+		writeMQTTString(&vh, u.User.Username())
+		if pw, ok := u.User.Password(); ok {
+			writeMQTTString(&vh, pw)
+		}
+	}
+	if err := writeMQTTPacket(conn, 0x10, vh.Bytes()); err != nil {
+		return err
+	}
+
+	// CONNACK
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("read CONNACK: %w", err)
+	}
+	if ack[0] != 0x20 || ack[3] != 0 {
+		return fmt.Errorf("broker refused connection (code %d)", ack[3])
+	}
+
+	// PUBLISH (QoS 0)
+	var pub bytes.Buffer
+	writeMQTTString(&pub, topic)
+	pub.Write(payload)
+	if err := writeMQTTPacket(conn, 0x30, pub.Bytes()); err != nil {
+		return err
+	}
+
+	// DISCONNECT
+	return writeMQTTPacket(conn, 0xE0, nil)
+}
+
+func writeMQTTString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
+
+func writeMQTTPacket(w io.Writer, header byte, body []byte) error {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		pkt = append(pkt, d)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(pkt, body...))
+	return err
+}
@@ -0,0 +1,269 @@
+// Package notify delivers updater events to Slack, webhooks and MQTT, and
+// to in-process subscribers.
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Event kinds a notifier can subscribe to.
+const (
+	KindChange   = "change"
+	KindFailure  = "failure"
+	KindNotFound = "not_found"
+	KindDrift    = "drift"
+	KindEviction = "eviction"
+	KindAll      = "all"
+)
+
+// Severities in increasing order of importance.
+var severities = []string{"info", "warning", "error"}
+
+// Config describes a notification target and the policy deciding
+// which events are sent to it.
+type Config struct {
+	Type string `json:"type"` // slack, webhook or mqtt
+	URL  string `json:"url"`
+	// Topic is the MQTT topic events are published to.
+	Topic string `json:"topic,omitempty"`
+	// Events lists the event kinds to fire on (change, failure, not_found,
+	// drift, eviction or all). Empty means all.
+	Events []string `json:"events,omitempty"`
+	// MinSeverity drops events below info, warning or error. Empty means info.
+	MinSeverity string `json:"min_severity,omitempty"`
+	// Template, if set, is a text/template rendering the body sent in
+	// place of the default one from the Event: Slack's text, the webhook's
+	// request body or the MQTT payload. ContentType is that of the webhook
+	// body, JSON by default.
+	Template    string `json:"template,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Digest, if set, collects the events instead of sending each, and
+	// sends them as one KindDigest summary daily at a time of day such as
+	// "08:00", or after a period such as "6h" from the first one.
+	Digest string `json:"digest,omitempty"`
+	// QuietHours, such as "22:00-07:00", holds back events other than
+	// errors while they last, adding them to the digest, or sending them
+	// as one when they end for notifiers without one.
+	QuietHours string `json:"quiet_hours,omitempty"`
+}
+
+// Event is a single notable occurrence during a cycle.
+type Event struct {
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	MAC      string    `json:"mac,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	GroupID  string    `json:"group_id,omitempty"`
+	OldIPv6  string    `json:"old_ipv6,omitempty"`
+	NewIPv6  string    `json:"new_ipv6,omitempty"`
+	Time     time.Time `json:"time"`
+	// GroupName is the name of the firewall group GroupID, when it is one.
+	GroupName string `json:"group_name,omitempty"`
+	// Added and Removed are the members the change added to and removed
+	// from the client's entries, or for drift those someone else did.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Events are the events a KindDigest summary covers.
+	Events []Event `json:"events,omitempty"`
+	// Hostnames are the names the new addresses resolve back to, by
+	// address, when resolving them is enabled.
+	Hostnames map[string]string `json:"hostnames,omitempty"`
+}
+
+// wants reports whether the notifier's policy accepts ev.
+func (n Config) wants(ev Event) bool {
+	if len(n.Events) > 0 && !slices.Contains(n.Events, KindAll) && !slices.Contains(n.Events, ev.Kind) {
+		return false
+	}
+	if n.MinSeverity == "" {
+		return true
+	}
+	return slices.Index(severities, ev.Severity) >= slices.Index(severities, n.MinSeverity)
+}
+
+// Validate checks the notifier's type and policy values.
+func (n Config) Validate() error {
+	switch n.Type {
+	case "slack", "webhook":
+	case "mqtt":
+		if n.Topic == "" {
+			return fmt.Errorf("mqtt notifier %s: topic is required", n.URL)
+		}
+	default:
+		return fmt.Errorf("unknown notifier type %q", n.Type)
+	}
+	for _, k := range n.Events {
+		if !slices.Contains([]string{KindChange, KindFailure, KindNotFound, KindDrift, KindEviction, KindAll}, k) {
+			return fmt.Errorf("%s notifier: unknown event %q", n.Type, k)
+		}
+	}
+	if n.MinSeverity != "" && !slices.Contains(severities, n.MinSeverity) {
+		return fmt.Errorf("%s notifier: unknown min_severity %q", n.Type, n.MinSeverity)
+	}
+	if !ValidDigest(n.Digest) {
+		return fmt.Errorf("%s notifier: invalid digest %q, use a time of day such as 08:00 or a period such as 6h", n.Type, n.Digest)
+	}
+	if !ValidQuietHours(n.QuietHours) {
+		return fmt.Errorf("%s notifier: invalid quiet_hours %q, use a range of times of day such as 22:00-07:00", n.Type, n.QuietHours)
+	}
+	// a trial run catches fields Event doesn't have
+	if t, err := n.template(); err != nil {
+		return fmt.Errorf("%s notifier: %w", n.Type, err)
+	} else if t != nil {
+		if err := t.Execute(io.Discard, Event{}); err != nil {
+			return fmt.Errorf("%s notifier: %w", n.Type, err)
+		}
+	}
+	return nil
+}
+
+// templateFuncs are the functions templates can use beyond the built-in
+// ones: join for lists, e.g. {{join .Added ", "}}, and json to quote a
+// value in a JSON body, e.g. {"text": {{json .Message}}}.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// template parses the notifier's Template, nil when it has none.
+func (n Config) template() (*template.Template, error) {
+	if n.Template == "" {
+		return nil, nil
+	}
+	return template.New("notifier").Funcs(templateFuncs).Option("missingkey=zero").Parse(n.Template)
+}
+
+// render returns ev formatted by the notifier's Template, or false when it
+// has none.
+func (n Config) render(ev Event) ([]byte, bool, error) {
+	t, err := n.template()
+	if t == nil || err != nil {
+		return nil, false, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ev); err != nil {
+		return nil, true, fmt.Errorf("template: %w", err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// Send delivers ev to every notifier whose policy accepts it and to all
+// event subscribers, or adds it to the notifier's digest. Delivery failures
+// are logged but never fail the cycle.
+func Send(notifiers []Config, ev Event) {
+	ev.Time = time.Now()
+	publish(ev)
+	for _, n := range notifiers {
+		if !n.wants(ev) {
+			continue
+		}
+		if quiet, end := n.quiet(ev.Time); quiet && ev.Severity != "error" {
+			if n.Digest != "" {
+				end = nextDigest(n.Digest, ev.Time)
+			}
+			n.collect(ev, end)
+			continue
+		}
+		if n.Digest != "" {
+			n.collect(ev, nextDigest(n.Digest, ev.Time))
+			continue
+		}
+		if err := n.send(ev); err != nil {
+			fmt.Printf("⚠️  Failed to send %s notification: %v\n", n.Type, err)
+		}
+	}
+}
+
+func (n Config) send(ev Event) error {
+	body, templated, err := n.render(ev)
+	if err != nil {
+		return err
+	}
+	switch n.Type {
+	case "slack":
+		text := ev.Message
+		if templated {
+			text = string(body)
+		}
+		return postJSON(n.URL, map[string]string{"text": text})
+	case "webhook":
+		if templated {
+			return post(n.URL, cmp.Or(n.ContentType, "application/json"), body)
+		}
+		return postJSON(n.URL, ev)
+	case "mqtt":
+		if !templated {
+			if body, err = json.Marshal(ev); err != nil {
+				return err
+			}
+		}
+		return mqttPublish(n.URL, n.Topic, body)
+	}
+	return fmt.Errorf("unknown notifier type %q", n.Type)
+}
+
+func postJSON(url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return post(url, "application/json", body)
+}
+
+func post(url, contentType string, body []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   = map[chan Event]struct{}{}
+)
+
+// Subscribe returns a channel receiving every event from now on and a
+// function ending the subscription. Events are dropped for subscribers that
+// fall behind rather than holding up the cycle.
+func Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	subscribersMu.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersMu.Unlock()
+	return ch, func() {
+		subscribersMu.Lock()
+		delete(subscribers, ch)
+		subscribersMu.Unlock()
+	}
+}
+
+func publish(ev Event) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestWants(t *testing.T) {
+	slack := Config{Type: "slack", Events: []string{KindFailure}, MinSeverity: "error"}
+	mqtt := Config{Type: "mqtt", Topic: "events", Events: []string{KindAll}}
+	tests := []struct {
+		ev          Event
+		slack, mqtt bool
+	}{
+		{ev: Event{Kind: KindChange, Severity: "info"}, mqtt: true},
+		{ev: Event{Kind: KindNotFound, Severity: "warning"}, mqtt: true},
+		{ev: Event{Kind: KindDrift, Severity: "warning"}, mqtt: true},
+		{ev: Event{Kind: KindFailure, Severity: "warning"}, mqtt: true},
+		{ev: Event{Kind: KindEviction, Severity: "warning"}, mqtt: true},
+		{ev: Event{Kind: KindFailure, Severity: "error"}, slack: true, mqtt: true},
+	}
+	for _, tt := range tests {
+		if got := slack.wants(tt.ev); got != tt.slack {
+			t.Errorf("slack wants %s %s = %v, want %v", tt.ev.Severity, tt.ev.Kind, got, tt.slack)
+		}
+		if got := mqtt.wants(tt.ev); got != tt.mqtt {
+			t.Errorf("mqtt wants %s %s = %v, want %v", tt.ev.Severity, tt.ev.Kind, got, tt.mqtt)
+		}
+	}
+}
+
+func TestSendRoutes(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		if text, ok := body["text"].(string); ok {
+			got[r.URL.Path] = append(got[r.URL.Path], text)
+		} else {
+			got[r.URL.Path] = append(got[r.URL.Path], body["message"].(string))
+		}
+	}))
+	defer srv.Close()
+
+	notifiers := []Config{
+		{Type: "slack", URL: srv.URL + "/slack", Events: []string{KindFailure}},
+		{Type: "webhook", URL: srv.URL + "/webhook"},
+	}
+	for _, n := range notifiers {
+		if err := n.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Send(notifiers, Event{Kind: KindChange, Severity: "info", Message: "changed"})
+	Send(notifiers, Event{Kind: KindFailure, Severity: "error", Message: "failed"})
+
+	if want := []string{"failed"}; !slices.Equal(got["/slack"], want) {
+		t.Errorf("slack got %v, want %v", got["/slack"], want)
+	}
+	if want := []string{"changed", "failed"}; !slices.Equal(got["/webhook"], want) {
+		t.Errorf("webhook got %v, want %v", got["/webhook"], want)
+	}
+}
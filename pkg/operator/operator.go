@@ -0,0 +1,284 @@
+// Package operator keeps the tracked clients as ClientFirewallEntry custom
+// resources instead of a config file, so they can be managed like any other
+// Kubernetes object and report how they were last synced in their status.
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/kube"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/updater"
+)
+
+// The API group, version and resource of ClientFirewallEntry, as defined by
+// deploy/crd.yaml.
+const (
+	Group    = "unifi.brendann993.github.io"
+	Version  = "v1alpha1"
+	Resource = "clientfirewallentries"
+)
+
+// ConditionSynced is the condition reporting the outcome of the last cycle.
+const ConditionSynced = "Synced"
+
+// Entry is a ClientFirewallEntry resource.
+type Entry struct {
+	Metadata struct {
+		Name       string `json:"name"`
+		Namespace  string `json:"namespace"`
+		Generation int64  `json:"generation"`
+	} `json:"metadata"`
+	Spec   EntrySpec   `json:"spec"`
+	Status EntryStatus `json:"status"`
+}
+
+// EntrySpec is a tracked client, as in the config file's clients.
+type EntrySpec struct {
+	MAC      string                `json:"mac"`
+	GroupID  string                `json:"groupID"`
+	Target   string                `json:"target,omitempty"`
+	Also     []updater.Destination `json:"also,omitempty"`
+	TrackIID bool                  `json:"trackIID,omitempty"`
+	Enabled  *bool                 `json:"enabled,omitempty"`
+	Interval int                   `json:"interval,omitempty"`
+	Prefer   string                `json:"prefer,omitempty"`
+	AllowULA *bool                 `json:"allowULA,omitempty"`
+
+	MaxAddresses int    `json:"maxAddresses,omitempty"`
+	Site         string `json:"site,omitempty"`
+	Controller   string `json:"controller,omitempty"`
+	AllowEmpty   bool   `json:"allowEmpty,omitempty"`
+	Drift        string `json:"drift,omitempty"`
+	Verify       string `json:"verify,omitempty"`
+	Overlap      *int   `json:"overlap,omitempty"`
+}
+
+// EntryStatus is the last synced address and the outcome of the last cycle.
+type EntryStatus struct {
+	LastIPv6           string                    `json:"lastIPv6,omitempty"`
+	Addresses          []string                  `json:"addresses,omitempty"`
+	IID                string                    `json:"iid,omitempty"`
+	Retiring           []updater.RetiringAddress `json:"retiring,omitempty"`
+	ObservedGeneration int64                     `json:"observedGeneration,omitempty"`
+	Conditions         []Condition               `json:"conditions,omitempty"`
+}
+
+// Condition is a standard Kubernetes status condition.
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+// Store loads the tracked clients from ClientFirewallEntry resources and
+// saves their addresses to the resources' status. Notifiers, targets and
+// other controllers still come from the config file, if there is one; its
+// clients are ignored. Renumbered prefixes are only kept in memory.
+type Store struct {
+	client     *kube.Client
+	namespace  string
+	configPath string
+
+	mu       sync.Mutex
+	entries  []Entry // as of the last Load, in the order of its clients
+	prefixes []updater.PrefixMove
+}
+
+// NewStore returns a store for the resources in namespace (the pod's own
+// when empty), with notifiers and targets from the config file at
+// configPath.
+func NewStore(client *kube.Client, namespace, configPath string) *Store {
+	if namespace == "" {
+		namespace = client.Namespace()
+	}
+	return &Store{client: client, namespace: namespace, configPath: configPath}
+}
+
+func (s *Store) path() string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, url.PathEscape(s.namespace), Resource)
+}
+
+// List returns the resources in the store's namespace.
+func (s *Store) List() ([]Entry, error) {
+	var list struct {
+		Items []Entry `json:"items"`
+	}
+	if err := s.client.Do("GET", s.path(), nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (s *Store) Load() (*updater.Config, error) {
+	cfg := &updater.Config{}
+	if s.configPath != "" {
+		c, err := updater.LoadConfig(s.configPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if c != nil {
+			cfg.Notifiers, cfg.Targets, cfg.Controllers, cfg.GroupLimits = c.Notifiers, c.Targets, c.Controllers, c.GroupLimits
+		}
+	}
+
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		cfg.Clients = append(cfg.Clients, updater.ClientConfig{
+			MAC:      e.Spec.MAC,
+			Name:     e.Metadata.Name,
+			GroupID:  e.Spec.GroupID,
+			Target:   e.Spec.Target,
+			Also:     e.Spec.Also,
+			TrackIID: e.Spec.TrackIID,
+			Enabled:  e.Spec.Enabled,
+			Interval: e.Spec.Interval,
+			Prefer:   e.Spec.Prefer,
+			AllowULA: e.Spec.AllowULA,
+			Site:     e.Spec.Site,
+			LastIPv6: e.Status.LastIPv6,
+			IID:      e.Status.IID,
+
+			Controller:   e.Spec.Controller,
+			MaxAddresses: e.Spec.MaxAddresses,
+			Addresses:    e.Status.Addresses,
+			AllowEmpty:   e.Spec.AllowEmpty,
+			Drift:        e.Spec.Drift,
+			Verify:       e.Spec.Verify,
+			Overlap:      e.Spec.Overlap,
+			Retiring:     e.Status.Retiring,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+	cfg.RenumberedPrefixes = s.prefixes
+	return cfg, nil
+}
+
+// Save writes changed addresses to the resources' status. Clients can't be
+// added or removed through it: that is done by creating and deleting
+// resources.
+func (s *Store) Save(cfg *updater.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(cfg.Clients) != len(s.entries) {
+		return errors.New("clients are managed as ClientFirewallEntry resources")
+	}
+	var errs []error
+	for i, c := range cfg.Clients {
+		e := &s.entries[i]
+		if !strings.EqualFold(c.MAC, e.Spec.MAC) || c.GroupID != e.Spec.GroupID {
+			return errors.New("clients are managed as ClientFirewallEntry resources")
+		}
+		if c.LastIPv6 == e.Status.LastIPv6 && c.IID == e.Status.IID && slices.Equal(c.Addresses, e.Status.Addresses) &&
+			slices.Equal(c.Retiring, e.Status.Retiring) {
+			continue
+		}
+		err := s.patchStatus(e, map[string]any{"lastIPv6": c.LastIPv6, "iid": c.IID, "addresses": c.Addresses, "retiring": c.Retiring})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		e.Status.LastIPv6, e.Status.IID, e.Status.Addresses, e.Status.Retiring = c.LastIPv6, c.IID, c.Addresses, c.Retiring
+	}
+	s.prefixes = cfg.RenumberedPrefixes
+	return errors.Join(errs...)
+}
+
+// Report sets the Synced condition of every resource from the outcome of
+// a cycle. Resources whose condition is unchanged are left alone.
+func (s *Store) Report(st updater.Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var errs []error
+	for i := range s.entries {
+		e := &s.entries[i]
+		var cs *updater.ClientStatus
+		for j, c := range st.Clients {
+			if strings.EqualFold(c.MAC, e.Spec.MAC) && c.GroupID == e.Spec.GroupID {
+				cs = &st.Clients[j]
+				break
+			}
+		}
+		if cs == nil || cs.Result == updater.ResultNotDue {
+			continue
+		}
+
+		cond := syncedCondition(*cs)
+		cond.ObservedGeneration = e.Metadata.Generation
+		cond.LastTransitionTime = now
+		if prev := findCondition(e.Status.Conditions, ConditionSynced); prev != nil {
+			if prev.Status == cond.Status && prev.Reason == cond.Reason && prev.Message == cond.Message &&
+				prev.ObservedGeneration == cond.ObservedGeneration {
+				continue
+			}
+			if prev.Status == cond.Status {
+				cond.LastTransitionTime = prev.LastTransitionTime
+			}
+		}
+		conditions := []Condition{cond}
+		err := s.patchStatus(e, map[string]any{"observedGeneration": e.Metadata.Generation, "conditions": conditions})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		e.Status.ObservedGeneration, e.Status.Conditions = e.Metadata.Generation, conditions
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Store) patchStatus(e *Entry, status map[string]any) error {
+	path := s.path() + "/" + url.PathEscape(e.Metadata.Name) + "/status"
+	if err := s.client.Do("PATCH", path, map[string]any{"status": status}, nil); err != nil {
+		return fmt.Errorf("%s: %w", e.Metadata.Name, err)
+	}
+	return nil
+}
+
+// syncedCondition describes a client's result as the Synced condition.
+func syncedCondition(cs updater.ClientStatus) Condition {
+	c := Condition{Type: ConditionSynced, Status: "False"}
+	switch cs.Result {
+	case updater.ResultUnchanged:
+		c.Status, c.Reason, c.Message = "True", "Unchanged", cs.IPv6
+	case updater.ResultUpdated:
+		c.Status, c.Reason, c.Message = "True", "Updated", cs.IPv6
+	case updater.ResultNotFound:
+		c.Reason, c.Message = "NotFound", "client is not known to the controller"
+	case updater.ResultNoIPv6:
+		c.Reason, c.Message = "NoIPv6", "client has no global IPv6 address"
+	case updater.ResultPaused:
+		c.Status, c.Reason, c.Message = "Unknown", "Paused", "updates are paused"
+	case updater.ResultDisabled:
+		c.Status, c.Reason, c.Message = "Unknown", "Disabled", "the client is disabled"
+	default:
+		c.Reason, c.Message = "Failed", cs.Error
+	}
+	return c
+}
+
+func findCondition(conditions []Condition, t string) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == t {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
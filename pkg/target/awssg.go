@@ -0,0 +1,98 @@
+package target
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// awsManagedDescription marks the security group rules the target owns, so
+// rules added by hand on the same port are left alone.
+const awsManagedDescription = "managed by unifi-ipv6-client-firewall-updater"
+
+// SecurityGroup publishes addresses as the source of an ingress rule of an
+// AWS EC2 security group.
+type SecurityGroup struct {
+	api      *awsAPI
+	protocol string
+	port     int
+}
+
+type ec2Permission struct {
+	Protocol string `xml:"ipProtocol"`
+	FromPort int    `xml:"fromPort"`
+	ToPort   int    `xml:"toPort"`
+	Ranges   []struct {
+		CIDR        string `xml:"cidrIpv6"`
+		Description string `xml:"description"`
+	} `xml:"ipv6Ranges>item"`
+}
+
+// Update makes ipv6/128 the only source of the managed ingress rule on the
+// target's port in the security group groupID, authorising the new address
+// before revoking the old ones.
+func (s *SecurityGroup) Update(groupID, ipv6 string) (bool, error) {
+	var resp struct {
+		Groups []struct {
+			Permissions []ec2Permission `xml:"ipPermissions>item"`
+		} `xml:"securityGroupInfo>item"`
+	}
+	err := s.call(url.Values{"Action": {"DescribeSecurityGroups"}, "GroupId.1": {groupID}}, &resp)
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Groups) == 0 {
+		return false, fmt.Errorf("security group %s not found", groupID)
+	}
+
+	want := ipv6 + "/128"
+	var managed []string
+	for _, p := range resp.Groups[0].Permissions {
+		if p.Protocol != s.protocol || p.FromPort != s.port || p.ToPort != s.port {
+			continue
+		}
+		for _, r := range p.Ranges {
+			if r.Description == awsManagedDescription {
+				managed = append(managed, r.CIDR)
+			}
+		}
+	}
+	if slices.Equal(managed, []string{want}) {
+		return false, nil
+	}
+
+	if !slices.Contains(managed, want) {
+		params := s.permission("AuthorizeSecurityGroupIngress", groupID, want)
+		params.Set("IpPermissions.1.Ipv6Ranges.1.Description", awsManagedDescription)
+		if err := s.call(params, nil); err != nil {
+			return false, err
+		}
+	}
+	for _, cidr := range managed {
+		if cidr == want {
+			continue
+		}
+		if err := s.call(s.permission("RevokeSecurityGroupIngress", groupID, cidr), nil); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (s *SecurityGroup) permission(action, groupID, cidr string) url.Values {
+	port := strconv.Itoa(s.port)
+	return url.Values{
+		"Action":                                {action},
+		"GroupId":                               {groupID},
+		"IpPermissions.1.IpProtocol":            {s.protocol},
+		"IpPermissions.1.FromPort":              {port},
+		"IpPermissions.1.ToPort":                {port},
+		"IpPermissions.1.Ipv6Ranges.1.CidrIpv6": {cidr},
+	}
+}
+
+func (s *SecurityGroup) call(params url.Values, out any) error {
+	params.Set("Version", "2016-11-15")
+	return s.api.do("POST", "/", "application/x-www-form-urlencoded; charset=utf-8", []byte(params.Encode()), out)
+}
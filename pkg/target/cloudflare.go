@@ -0,0 +1,53 @@
+package target
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type cfRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// Cloudflare publishes addresses as AAAA records in a Cloudflare zone,
+// authenticating with an API token allowed to edit the zone's DNS.
+type Cloudflare struct {
+	api  *api
+	zone string
+	ttl  int
+}
+
+// Update makes ipv6 the only AAAA record for name, creating it if needed.
+func (cf *Cloudflare) Update(name, ipv6 string) (bool, error) {
+	base := "/zones/" + url.PathEscape(cf.zone) + "/dns_records"
+	var list struct {
+		Result []cfRecord `json:"result"`
+	}
+	if err := cf.api.call("GET", base+"?type=AAAA&name="+url.QueryEscape(name), nil, &list); err != nil {
+		return false, err
+	}
+	if len(list.Result) == 1 && list.Result[0].Content == ipv6 {
+		return false, nil
+	}
+
+	record := cfRecord{Type: "AAAA", Name: name, Content: ipv6, TTL: cf.ttl}
+	if len(list.Result) == 0 {
+		if err := cf.api.call("POST", base, record, nil); err != nil {
+			return false, fmt.Errorf("create record %s: %w", name, err)
+		}
+		return true, nil
+	}
+	if err := cf.api.call("PUT", base+"/"+list.Result[0].ID, record, nil); err != nil {
+		return false, fmt.Errorf("update record %s: %w", name, err)
+	}
+	for _, r := range list.Result[1:] {
+		if err := cf.api.call("DELETE", base+"/"+r.ID, nil, nil); err != nil {
+			return true, fmt.Errorf("delete record %s: %w", name, err)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package target
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// dynDNSURLs are the update endpoints of the supported providers.
+var dynDNSURLs = map[string]string{
+	"duckdns": "https://www.duckdns.org",
+	"desec":   "https://update.dedyn.io",
+	"dynv6":   "https://dynv6.com",
+}
+
+// DynDNS publishes addresses as the AAAA record of a hostname at a token
+// based dynamic DNS provider: DuckDNS, deSEC or dynv6. Only the IPv6 address
+// is set; the IPv4 one is left alone.
+type DynDNS struct {
+	api      *api
+	provider string
+	token    string
+}
+
+// Update sets ipv6 as the address of hostname.
+func (d *DynDNS) Update(hostname, ipv6 string) (bool, error) {
+	var path string
+	switch d.provider {
+	case "duckdns":
+		q := url.Values{"domains": {strings.TrimSuffix(hostname, ".duckdns.org")}, "token": {d.token}, "ipv6": {ipv6}, "verbose": {"true"}}
+		path = "/update?" + q.Encode()
+	case "desec":
+		// deSEC takes the token as a header and would otherwise set the
+		// IPv4 address to the one the request came from
+		q := url.Values{"hostname": {hostname}, "myipv4": {"preserve"}, "myipv6": {ipv6}}
+		path = "/?" + q.Encode()
+	case "dynv6":
+		q := url.Values{"hostname": {hostname}, "token": {d.token}, "ipv6": {ipv6}}
+		path = "/api/update?" + q.Encode()
+	}
+
+	data, err := d.api.raw("GET", path, nil)
+	if err != nil {
+		// the token is part of the URL, keep it out of logs and alerts
+		return false, errors.New(strings.ReplaceAll(err.Error(), url.QueryEscape(d.token), "REDACTED"))
+	}
+	reply := strings.TrimSpace(string(data))
+
+	switch d.provider {
+	case "duckdns":
+		// verbose replies are OK, the IPv4 and IPv6 addresses and
+		// UPDATED or NOCHANGE, one per line
+		lines := strings.Fields(reply)
+		if len(lines) == 0 || lines[0] != "OK" {
+			return false, fmt.Errorf("duckdns: update of %s refused: %s", hostname, reply)
+		}
+		return lines[len(lines)-1] != "NOCHANGE", nil
+	case "desec":
+		switch {
+		case strings.HasPrefix(reply, "good"):
+			return true, nil
+		case strings.HasPrefix(reply, "nochg"):
+			return false, nil
+		}
+		return false, fmt.Errorf("desec: update of %s refused: %s", hostname, reply)
+	default:
+		switch reply {
+		case "addresses updated":
+			return true, nil
+		case "addresses unchanged":
+			return false, nil
+		}
+		return false, fmt.Errorf("dynv6: update of %s refused: %s", hostname, reply)
+	}
+}
@@ -0,0 +1,51 @@
+package target
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MikroTik publishes addresses as the entries of an IPv6 firewall
+// address-list through the RouterOS v7 REST API, authenticating with a
+// username and password.
+type MikroTik struct {
+	api *api
+}
+
+// Update makes ipv6 the only entry of the address-list named list, adding
+// the new entry before removing the old ones so the list is never empty.
+func (m *MikroTik) Update(list, ipv6 string) (bool, error) {
+	var entries []struct {
+		ID      string `json:".id"`
+		Address string `json:"address"`
+	}
+	if err := m.api.call("GET", "/rest/ipv6/firewall/address-list?list="+url.QueryEscape(list), nil, &entries); err != nil {
+		return false, err
+	}
+
+	have := false
+	var stale []string
+	for _, e := range entries {
+		if strings.TrimSuffix(e.Address, "/128") == ipv6 {
+			have = true
+			continue
+		}
+		stale = append(stale, e.ID)
+	}
+	if have && len(stale) == 0 {
+		return false, nil
+	}
+
+	if !have {
+		body := map[string]string{"list": list, "address": ipv6, "comment": "unifi-ipv6-client-firewall-updater"}
+		if err := m.api.call("PUT", "/rest/ipv6/firewall/address-list", body, nil); err != nil {
+			return false, err
+		}
+	}
+	for _, id := range stale {
+		if err := m.api.call("DELETE", "/rest/ipv6/firewall/address-list/"+id, nil, nil); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
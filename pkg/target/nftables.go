@@ -0,0 +1,107 @@
+package target
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// NFTables publishes addresses as the elements of a named set in the local
+// nftables ruleset, through the nft command. The process needs
+// CAP_NET_ADMIN.
+type NFTables struct {
+	family string
+	table  string
+}
+
+// Update makes ipv6 the only element of the set named set, replacing the
+// elements in a single transaction.
+func (n *NFTables) Update(set, ipv6 string) (bool, error) {
+	out, err := run(nil, "nft", "-j", "list", "set", n.family, n.table, set)
+	if err != nil {
+		return false, err
+	}
+	var list struct {
+		Nftables []struct {
+			Set *struct {
+				Elem []json.RawMessage `json:"elem"`
+			} `json:"set"`
+		} `json:"nftables"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return false, fmt.Errorf("parse nft output: %w", err)
+	}
+	var current []string
+	for _, item := range list.Nftables {
+		if item.Set == nil {
+			continue
+		}
+		for _, e := range item.Set.Elem {
+			// plain addresses are strings; prefixes and elements with
+			// timeouts are objects, which never match a single address
+			var addr string
+			if json.Unmarshal(e, &addr) == nil {
+				current = append(current, addr)
+			} else {
+				current = append(current, string(e))
+			}
+		}
+	}
+	if slices.Equal(current, []string{ipv6}) {
+		return false, nil
+	}
+
+	script := fmt.Sprintf("flush set %[1]s %[2]s %[3]s\nadd element %[1]s %[2]s %[3]s { %[4]s }\n", n.family, n.table, set, ipv6)
+	if _, err := run(strings.NewReader(script), "nft", "-f", "-"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IPSet publishes addresses as the members of a local ipset, as used by
+// ip6tables rules with -m set. The set must be of family inet6.
+type IPSet struct{}
+
+// Update makes ipv6 the only member of the set named set, replacing the
+// members in a single restore.
+func (IPSet) Update(set, ipv6 string) (bool, error) {
+	out, err := run(nil, "ipset", "list", set)
+	if err != nil {
+		return false, err
+	}
+	var current []string
+	_, members, _ := strings.Cut(string(out), "Members:\n")
+	for _, line := range strings.Split(members, "\n") {
+		if f := strings.Fields(line); len(f) > 0 {
+			current = append(current, f[0])
+		}
+	}
+	if slices.Equal(current, []string{ipv6}) {
+		return false, nil
+	}
+
+	script := fmt.Sprintf("flush %[1]s\nadd %[1]s %[2]s\n", set, ipv6)
+	if _, err := run(strings.NewReader(script), "ipset", "restore"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// run runs a command with stdin and returns its output, folding stderr
+// into the error.
+func run(stdin *strings.Reader, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package target
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// OPNsense publishes addresses as the content of host aliases through the
+// OPNsense API, authenticating with an API key and secret.
+type OPNsense struct {
+	api *api
+}
+
+// Update makes ipv6 the only entry of the alias named alias and applies the
+// change.
+func (o *OPNsense) Update(alias, ipv6 string) (bool, error) {
+	// an unknown alias is answered with an empty array rather than an object
+	var raw json.RawMessage
+	if err := o.api.call("GET", "/api/firewall/alias/getAliasUUID/"+url.PathEscape(alias), nil, &raw); err != nil {
+		return false, err
+	}
+	var found struct {
+		UUID string `json:"uuid"`
+	}
+	json.Unmarshal(raw, &found)
+	if found.UUID == "" {
+		return false, fmt.Errorf("alias %s not found", alias)
+	}
+
+	// content is returned as the selectable options, with the current
+	// entries marked selected
+	var item struct {
+		Alias struct {
+			Content map[string]struct {
+				Selected int `json:"selected"`
+			} `json:"content"`
+		} `json:"alias"`
+	}
+	if err := o.api.call("GET", "/api/firewall/alias/getItem/"+found.UUID, nil, &item); err != nil {
+		return false, err
+	}
+	var current []string
+	for v, opt := range item.Alias.Content {
+		if opt.Selected == 1 && v != "" {
+			current = append(current, v)
+		}
+	}
+	if slices.Equal(current, []string{ipv6}) {
+		return false, nil
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	body := map[string]any{"alias": map[string]string{"content": ipv6}}
+	if err := o.api.call("POST", "/api/firewall/alias/setItem/"+found.UUID, body, &result); err != nil {
+		return false, err
+	}
+	if result.Result != "saved" {
+		return false, fmt.Errorf("alias %s not saved: %s", alias, result.Result)
+	}
+	if err := o.api.call("POST", "/api/firewall/alias/reconfigure", map[string]any{}, nil); err != nil {
+		return false, fmt.Errorf("apply alias %s: %w", alias, err)
+	}
+	return true, nil
+}
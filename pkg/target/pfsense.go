@@ -0,0 +1,46 @@
+package target
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+type pfAlias struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Address []string `json:"address"`
+}
+
+// PfSense publishes addresses as the content of host aliases through the
+// pfSense REST API package (v2), authenticating with an API key.
+type PfSense struct {
+	api *api
+}
+
+// Update makes ipv6 the only address of the alias named alias and applies
+// the change.
+func (p *PfSense) Update(alias, ipv6 string) (bool, error) {
+	var list struct {
+		Data []pfAlias `json:"data"`
+	}
+	if err := p.api.call("GET", "/api/v2/firewall/aliases?name="+url.QueryEscape(alias), nil, &list); err != nil {
+		return false, err
+	}
+	i := slices.IndexFunc(list.Data, func(a pfAlias) bool { return a.Name == alias })
+	if i < 0 {
+		return false, fmt.Errorf("alias %s not found", alias)
+	}
+	if slices.Equal(list.Data[i].Address, []string{ipv6}) {
+		return false, nil
+	}
+
+	body := map[string]any{"id": list.Data[i].ID, "address": []string{ipv6}}
+	if err := p.api.call("PATCH", "/api/v2/firewall/alias", body, nil); err != nil {
+		return false, err
+	}
+	if err := p.api.call("POST", "/api/v2/firewall/apply", map[string]any{}, nil); err != nil {
+		return false, fmt.Errorf("apply alias %s: %w", alias, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,263 @@
+package target
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DNS wire format constants used by the dynamic update target.
+const (
+	dnsTypeSOA  = 6
+	dnsTypeAAAA = 28
+	dnsTypeTSIG = 250
+	dnsClassIN  = 1
+	dnsClassANY = 255
+
+	dnsOpcodeUpdate = 5
+)
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1":   sha1.New,
+	"hmac-sha256": sha256.New,
+	"hmac-sha512": sha512.New,
+}
+
+var dnsRcodes = []string{"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED", "YXDOMAIN", "YXRRSET", "NXRRSET", "NOTAUTH", "NOTZONE"}
+
+// RFC2136 publishes addresses as AAAA records on an authoritative DNS
+// server through TSIG-signed dynamic updates (RFC 2136), as supported by
+// BIND, Knot, PowerDNS and others. Messages are sent over TCP.
+type RFC2136 struct {
+	server    string
+	zone      string
+	ttl       int
+	keyName   string
+	secret    []byte
+	algorithm string
+}
+
+// Update replaces the AAAA records of name with ipv6, unless the server
+// already answers with exactly that address.
+func (r *RFC2136) Update(name, ipv6 string) (bool, error) {
+	ip := net.ParseIP(ipv6)
+	if ip == nil {
+		return false, fmt.Errorf("invalid address %q", ipv6)
+	}
+	if current, err := r.lookup(name); err == nil && slices.Equal(current, []string{ip.String()}) {
+		return false, nil
+	}
+
+	m := dnsHeader(dnsOpcodeUpdate<<11, 1, 0, 2, 0)
+	m = dnsQuestion(m, r.zone, dnsTypeSOA)
+	// delete the name's AAAA RRset, then add the new address
+	m = dnsRR(m, name, dnsTypeAAAA, dnsClassANY, 0, nil)
+	m = dnsRR(m, name, dnsTypeAAAA, dnsClassIN, uint32(r.ttl), ip.To16())
+	m, err := r.sign(m, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := r.exchange(m)
+	if err != nil {
+		return false, err
+	}
+	if rcode := int(resp[3] & 0x0f); rcode != 0 {
+		return false, fmt.Errorf("update %s: %s", name, dnsRcode(rcode))
+	}
+	return true, nil
+}
+
+// lookup returns the AAAA records the server holds for name.
+func (r *RFC2136) lookup(name string) ([]string, error) {
+	m := dnsQuestion(dnsHeader(0, 1, 0, 0, 0), name, dnsTypeAAAA)
+	resp, err := r.exchange(m)
+	if err != nil {
+		return nil, err
+	}
+	if rcode := int(resp[3] & 0x0f); rcode != 0 {
+		return nil, errors.New(dnsRcode(rcode))
+	}
+	qd, an := binary.BigEndian.Uint16(resp[4:]), binary.BigEndian.Uint16(resp[6:])
+	off := 12
+	for range qd {
+		if off, err = dnsSkipName(resp, off); err != nil {
+			return nil, err
+		}
+		off += 4
+	}
+	var addrs []string
+	for range an {
+		if off, err = dnsSkipName(resp, off); err != nil {
+			return nil, err
+		}
+		if off+10 > len(resp) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		typ := binary.BigEndian.Uint16(resp[off:])
+		rdlen := int(binary.BigEndian.Uint16(resp[off+8:]))
+		off += 10
+		if off+rdlen > len(resp) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if typ == dnsTypeAAAA && rdlen == net.IPv6len {
+			addrs = append(addrs, net.IP(resp[off:off+rdlen]).String())
+		}
+		off += rdlen
+	}
+	return addrs, nil
+}
+
+// sign appends a TSIG record (RFC 8945) to the message m.
+func (r *RFC2136) sign(m []byte, now time.Time) ([]byte, error) {
+	newHash, ok := tsigAlgorithms[r.algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", r.algorithm)
+	}
+	var timers [8]byte
+	binary.BigEndian.PutUint64(timers[:], uint64(now.Unix())<<16|300) // time signed, fudge
+
+	// the MAC covers the message and the TSIG variables
+	mac := hmac.New(newHash, r.secret)
+	mac.Write(m)
+	vars := dnsName(nil, strings.ToLower(r.keyName))
+	vars = binary.BigEndian.AppendUint16(vars, dnsClassANY)
+	vars = binary.BigEndian.AppendUint32(vars, 0)
+	vars = dnsName(vars, r.algorithm)
+	vars = append(vars, timers[:]...)
+	vars = binary.BigEndian.AppendUint32(vars, 0) // error, other length
+	mac.Write(vars)
+	sum := mac.Sum(nil)
+
+	rdata := dnsName(nil, r.algorithm)
+	rdata = append(rdata, timers[:]...)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = append(rdata, m[0], m[1])               // original ID
+	rdata = binary.BigEndian.AppendUint32(rdata, 0) // error, other length
+
+	m = dnsRR(m, strings.ToLower(r.keyName), dnsTypeTSIG, dnsClassANY, 0, rdata)
+	binary.BigEndian.PutUint16(m[10:], binary.BigEndian.Uint16(m[10:])+1)
+	return m, nil
+}
+
+// exchange sends m to the server over TCP and returns the response.
+func (r *RFC2136) exchange(m []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", r.server, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(m)))); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(m); err != nil {
+		return nil, err
+	}
+	var n [2]byte
+	if _, err := io.ReadFull(conn, n[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(n[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 || resp[0] != m[0] || resp[1] != m[1] {
+		return nil, errors.New("malformed DNS response")
+	}
+	return resp, nil
+}
+
+func dnsHeader(flags, qd, an, ns, ar uint16) []byte {
+	m := make([]byte, 2, 512)
+	_, _ = rand.Read(m)
+	for _, v := range []uint16{flags, qd, an, ns, ar} {
+		m = binary.BigEndian.AppendUint16(m, v)
+	}
+	return m
+}
+
+func dnsQuestion(m []byte, name string, typ uint16) []byte {
+	m = dnsName(m, name)
+	m = binary.BigEndian.AppendUint16(m, typ)
+	return binary.BigEndian.AppendUint16(m, dnsClassIN)
+}
+
+func dnsRR(m []byte, name string, typ, class uint16, ttl uint32, rdata []byte) []byte {
+	m = dnsName(m, name)
+	m = binary.BigEndian.AppendUint16(m, typ)
+	m = binary.BigEndian.AppendUint16(m, class)
+	m = binary.BigEndian.AppendUint32(m, ttl)
+	m = binary.BigEndian.AppendUint16(m, uint16(len(rdata)))
+	return append(m, rdata...)
+}
+
+// dnsName appends name in uncompressed wire format; it is taken as fully
+// qualified with or without the trailing dot.
+func dnsName(m []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		m = append(m, byte(len(label)))
+		m = append(m, label...)
+	}
+	return append(m, 0)
+}
+
+// dnsSkipName returns the offset just past the (possibly compressed) name
+// at off.
+func dnsSkipName(m []byte, off int) (int, error) {
+	for off < len(m) {
+		switch l := int(m[off]); {
+		case l == 0:
+			return off + 1, nil
+		case l&0xc0 == 0xc0:
+			return off + 2, nil
+		default:
+			off += l + 1
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+func dnsRcode(rcode int) string {
+	if rcode < len(dnsRcodes) {
+		return dnsRcodes[rcode]
+	}
+	return fmt.Sprintf("rcode %d", rcode)
+}
+
+func newRFC2136(c Config) (*RFC2136, error) {
+	secret, err := base64.StdEncoding.DecodeString(c.APISecret)
+	if err != nil {
+		return nil, fmt.Errorf("target %s: api_secret is not base64: %w", c.Name, err)
+	}
+	server := c.Server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	algorithm := strings.TrimSuffix(strings.ToLower(c.Algorithm), ".")
+	if algorithm == "" {
+		algorithm = "hmac-sha256"
+	}
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = 300
+	}
+	return &RFC2136{server: server, zone: c.Zone, ttl: ttl, keyName: c.APIKey, secret: secret, algorithm: algorithm}, nil
+}
@@ -0,0 +1,65 @@
+package target
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+// Route53 publishes addresses as AAAA records in an AWS Route 53 hosted
+// zone, using UPSERT change batches.
+type Route53 struct {
+	api  *awsAPI
+	zone string
+	ttl  int
+}
+
+type r53RecordSet struct {
+	Name   string   `xml:"Name"`
+	Type   string   `xml:"Type"`
+	TTL    int      `xml:"TTL"`
+	Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+}
+
+// Update makes ipv6 the only value of the AAAA record set name.
+func (r *Route53) Update(name, ipv6 string) (bool, error) {
+	fqdn := strings.TrimSuffix(name, ".") + "."
+	base := "/2013-04-01/hostedzone/" + url.PathEscape(strings.TrimPrefix(r.zone, "/hostedzone/")) + "/rrset"
+
+	var list struct {
+		Sets []r53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	query := url.Values{"name": {fqdn}, "type": {"AAAA"}, "maxitems": {"1"}}
+	if err := r.api.do("GET", base+"?"+query.Encode(), "", nil, &list); err != nil {
+		return false, err
+	}
+	if len(list.Sets) == 1 && list.Sets[0].Type == "AAAA" && strings.EqualFold(list.Sets[0].Name, fqdn) &&
+		len(list.Sets[0].Values) == 1 && list.Sets[0].Values[0] == ipv6 && list.Sets[0].TTL == r.ttl {
+		return false, nil
+	}
+
+	type change struct {
+		Action    string       `xml:"Action"`
+		RecordSet r53RecordSet `xml:"ResourceRecordSet"`
+	}
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"ChangeResourceRecordSetsRequest"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Comment string   `xml:"ChangeBatch>Comment"`
+		Changes []change `xml:"ChangeBatch>Changes>Change"`
+	}{
+		Xmlns:   route53Namespace,
+		Comment: "unifi-ipv6-client-firewall-updater",
+		Changes: []change{{Action: "UPSERT", RecordSet: r53RecordSet{Name: fqdn, Type: "AAAA", TTL: r.ttl, Values: []string{ipv6}}}},
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := r.api.do("POST", base, "application/xml", append([]byte(xml.Header), body...), nil); err != nil {
+		return false, fmt.Errorf("upsert record %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,264 @@
+// Package target implements the update targets beyond the built-in UniFi
+// firewall group: other firewalls and DNS providers the updater can publish
+// client addresses to. Targets are configured in the "targets" section of
+// the config file and selected per client by name.
+package target
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Config describes a configured target. Which fields apply depends on Type.
+type Config struct {
+	// Name is what clients select the target by.
+	Name string `json:"name"`
+	// Type is one of opnsense, pfsense, mikrotik, nftables, ipset,
+	// aws_security_group, cloudflare, route53, duckdns, desec, dynv6 or
+	// rfc2136.
+	Type string `json:"type"`
+	// URL is the base URL of the target's API. For AWS it overrides the
+	// regional endpoint.
+	URL string `json:"url,omitempty"`
+	// APIKey and APISecret authenticate to the API. pfSense, Cloudflare
+	// and the dynamic DNS providers only use the key, as their token. For
+	// AWS they are the access key ID and secret access key, read from the
+	// usual AWS_* variables when empty, and for RFC 2136 the TSIG key name
+	// and base64 secret.
+	APIKey    string `json:"api_key,omitempty"`
+	APISecret string `json:"api_secret,omitempty"`
+	// Username and Password authenticate to the MikroTik REST API.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Family and Table locate the nftables set; Family defaults to inet.
+	Family string `json:"family,omitempty"`
+	Table  string `json:"table,omitempty"`
+	// Region is the AWS region.
+	Region string `json:"region,omitempty"`
+	// Protocol and Port select the security group rule; Protocol defaults
+	// to tcp.
+	Protocol string `json:"protocol,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	// Zone is the DNS zone's ID at the provider (the hosted zone ID for
+	// Route 53, the zone name for RFC 2136).
+	Zone string `json:"zone,omitempty"`
+	// Server is the DNS server RFC 2136 updates are sent to, as host or
+	// host:port.
+	Server string `json:"server,omitempty"`
+	// Algorithm is the TSIG algorithm: hmac-sha256 (default), hmac-sha1
+	// or hmac-sha512.
+	Algorithm string `json:"algorithm,omitempty"`
+	// TTL is the TTL of DNS records, in seconds; 0 leaves it to the
+	// provider.
+	TTL int `json:"ttl,omitempty"`
+	// Insecure skips TLS certificate verification.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// Target publishes an address to the entry ref, e.g. a firewall alias name,
+// reporting whether anything had to change. It matches updater.Target.
+type Target interface {
+	Update(ref, ipv6 string) (changed bool, err error)
+}
+
+// Validate checks the target's type and required settings.
+func (c Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("%s target: name is required", c.Type)
+	}
+	switch c.Type {
+	case "opnsense":
+		if c.URL == "" || c.APIKey == "" || c.APISecret == "" {
+			return fmt.Errorf("target %s: url, api_key and api_secret are required", c.Name)
+		}
+	case "pfsense":
+		if c.URL == "" || c.APIKey == "" {
+			return fmt.Errorf("target %s: url and api_key are required", c.Name)
+		}
+	case "mikrotik":
+		if c.URL == "" || c.Username == "" {
+			return fmt.Errorf("target %s: url and username are required", c.Name)
+		}
+	case "nftables":
+		if c.Table == "" {
+			return fmt.Errorf("target %s: table is required", c.Name)
+		}
+	case "ipset":
+	case "aws_security_group":
+		if c.Region == "" || c.Port == 0 {
+			return fmt.Errorf("target %s: region and port are required", c.Name)
+		}
+	case "cloudflare":
+		if c.APIKey == "" || c.Zone == "" {
+			return fmt.Errorf("target %s: api_key and zone are required", c.Name)
+		}
+	case "route53":
+		if c.Zone == "" {
+			return fmt.Errorf("target %s: zone is required", c.Name)
+		}
+	case "duckdns", "desec", "dynv6":
+		if c.APIKey == "" {
+			return fmt.Errorf("target %s: api_key is required", c.Name)
+		}
+	case "rfc2136":
+		if c.Server == "" || c.Zone == "" || c.APIKey == "" || c.APISecret == "" {
+			return fmt.Errorf("target %s: server, zone, api_key and api_secret are required", c.Name)
+		}
+	default:
+		return fmt.Errorf("target %s: unknown type %q", c.Name, c.Type)
+	}
+	return nil
+}
+
+// New returns the target described by c.
+func New(c Config) (Target, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case "opnsense":
+		a := newAPI(c)
+		a.user, a.pass = c.APIKey, c.APISecret
+		return &OPNsense{api: a}, nil
+	case "pfsense":
+		a := newAPI(c)
+		a.header.Set("X-API-Key", c.APIKey)
+		return &PfSense{api: a}, nil
+	case "mikrotik":
+		a := newAPI(c)
+		a.user, a.pass = c.Username, c.Password
+		return &MikroTik{api: a}, nil
+	case "nftables":
+		family := c.Family
+		if family == "" {
+			family = "inet"
+		}
+		return &NFTables{family: family, table: c.Table}, nil
+	case "ipset":
+		return IPSet{}, nil
+	case "aws_security_group":
+		protocol := c.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		return &SecurityGroup{api: newAWSAPI(c, "ec2"), protocol: protocol, port: c.Port}, nil
+	case "cloudflare":
+		if c.URL == "" {
+			c.URL = "https://api.cloudflare.com/client/v4"
+		}
+		a := newAPI(c)
+		a.header.Set("Authorization", "Bearer "+c.APIKey)
+		ttl := c.TTL
+		if ttl == 0 {
+			ttl = 1 // automatic
+		}
+		return &Cloudflare{api: a, zone: c.Zone, ttl: ttl}, nil
+	case "route53":
+		// Route 53 has a single global endpoint, signed for us-east-1
+		c.Region = "us-east-1"
+		if c.URL == "" {
+			c.URL = "https://route53.amazonaws.com"
+		}
+		ttl := c.TTL
+		if ttl == 0 {
+			ttl = 300
+		}
+		return &Route53{api: newAWSAPI(c, "route53"), zone: c.Zone, ttl: ttl}, nil
+	case "duckdns", "desec", "dynv6":
+		if c.URL == "" {
+			c.URL = dynDNSURLs[c.Type]
+		}
+		a := newAPI(c)
+		if c.Type == "desec" {
+			a.header.Set("Authorization", "Token "+c.APIKey)
+		}
+		return &DynDNS{api: a, provider: c.Type, token: c.APIKey}, nil
+	case "rfc2136":
+		r, err := newRFC2136(c)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("target %s: unknown type %q", c.Name, c.Type)
+}
+
+// api is a small JSON-over-HTTP client shared by the targets.
+type api struct {
+	base   string
+	http   *http.Client
+	header http.Header
+	user   string
+	pass   string
+}
+
+func newAPI(c Config) *api {
+	return &api{
+		base: strings.TrimRight(c.URL, "/"),
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: c.Insecure}},
+		},
+		header: http.Header{},
+	}
+}
+
+// call sends in (if not nil) as JSON and decodes the response into out (if
+// not nil). Non-2xx responses are errors.
+func (a *api) call(method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	data, err := a.raw(method, path, body)
+	if err != nil || out == nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// raw sends body (if not nil) as JSON and returns the response body.
+// Non-2xx responses are errors.
+func (a *api) raw(method, path string, body []byte) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, a.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range a.header {
+		req.Header[k] = v
+	}
+	if a.user != "" {
+		req.SetBasicAuth(a.user, a.pass)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, data)
+	}
+	return data, nil
+}
@@ -0,0 +1,159 @@
+package unifi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// cachedResponse is the last decoded response of a listing endpoint.
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	sum          [sha256.Size]byte
+	value        any
+}
+
+// page is one response of a listing: the items kept, how many were read
+// and, for paginated endpoints, how many there are in all.
+type page[T any] struct {
+	items []T
+	read  int
+	total *int
+}
+
+// getList GETs the listing at url and decodes its items, keeping only
+// those keep returns true for, or all with a nil keep. filter names what
+// keep keeps, so results filtered differently are cached apart.
+//
+// The previous result is reused when nothing changed: the request carries
+// the last ETag/Last-Modified so the controller can answer 304, and for
+// controllers that don't support that, the body is hashed before it is
+// decoded and one hashing the same isn't decoded again. The returned items
+// are shared with later calls and must not be modified.
+func getList[T any](c *Client, url string, keep func(T) bool, filter string) (page[T], error) {
+	key := url + "\x00" + filter
+	c.cacheMu.Lock()
+	prev := c.cache[key]
+	c.cacheMu.Unlock()
+
+	header := http.Header{}
+	if prev != nil {
+		if prev.etag != "" {
+			header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	resp, err := c.open("GET", url, nil, header)
+	if err != nil {
+		return page[T]{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		if v, ok := prev.value.(page[T]); ok {
+			return v, nil
+		}
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return page[T]{}, &APIError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return page[T]{}, err
+	}
+	sum := sha256.Sum256(data)
+	if prev != nil && prev.sum == sum {
+		if v, ok := prev.value.(page[T]); ok {
+			return v, nil
+		}
+	}
+	p, err := decodeList(bytes.NewReader(data), keep)
+	if err != nil {
+		return page[T]{}, err
+	}
+
+	c.cacheMu.Lock()
+	c.cache[key] = &cachedResponse{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		sum:          sum,
+		value:        p,
+	}
+	c.cacheMu.Unlock()
+	return p, nil
+}
+
+// decodeList decodes a listing from r as it is read: either a bare array
+// of items, or an object with them in "data" and, when paginated, their
+// total in "totalCount". Items keep returns false for are dropped as soon
+// as they are decoded.
+func decodeList[T any](r io.Reader, keep func(T) bool) (page[T], error) {
+	var p page[T]
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return p, err
+	}
+	if tok == json.Delim('[') {
+		return p, decodeItems(dec, &p, keep)
+	}
+	if tok != json.Delim('{') {
+		return p, fmt.Errorf("unexpected %v at the start of a listing", tok)
+	}
+	for dec.More() {
+		name, err := dec.Token()
+		if err != nil {
+			return p, err
+		}
+		switch name {
+		case "data":
+			tok, err := dec.Token()
+			if err != nil {
+				return p, err
+			}
+			if tok == json.Delim('[') {
+				if err := decodeItems(dec, &p, keep); err != nil {
+					return p, err
+				}
+			} else if tok != nil {
+				return p, fmt.Errorf("unexpected %v as the data of a listing", tok)
+			}
+		case "totalCount":
+			if err := dec.Decode(&p.total); err != nil {
+				return p, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return p, err
+			}
+		}
+	}
+	_, err = dec.Token() // the closing brace
+	return p, err
+}
+
+// decodeItems decodes the items of an array whose opening bracket has been
+// read, up to and including its closing one.
+func decodeItems[T any](dec *json.Decoder, p *page[T], keep func(T) bool) error {
+	for dec.More() {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		p.read++
+		if keep == nil || keep(v) {
+			p.items = append(p.items, v)
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
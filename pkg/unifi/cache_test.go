@@ -0,0 +1,137 @@
+package unifi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// activeClients returns a /v2 active-clients response of n clients, each
+// with the many fields a controller reports besides those decoded.
+func activeClients(n int) []byte {
+	clients := make([]map[string]any, n)
+	for i := range clients {
+		mac := fmt.Sprintf("02:00:00:%02x:%02x:%02x", i>>16&0xff, i>>8&0xff, i&0xff)
+		c := map[string]any{
+			"mac":            mac,
+			"name":           fmt.Sprintf("client-%d", i),
+			"display_name":   fmt.Sprintf("Client %d", i),
+			"hostname":       fmt.Sprintf("host-%d", i),
+			"network_name":   "LAN",
+			"ip":             fmt.Sprintf("192.168.%d.%d", i>>8&0xff, i&0xff),
+			"ipv6_addresses": []string{fmt.Sprintf("2001:db8::%x", i+1), fmt.Sprintf("2001:db8::1:%x", i+1), fmt.Sprintf("fe80::%x", i+1)},
+			"oui":            "Vendor Inc.",
+			"is_wired":       i%3 == 0,
+			"uptime":         86400 + i,
+			"tx_bytes":       1 << 30,
+			"rx_bytes":       1 << 31,
+			"fingerprint":    map[string]any{"dev_cat": 1, "dev_family": 9, "dev_vendor": 47, "dev_id": 3821, "computed_engine": 1},
+			"last_seen":      1700000000 + i,
+		}
+		for j := range 40 {
+			c[fmt.Sprintf("stat_%d", j)] = strings.Repeat("x", 20)
+		}
+		clients[i] = c
+	}
+	data, _ := json.Marshal(clients)
+	return data
+}
+
+// BenchmarkDecode compares decoding a large client listing in one go with
+// decoding it item by item as decodeList does, keeping every client or only
+// two tracked ones.
+func BenchmarkDecode(b *testing.B) {
+	type active struct {
+		MAC           string   `json:"mac"`
+		Name          string   `json:"name"`
+		DisplayName   string   `json:"display_name"`
+		Hostname      string   `json:"hostname"`
+		NetworkName   string   `json:"network_name"`
+		IP            string   `json:"ip"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	}
+	data := activeClients(5000)
+	tracked := map[string]bool{"02:00:00:00:00:07": true, "02:00:00:00:10:00": true}
+
+	b.Run("buffered", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for b.Loop() {
+			body, err := io.ReadAll(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var items []active
+			if err := json.Unmarshal(body, &items); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("streamed", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := decodeList[active](bytes.NewReader(data), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("tracked", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for b.Loop() {
+			p, err := decodeList(bytes.NewReader(data), func(s active) bool { return tracked[s.MAC] })
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(p.items) != len(tracked) {
+				b.Fatalf("kept %d clients, want %d", len(p.items), len(tracked))
+			}
+		}
+	})
+}
+
+func TestGetListReusesUnchangedBody(t *testing.T) {
+	body := `{"data":[{"mac":"02:00:00:00:00:01"},{"mac":"02:00:00:00:00:02"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	c := New(srv.URL, "key", false)
+
+	type item struct {
+		MAC string `json:"mac"`
+	}
+	var decoded int
+	keep := func(item) bool { decoded++; return true }
+	first, err := getList(c, srv.URL+"/list", keep, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.items) != 2 || decoded != 2 {
+		t.Fatalf("got %d items after decoding %d, want 2", len(first.items), decoded)
+	}
+
+	// the same body again is answered from the cache without decoding it
+	again, err := getList(c, srv.URL+"/list", keep, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != 2 || len(again.items) != 2 || &again.items[0] != &first.items[0] {
+		t.Errorf("unchanged body decoded %d items in all, want it reused from the cache", decoded)
+	}
+
+	body = `{"data":[{"mac":"02:00:00:00:00:03"}]}`
+	changed, err := getList(c, srv.URL+"/list", keep, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != 3 || len(changed.items) != 1 || changed.items[0].MAC != "02:00:00:00:00:03" {
+		t.Errorf("changed body gave %v after decoding %d items in all, want it decoded", changed.items, decoded)
+	}
+}
@@ -0,0 +1,81 @@
+package unifi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestCanonicalMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []string
+		want    []string
+	}{
+		{name: "empty"},
+		{name: "RFC 5952", members: []string{"2001:0DB8:0000:0000:0000:0000:0000:0001"}, want: []string{"2001:db8::1"}},
+		{name: "numeric order", members: []string{"2001:db8::10", "2001:db8::9", "2001:db8::a"}, want: []string{"2001:db8::9", "2001:db8::a", "2001:db8::10"}},
+		{name: "duplicates", members: []string{"2001:db8::1", " 2001:DB8::1", "2001:db8:0::1"}, want: []string{"2001:db8::1"}},
+		{name: "prefixes after addresses", members: []string{"2001:db8::/64", "2001:db8::1", "2001:db8::/48"}, want: []string{"2001:db8::1", "2001:db8::/48", "2001:db8::/64"}},
+		{name: "others kept last", members: []string{"192.168.1.1-192.168.1.9", "2001:db8::1"}, want: []string{"2001:db8::1", "192.168.1.1-192.168.1.9"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CanonicalMembers(tt.members)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CanonicalMembers(%v) = %v, want %v", tt.members, got, tt.want)
+			}
+			if again := CanonicalMembers(got); !slices.Equal(again, got) {
+				t.Errorf("CanonicalMembers isn't idempotent: %v became %v", got, again)
+			}
+		})
+	}
+}
+
+func TestCanWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		self     string
+		readOnly bool
+	}{
+		{name: "admin", status: 200, self: `{"data":[{"site_role":"admin"}]}`},
+		{name: "super admin", status: 200, self: `{"data":[{"is_super":true,"site_role":"readonly"}]}`},
+		{name: "view only", status: 200, self: `{"data":[{"site_role":"readonly"}]}`, readOnly: true},
+		{name: "role not reported", status: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/proxy/network/api/s/default/self" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.self)
+			}))
+			defer srv.Close()
+
+			err := New(srv.URL, "key", false).CanWrite()
+			if got := errors.Is(err, ErrReadOnly); got != tt.readOnly || err != nil && !got {
+				t.Errorf("CanWrite() = %v, want read-only %v", err, tt.readOnly)
+			}
+		})
+	}
+}
+
+func TestUpdateFirewallGroupForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"meta":{"rc":"error","msg":"api.err.NoPermission"}}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	group := FirewallGroup{ID: "g1", Type: GroupTypeIPv6}
+	err := New(srv.URL, "key", false).UpdateFirewallGroup(group, "2001:db8::1")
+	if !errors.Is(err, ErrReadOnly) || !IsAuthError(err) {
+		t.Errorf("UpdateFirewallGroup() = %v, want ErrReadOnly", err)
+	}
+}
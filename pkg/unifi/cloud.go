@@ -0,0 +1,83 @@
+package unifi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// CloudURL is the base URL of Ubiquiti's Site Manager API.
+var CloudURL = "https://api.ui.com"
+
+// ConsoleURL returns the URL reaching the console with the ID id through
+// the Site Manager's connector, to use as a controller host with a Site
+// Manager API key, for consoles that can't be reached directly.
+func ConsoleURL(id string) string {
+	return CloudURL + "/v1/connector/consoles/" + url.PathEscape(id)
+}
+
+// Console is a console known to the Site Manager.
+type Console struct {
+	ID   string
+	Name string
+	IP   string
+}
+
+// Consoles lists the consoles the Site Manager API key apiKey has access
+// to, for finding their IDs.
+func Consoles(apiKey, userAgent string) ([]Console, error) {
+	var consoles []Console
+	for next := ""; ; {
+		u := CloudURL + "/v1/hosts"
+		if next != "" {
+			u += "?nextToken=" + url.QueryEscape(next)
+		}
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-API-KEY", apiKey)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			return nil, &APIError{StatusCode: resp.StatusCode, Body: string(data)}
+		}
+
+		var page struct {
+			Data []struct {
+				ID            string `json:"id"`
+				IPAddress     string `json:"ipAddress"`
+				ReportedState struct {
+					Name     string `json:"name"`
+					Hostname string `json:"hostname"`
+				} `json:"reportedState"`
+			} `json:"data"`
+			NextToken string `json:"nextToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("decode hosts: %w", err)
+		}
+		for _, h := range page.Data {
+			name := h.ReportedState.Name
+			if name == "" {
+				name = h.ReportedState.Hostname
+			}
+			consoles = append(consoles, Console{ID: h.ID, Name: name, IP: h.IPAddress})
+		}
+		if page.NextToken == "" || len(page.Data) == 0 {
+			return consoles, nil
+		}
+		next = page.NextToken
+	}
+}
@@ -0,0 +1,138 @@
+package unifi
+
+import (
+	"cmp"
+	"encoding/binary"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DiscoveryPort is the UDP port UniFi devices answer discovery requests on.
+const DiscoveryPort = 10001
+
+// Device is a UniFi device that answered a discovery request.
+type Device struct {
+	MAC      string
+	IP       string
+	Model    string
+	Hostname string
+	Firmware string
+}
+
+// consoleModels are the model prefixes of the consoles that run the
+// Network application themselves. Other gateways (UXG), access points and
+// switches answer discovery too, but are managed from elsewhere.
+var consoleModels = []string{"UDM", "UDR", "UDW", "UCG", "UCK", "UX"}
+
+// Console reports whether the device is a console running the Network
+// application, one the updater can talk to.
+func (d Device) Console() bool {
+	m := strings.ToUpper(d.Model)
+	return !strings.HasPrefix(m, "UXG") && slices.ContainsFunc(consoleModels, func(p string) bool { return strings.HasPrefix(m, p) })
+}
+
+// URL returns the URL of the device's Network application.
+func (d Device) URL() string {
+	return "https://" + d.IP
+}
+
+// discovery TLV types, as sent in version 1 answers
+const (
+	tlvHWAddr   = 0x01
+	tlvIPInfo   = 0x02
+	tlvFirmware = 0x03
+	tlvHostname = 0x0b
+	tlvPlatform = 0x0c
+	tlvModel    = 0x14
+	tlvModelV2  = 0x15
+)
+
+// Discover broadcasts a UniFi discovery request on the local network and
+// returns the devices that answer within timeout, sorted by IP. Only
+// devices on the same broadcast domain can answer.
+func Discover(timeout time.Duration) ([]Device, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := []byte{1, 0, 0, 0}
+	for _, addr := range []string{"255.255.255.255", "233.89.188.1"} {
+		dst := &net.UDPAddr{IP: net.ParseIP(addr), Port: DiscoveryPort}
+		if _, err := conn.WriteTo(req, dst); err != nil && addr == "255.255.255.255" {
+			return nil, err
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	seen := map[string]bool{}
+	var devices []Device
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		d, ok := parseDiscovery(buf[:n])
+		if !ok {
+			continue // our own request, or not a device
+		}
+		if d.IP == "" {
+			d.IP = from.(*net.UDPAddr).IP.String()
+		}
+		if key := d.MAC + d.IP; !seen[key] {
+			seen[key] = true
+			devices = append(devices, d)
+		}
+	}
+	slices.SortFunc(devices, func(a, b Device) int { return strings.Compare(a.IP, b.IP) })
+	return devices, nil
+}
+
+// parseDiscovery decodes a discovery answer, reporting false for anything
+// else, e.g. requests from other hosts.
+func parseDiscovery(b []byte) (Device, bool) {
+	if len(b) < 4 || b[0] != 1 || b[1] != 0 {
+		return Device{}, false
+	}
+	b = b[4:]
+	var d Device
+	var platform string
+	for len(b) >= 3 {
+		typ, n := b[0], int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < 3+n {
+			break
+		}
+		v := b[3 : 3+n]
+		b = b[3+n:]
+		switch typ {
+		case tlvHWAddr:
+			if n == 6 {
+				d.MAC = net.HardwareAddr(v).String()
+			}
+		case tlvIPInfo:
+			if n == 10 {
+				d.MAC = net.HardwareAddr(v[:6]).String()
+				if ip := net.IP(v[6:]); d.IP == "" && !ip.IsLinkLocalUnicast() {
+					d.IP = ip.String()
+				}
+			}
+		case tlvFirmware:
+			d.Firmware = string(v)
+		case tlvHostname:
+			d.Hostname = string(v)
+		case tlvPlatform:
+			platform = string(v)
+		case tlvModel, tlvModelV2:
+			d.Model = string(v)
+		}
+	}
+	d.Model = cmp.Or(d.Model, platform)
+	return d, d.MAC != ""
+}
@@ -0,0 +1,62 @@
+package unifi
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Event is a message from the controller's event WebSocket.
+type Event struct {
+	Meta struct {
+		Message string `json:"message"`
+	} `json:"meta"`
+	Data []struct {
+		Key           string   `json:"key"`
+		MAC           string   `json:"mac"`
+		User          string   `json:"user"`
+		IPv6Addresses []string `json:"ipv6_addresses"`
+	} `json:"data"`
+}
+
+// EventStream is an open connection to the controller's event WebSocket.
+type EventStream struct {
+	ws *wsConn
+}
+
+// Events connects to the site's event WebSocket.
+func (c *Client) Events() (*EventStream, error) {
+	host := c.Host()
+	u := strings.Replace(host, "http", "ws", 1) + "/proxy/network/wss/s/" + c.Site + "/events?clients=v2"
+	header, err := c.sessionHeader(host)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := dialWebSocket(u, header, &tls.Config{InsecureSkipVerify: !c.verifySSL})
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{ws: ws}, nil
+}
+
+// Next returns the next event, skipping messages that aren't JSON. idle
+// bounds the wait for any traffic, so a silently dropped connection is
+// noticed.
+func (s *EventStream) Next(idle time.Duration) (Event, error) {
+	for {
+		msg, err := s.ws.readMessage(idle)
+		if err != nil {
+			return Event{}, err
+		}
+		var ev Event
+		if err := json.Unmarshal(msg, &ev); err == nil {
+			return ev, nil
+		}
+	}
+}
+
+// Close closes the connection.
+func (s *EventStream) Close() error {
+	return s.ws.Close()
+}
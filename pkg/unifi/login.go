@@ -0,0 +1,196 @@
+package unifi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusMFARequired is what UniFi OS answers a login without a 2FA code
+// with, for accounts that need one.
+const statusMFARequired = 499
+
+// ErrMFARequired is returned when the account needs a 2FA code and the
+// client has no way to get one.
+var ErrMFARequired = errors.New("the account requires a 2FA code")
+
+// ErrLoginRejected is returned, along with the *APIError, when the console
+// refuses the username, password or 2FA code.
+var ErrLoginRejected = errors.New("login rejected")
+
+// login is a username and password session with a UniFi OS console, for
+// controllers or accounts without API keys.
+type login struct {
+	username string
+	password string
+	// code returns the current 2FA code, or nil when there is none.
+	code func() (string, error)
+
+	mu   sync.Mutex
+	csrf string // empty until logged in
+}
+
+// SetLogin makes the client log in to the console with a local or UI
+// account instead of sending an API key, and log in again whenever the
+// session expires. code is called for a 2FA code when the account needs
+// one, e.g. TOTPCode; nil fails such logins with ErrMFARequired.
+func (c *Client) SetLogin(username, password string, code func() (string, error)) {
+	c.login = &login{username: username, password: password, code: code}
+	c.http.Jar, _ = cookiejar.New(nil)
+}
+
+// signIn logs in, replacing the session. It is called with c.login.mu held.
+func (c *Client) signIn() error {
+	l := c.login
+	l.csrf = ""
+	creds := map[string]any{"username": l.username, "password": l.password, "rememberMe": true}
+	resp, data, err := c.post("/api/auth/login", creds)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == statusMFARequired {
+		if l.code == nil {
+			return ErrMFARequired
+		}
+		code, err := l.code()
+		if err != nil {
+			return fmt.Errorf("2FA code: %w", err)
+		}
+		creds["token"] = code
+		if resp, data, err = c.post("/api/auth/login", creds); err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %w", ErrLoginRejected, &APIError{StatusCode: resp.StatusCode, Body: string(data)})
+	}
+	l.csrf = resp.Header.Get("X-Csrf-Token")
+	if l.csrf == "" {
+		l.csrf = "-" // older firmware doesn't check it
+	}
+	return nil
+}
+
+// post sends a JSON login request, unauthenticated.
+func (c *Client) post(path string, v any) (*http.Response, []byte, error) {
+	body, _ := json.Marshal(v)
+	req, err := http.NewRequest("POST", c.Host()+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.UserAgent)
+	return c.read(req)
+}
+
+// authorize adds the API key or session to req, logging in first if
+// needed. It returns the position of the API key used.
+func (c *Client) authorize(req *http.Request) (int, error) {
+	if c.login == nil {
+		key := int(c.key.Load())
+		req.Header.Set("X-API-KEY", c.apiKeys[key])
+		return key, nil
+	}
+	c.login.mu.Lock()
+	defer c.login.mu.Unlock()
+	if c.login.csrf == "" {
+		if err := c.signIn(); err != nil {
+			return 0, err
+		}
+	}
+	if c.login.csrf != "-" {
+		req.Header.Set("X-Csrf-Token", c.login.csrf)
+	}
+	return 0, nil
+}
+
+// expired reports whether resp shows the credentials have stopped working
+// and the request should be sent again: the API key in use, key, was
+// rejected and there is a next one, or the session has ended and is
+// forgotten, so the next request logs in again. Keys only move forward, so
+// once the last is rejected too its error is returned. The console may
+// also hand out a new CSRF token with any response.
+func (c *Client) expired(resp *http.Response, key int) bool {
+	if c.login == nil {
+		if resp.StatusCode != http.StatusUnauthorized || key == len(c.apiKeys)-1 {
+			return false
+		}
+		next := key + 1
+		// concurrent requests rejected with the same key rotate it once
+		if c.key.CompareAndSwap(int32(key), int32(next)) && c.KeyRotated != nil {
+			c.KeyRotated(key, next)
+		}
+		return true
+	}
+	c.login.mu.Lock()
+	defer c.login.mu.Unlock()
+	if t := resp.Header.Get("X-Updated-Csrf-Token"); t != "" {
+		c.login.csrf = t
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.login.csrf = ""
+		return true
+	}
+	return false
+}
+
+// sessionHeader returns the headers authenticating a WebSocket to the
+// console at host, the API key or the session's cookies.
+func (c *Client) sessionHeader(host string) (http.Header, error) {
+	header := http.Header{"User-Agent": {c.UserAgent}}
+	if c.login == nil {
+		header.Set("X-API-KEY", c.apiKeys[c.key.Load()])
+		return header, nil
+	}
+	req, _ := http.NewRequest("GET", host, nil)
+	if _, err := c.authorize(req); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	var cookies []string
+	for _, ck := range c.http.Jar.Cookies(u) {
+		cookies = append(cookies, ck.Name+"="+ck.Value)
+	}
+	header.Set("Cookie", strings.Join(cookies, "; "))
+	if t := req.Header.Get("X-Csrf-Token"); t != "" {
+		header.Set("X-Csrf-Token", t)
+	}
+	return header, nil
+}
+
+// TOTPCode returns a function generating the current time-based 2FA code
+// (RFC 6238: 6 digits, 30 second steps, SHA-1) for secret, the base32 key
+// shown when 2FA was set up. It fails straight away on an invalid secret.
+func TOTPCode(secret string) (func() (string, error), error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return func() (string, error) { return totp(key, time.Now()), nil }, nil
+}
+
+func totp(key []byte, t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[off:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", code%1_000_000)
+}
@@ -0,0 +1,114 @@
+package unifi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Handler sends a request to the controller and returns its response. The
+// request's body can be read again through its GetBody, which middleware
+// replacing the body must set too.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the handling of the client's API requests, e.g. to log
+// them, measure them, add headers or retry them, calling next to go on.
+type Middleware func(next Handler) Handler
+
+// Use adds middleware around every API request the client sends, the first
+// added outermost. It sees each request once, with its headers and
+// authentication set, before the rate limit and failover to the
+// controller's other URLs. Like the client's fields, it is set up before
+// requests are sent. The event WebSocket is not affected.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// handle sends req through the middleware, subject to the rate limit.
+func (c *Client) handle(req *http.Request) (*http.Response, error) {
+	h := c.transmit
+	for _, mw := range slices.Backward(c.middleware) {
+		h = mw(h)
+	}
+	return h(req)
+}
+
+// transmit is the end of the middleware chain, sending req to the
+// controller.
+func (c *Client) transmit(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	c.wait()
+	return c.send(req, body)
+}
+
+// Header returns middleware setting a header on every request, e.g. to
+// authenticate with a reverse proxy in front of the controller.
+func Header(key, value string) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set(key, value)
+			return next(req)
+		}
+	}
+}
+
+// Observe returns middleware calling fn after every request with its
+// response or error and how long it took, e.g. to log requests or count
+// them.
+func Observe(fn func(req *http.Request, resp *http.Response, err error, took time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			fn(req, resp, err, time.Since(start))
+			return resp, err
+		}
+	}
+}
+
+// Retry returns middleware sending a request up to attempts times while it
+// fails to reach the controller or is answered with a 429 or 5xx status,
+// waiting backoff before the first retry of each request and twice as long
+// each time after.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			wait := backoff
+			for i := 1; ; i++ {
+				resp, err := next(req)
+				if i >= attempts || !retryable(resp, err) {
+					return resp, err
+				}
+				if resp != nil {
+					resp.Body.Close()
+				}
+				sleep(wait)
+				wait *= 2
+			}
+		}
+	}
+}
+
+// sleep waits between retries; tests replace it.
+var sleep = time.Sleep
+
+// retryable reports whether a request answered with resp or failed with
+// err may succeed if sent again.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return IsUnreachable(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,102 @@
+package unifi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statuses returns a handler answering with each of codes in turn, then
+// 200, and counting the requests it gets.
+func statuses(codes ...int) (Handler, *int) {
+	var n int
+	var mu sync.Mutex
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		code := http.StatusOK
+		if n < len(codes) {
+			code = codes[n]
+		}
+		n++
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(code)
+		return rec.Result(), nil
+	}, &n
+}
+
+func TestRetry(t *testing.T) {
+	var mu sync.Mutex
+	var waits []time.Duration
+	sleep = func(d time.Duration) {
+		mu.Lock()
+		waits = append(waits, d)
+		mu.Unlock()
+	}
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	tests := []struct {
+		name     string
+		codes    []int
+		status   int
+		attempts int
+		waits    []time.Duration
+	}{
+		{name: "success", status: 200, attempts: 1},
+		{name: "not retryable", codes: []int{404}, status: 404, attempts: 1},
+		{name: "retried", codes: []int{503, 429}, status: 200, attempts: 3, waits: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "gives up", codes: []int{500, 502, 503, 504, 500}, status: 504, attempts: 4, waits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits = nil
+			next, n := statuses(tt.codes...)
+			resp, err := Retry(4, time.Second)(next)(httptest.NewRequest("GET", "/", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || *n != tt.attempts {
+				t.Errorf("got HTTP %d after %d attempts, want HTTP %d after %d", resp.StatusCode, *n, tt.status, tt.attempts)
+			}
+			if !slices.Equal(waits, tt.waits) {
+				t.Errorf("waited %v, want %v", waits, tt.waits)
+			}
+		})
+	}
+}
+
+// TestRetryBackoffPerRequest checks that one request's retries don't make
+// later or concurrent ones wait longer.
+func TestRetryBackoffPerRequest(t *testing.T) {
+	var mu sync.Mutex
+	var waits []time.Duration
+	sleep = func(d time.Duration) {
+		mu.Lock()
+		waits = append(waits, d)
+		mu.Unlock()
+	}
+	t.Cleanup(func() { sleep = time.Sleep })
+
+	retry := Retry(2, time.Second)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			next, _ := statuses(503)
+			retry(next)(httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+	wg.Wait()
+	for _, d := range waits {
+		if d != time.Second {
+			t.Fatalf("waited %v, want every request to wait 1s", waits)
+		}
+	}
+	if len(waits) != 8 {
+		t.Errorf("%d waits, want 8", len(waits))
+	}
+}
@@ -0,0 +1,24 @@
+package unifi
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// SetRateLimit limits the client's API calls to rps per second on average,
+// with bursts of up to burst calls. rps <= 0 disables the limit, which is
+// the default.
+func (c *Client) SetRateLimit(rps float64, burst int) {
+	if rps <= 0 {
+		c.limiter.SetLimit(rate.Inf)
+		return
+	}
+	c.limiter.SetLimit(rate.Limit(rps))
+	c.limiter.SetBurst(max(burst, 1))
+}
+
+// wait blocks until the limiter allows another API call.
+func (c *Client) wait() {
+	c.limiter.Wait(context.Background())
+}
@@ -0,0 +1,176 @@
+package unifi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is a request to the controller and its response, as kept in a
+// Recording.
+type Exchange struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// URL is the request's path and query, without the controller's host.
+	URL      string `json:"url"`
+	Request  string `json:"request,omitempty"`
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// redacted replaces secrets in recordings.
+const redacted = "REDACTED"
+
+// WrapTransport makes the client send its API requests through the
+// transport wrap returns, given the client's own, e.g. a Recording's. The
+// event WebSocket is not affected.
+func (c *Client) WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	c.http.Transport = wrap(c.http.Transport)
+}
+
+// Recording appends exchanges with controllers to a file as JSON lines.
+type Recording struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Transport returns a transport sending requests with next and recording
+// them, with request headers left out and secrets redacted everywhere else.
+func (r *Recording) Transport(next http.RoundTripper, secrets ...string) http.RoundTripper {
+	return &recorder{r, next, secrets}
+}
+
+type recorder struct {
+	rec     *Recording
+	next    http.RoundTripper
+	secrets []string
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	ex := Exchange{Time: time.Now().UTC(), Method: req.Method, URL: r.redact(req.URL.RequestURI()),
+		Request: r.redact(string(body)), Status: resp.StatusCode, Response: r.redact(string(data))}
+	if err := r.rec.write(ex); err != nil {
+		return nil, fmt.Errorf("record: %w", err)
+	}
+	return resp, nil
+}
+
+func (r *recorder) redact(s string) string {
+	for _, secret := range r.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+func (r *Recording) write(ex Exchange) error {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replayer is an http.RoundTripper answering requests from a Recording
+// instead of a controller. Each request gets the response recorded for the
+// next exchange with the same method and URL, or the last one once they
+// are used up, whatever the host; requests never recorded fail.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+}
+
+// LoadReplayer reads the Recording at path.
+func LoadReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &Replayer{exchanges: map[string][]Exchange{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var ex Exchange
+		if err := json.Unmarshal(sc.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		key := ex.Method + " " + ex.URL
+		r.exchanges[key] = append(r.exchanges[key], ex)
+	}
+	return r, sc.Err()
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := req.Method + " " + req.URL.RequestURI()
+
+	r.mu.Lock()
+	queue := r.exchanges[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("replay: no recorded response for %s", key)
+	}
+	ex := queue[0]
+	if len(queue) > 1 {
+		r.exchanges[key] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+		StatusCode:    ex.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(ex.Response)),
+		ContentLength: int64(len(ex.Response)),
+		Request:       req,
+	}, nil
+}
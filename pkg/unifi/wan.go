@@ -0,0 +1,38 @@
+package unifi
+
+import (
+	"net"
+	"slices"
+)
+
+// WANAddresses returns the global IPv6 addresses of the site's gateway WAN
+// interfaces, sorted, read from the device list. They change when the ISP
+// renumbers the connection, usually along with the delegated LAN prefix.
+func (c *Client) WANAddresses() ([]string, error) {
+	type wan struct {
+		IPv6 []string `json:"ipv6"`
+	}
+	devices, err := getPaged[struct {
+		Wan1 *wan `json:"wan1"`
+		Wan2 *wan `json:"wan2"`
+	}](c, c.url("/api/s/%s/stat/device", c.Site), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, d := range devices {
+		for _, w := range []*wan{d.Wan1, d.Wan2} {
+			if w == nil {
+				continue
+			}
+			for _, a := range w.IPv6 {
+				if ip := net.ParseIP(a); ip != nil && ip.To4() == nil && ip.IsGlobalUnicast() {
+					addrs = append(addrs, ip.String())
+				}
+			}
+		}
+	}
+	slices.Sort(addrs)
+	return slices.Compact(addrs), nil
+}
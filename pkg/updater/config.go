@@ -0,0 +1,268 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/target"
+)
+
+// ClientConfig holds each client’s details and cached address
+type ClientConfig struct {
+	MAC string `json:"mac"`
+	// Name is a friendly label for the client, used in logs and
+	// notifications alongside its MAC.
+	Name string `json:"name,omitempty"`
+	// GroupID is the entry the address is published to: a firewall group
+	// ID for the default target, or the target's own reference.
+	GroupID string `json:"group_id"`
+	// Target names where the address is published; empty means
+	// DefaultTarget.
+	Target string `json:"target,omitempty"`
+	// Also lists further targets the address is published to alongside
+	// the main one, e.g. a DNS record naming the client.
+	Also     []Destination `json:"also,omitempty"`
+	LastIPv6 string        `json:"last_ipv6"`
+	// Addresses are every address last published, when there were
+	// several; LastIPv6 is the first of them.
+	Addresses []string `json:"addresses,omitempty"`
+	// Token lets the client push its own address to the listener.
+	Token string `json:"token,omitempty"`
+	// TrackIID publishes the client's interface ID in a renumbered
+	// prefix as soon as other clients reveal the new prefix, before the
+	// client itself is seen with it. Only suitable for clients whose
+	// interface ID doesn't change with the prefix (EUI-64 or static).
+	TrackIID bool `json:"track_iid,omitempty"`
+	// IID is the interface ID (low 64 bits) of the last published
+	// address, kept for TrackIID.
+	IID string `json:"iid,omitempty"`
+	// Site is the controller site the client is on, and Controller names
+	// the controller among the config's Controllers; empty means the
+	// updater's own. The client is looked up there and DefaultTarget
+	// publishes to that site's firewall groups.
+	Site       string `json:"site,omitempty"`
+	Controller string `json:"controller,omitempty"`
+	// Enabled set to false skips the client in every cycle, keeping its
+	// config and last address. Clients are enabled when it is unset.
+	Enabled *bool `json:"enabled,omitempty"`
+	// AllowEmpty clears the client's entries when it has no usable
+	// address. Otherwise they keep the last addresses, so a gap in the
+	// controller's data never leaves a group empty.
+	AllowEmpty bool `json:"allow_empty,omitempty"`
+	// Interval is how often the client is checked, in seconds, in place
+	// of the updater's Interval.
+	Interval int `json:"interval,omitempty"`
+	// Prefer, AllowULA and MaxAddresses choose which of the client's
+	// addresses are published in place of the updater's Selection: see
+	// PreferFirst, PreferStable, PreferTemporary and PreferAll.
+	Prefer       string `json:"prefer,omitempty"`
+	AllowULA     *bool  `json:"allow_ula,omitempty"`
+	MaxAddresses int    `json:"max_addresses,omitempty"`
+	// Drift is what is done about the client's firewall groups being
+	// changed outside the updater, in place of the updater's DriftPolicy:
+	// DriftAlert, DriftRepair or DriftRespect. Clients sharing a group
+	// should agree, or the first one's applies.
+	Drift string `json:"drift,omitempty"`
+	// Verify is how the client's new addresses are checked before they
+	// are published, in place of the updater's Verify: VerifyPing,
+	// VerifyTCP with a port, or VerifyOff.
+	Verify string `json:"verify,omitempty"`
+	// Overlap is how long, in seconds, an address the client's new one
+	// replaced stays published alongside it, in place of the updater's
+	// Overlap; 0 removes it straight away.
+	Overlap *int `json:"overlap,omitempty"`
+	// Retiring are the replaced addresses still published until their
+	// overlap ends.
+	Retiring []RetiringAddress `json:"retiring,omitempty"`
+}
+
+// Destination is an entry on a target: the target's name and what it calls
+// the entry, such as a DNS record name.
+type Destination struct {
+	Target string `json:"target"`
+	Ref    string `json:"ref"`
+	// Site is the controller site of a DefaultTarget entry, when it isn't
+	// the client's, e.g. for a client roaming between sites.
+	Site string `json:"site,omitempty"`
+}
+
+// TargetName returns the client's target, defaulting to DefaultTarget.
+func (c ClientConfig) TargetName() string {
+	if c.Target == "" {
+		return DefaultTarget
+	}
+	return c.Target
+}
+
+// Label returns the client's name and MAC, e.g. "NAS (aa:bb:cc:dd:ee:ff)",
+// or just the MAC when it has no name.
+func (c ClientConfig) Label() string { return label(c.Name, c.MAC) }
+
+func label(name, mac string) string {
+	if name == "" {
+		return mac
+	}
+	return name + " (" + mac + ")"
+}
+
+// IsEnabled reports whether the client is managed.
+func (c ClientConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// interfaceID returns the client's recorded interface ID, or that of its
+// last address.
+func (c ClientConfig) interfaceID() string {
+	if c.IID != "" {
+		return c.IID
+	}
+	return interfaceID(c.LastIPv6)
+}
+
+// Published returns the addresses last published for the client.
+func (c ClientConfig) Published() []string {
+	if len(c.Addresses) > 0 {
+		return c.Addresses
+	}
+	if c.LastIPv6 == "" {
+		return nil
+	}
+	return []string{c.LastIPv6}
+}
+
+// At returns the client as it is on the site of the destination d: c
+// itself unless d names another site of the client's controller.
+func (c ClientConfig) At(d Destination) ClientConfig {
+	if d.Site != "" {
+		c.Site = d.Site
+	}
+	return c
+}
+
+// Destinations returns every entry the client's address is published to,
+// its main target first.
+func (c ClientConfig) Destinations() []Destination {
+	return append([]Destination{{Target: c.TargetName(), Ref: c.GroupID}}, c.Also...)
+}
+
+// Config holds the tracked clients, the notifiers to alert and the targets
+// beyond the UniFi firewall groups.
+type Config struct {
+	// Schema is the JSON Schema the file refers to for editors, kept when
+	// the file is saved.
+	Schema string `json:"$schema,omitempty"`
+	// Version is the layout of the file, see ConfigVersion and Migrate.
+	Version int `json:"version,omitempty"`
+
+	Clients   []ClientConfig  `json:"clients"`
+	Notifiers []notify.Config `json:"notifiers,omitempty"`
+	Targets   []target.Config `json:"targets,omitempty"`
+	// Controllers are further UniFi controllers clients can be on.
+	Controllers []ControllerConfig `json:"controllers,omitempty"`
+	// GroupLimits are the most members of the firewall groups they name by
+	// ID, in place of the updater's MaxGroupMembers; 0 means no cap.
+	GroupLimits map[string]int `json:"group_limits,omitempty"`
+	// RenumberedPrefixes are the latest /64 prefixes known to have been
+	// renumbered, most recent first, for clients with TrackIID.
+	RenumberedPrefixes []PrefixMove `json:"renumbered_prefixes,omitempty"`
+	// Log are where the log is written, read when the updater starts.
+	Log []LogConfig `json:"log,omitempty"`
+}
+
+// Log outputs and formats.
+const (
+	LogConsole = "console"
+	LogFile    = "file"
+	LogSyslog  = "syslog"
+
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// LogConfig is a destination of the log.
+type LogConfig struct {
+	// Output is LogConsole, LogFile or LogSyslog.
+	Output string `json:"output"`
+	// Path is the file LogFile writes to.
+	Path string `json:"path,omitempty"`
+	// Address is the syslog server LogSyslog sends to, e.g.
+	// "udp://192.168.1.10:514"; empty means the local one.
+	Address string `json:"address,omitempty"`
+	// Format is LogText, the lines as printed and the default, or LogJSON.
+	Format string `json:"format,omitempty"`
+	// Level is the least severe line written: SeverityInfo, the default,
+	// SeverityWarning or SeverityError.
+	Level string `json:"level,omitempty"`
+}
+
+// PrefixMove records that the /64 prefix From was replaced by To.
+type PrefixMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Store loads and saves the config, which also carries each client's last
+// published address.
+type Store interface {
+	Load() (*Config, error)
+	Save(*Config) error
+}
+
+// FileStore keeps the config in a JSON file.
+type FileStore struct {
+	Path string
+}
+
+func (s FileStore) Load() (*Config, error) { return LoadConfig(s.Path) }
+func (s FileStore) Save(cfg *Config) error { return SaveConfig(s.Path, cfg) }
+
+// LoadConfig reads and validates the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version > ConfigVersion {
+		return nil, fmt.Errorf("config version %d is newer than this updater's %d: upgrade the updater", cfg.Version, ConfigVersion)
+	}
+	for _, n := range cfg.Notifiers {
+		if err := n.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range cfg.Targets {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// SaveConfig writes cfg to the file at path, in place so that a file
+// mounted on its own or locked stays the same file. It holds API keys and
+// passwords, so only its owner may read it.
+func SaveConfig(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
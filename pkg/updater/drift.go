@@ -0,0 +1,234 @@
+package updater
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// What is done about a firewall group changed outside the updater.
+const (
+	// DriftAlert only reports the change, leaving the group as it is until
+	// a client's address changes and the group is written as usual.
+	DriftAlert = "alert"
+	// DriftRepair puts the group back to the addresses last published.
+	DriftRepair = "repair"
+	// DriftRespect keeps the change: clients' address changes only replace
+	// their own addresses in the group, keeping the other members.
+	DriftRespect = "respect"
+)
+
+// ValidDriftPolicy reports whether p is a known drift policy, or empty.
+func ValidDriftPolicy(p string) bool {
+	switch p {
+	case "", DriftAlert, DriftRepair, DriftRespect:
+		return true
+	}
+	return false
+}
+
+// Drift is how a firewall group differs from what the updater last wrote
+// to it, after someone changed it outside the updater, e.g. in the UI.
+type Drift struct {
+	Group unifi.FirewallGroup
+	// Added are the members nobody published, and Removed the published
+	// addresses missing from the group.
+	Added, Removed []string
+	// Policy is the group's drift policy, and Published the addresses its
+	// clients last published.
+	Policy    string
+	Published []string
+
+	t *FirewallGroupTarget
+}
+
+func (d Drift) String() string {
+	var parts []string
+	if len(d.Added) > 0 {
+		parts = append(parts, "added "+strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(d.Removed, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// group returns the firewall group with the ID id, as read this cycle.
+func (t *FirewallGroupTarget) group(id string) (unifi.FirewallGroup, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.groups[id]
+	return g, ok
+}
+
+// groupTarget returns the firewall groups of the client's site, if read
+// this cycle.
+func groupTarget(c ClientConfig, targets map[string]Target, sites map[string]*site) (*FirewallGroupTarget, bool) {
+	if s := sites[c.siteKey()]; s != nil {
+		return s.groups, s.err == nil && s.groups.read()
+	}
+	t, ok := targets[DefaultTarget].(*FirewallGroupTarget)
+	return t, ok && t.read()
+}
+
+// groupKey identifies the firewall group with the ID id on the client's
+// site.
+func (c ClientConfig) groupKey(id string) string { return c.siteKey() + "/" + id }
+
+// drift compares the firewall groups of the clients due this cycle with
+// the addresses all their clients last published, returning the drift of
+// each group checked, by its groupKey; it is empty for those that don't
+// differ. A group's policy is that of its first client with one, or the
+// updater's.
+func (u *Updater) drift(cfg *Config, due []bool, targets map[string]Target, sites map[string]*site) map[string]Drift {
+	type owned struct {
+		t       *FirewallGroupTarget
+		id      string
+		checked bool // a client due this cycle publishes to it
+		addrs   []string
+		policy  string
+	}
+	var keys []string
+	groups := map[string]*owned{}
+	for i, c := range cfg.Clients {
+		for _, d := range c.Destinations() {
+			if d.Target != DefaultTarget {
+				continue
+			}
+			key := c.At(d).groupKey(d.Ref)
+			g, ok := groups[key]
+			if !ok {
+				g = &owned{id: d.Ref}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+			for _, a := range c.Written() {
+				if !containsIP(g.addrs, a) {
+					g.addrs = append(g.addrs, a)
+				}
+			}
+			if g.policy == "" {
+				g.policy = c.Drift
+			}
+			if due[i] && c.IsEnabled() && g.t == nil {
+				g.t, g.checked = groupTarget(c.At(d), targets, sites)
+			}
+		}
+	}
+
+	drifts := map[string]Drift{}
+	for _, key := range keys {
+		g := groups[key]
+		if !g.checked {
+			continue
+		}
+		group, ok := g.t.group(g.id)
+		if !ok {
+			continue
+		}
+		d := Drift{Group: group, Policy: cmp.Or(g.policy, u.DriftPolicy, DriftAlert), Published: g.addrs, t: g.t}
+		if len(g.addrs) > 0 {
+			d.Added, d.Removed = diff(g.addrs, group.Members)
+		}
+		drifts[key] = d
+	}
+	return drifts
+}
+
+// diff returns the members of new missing from old, and those of old
+// missing from new.
+func diff(old, new []string) (added, removed []string) {
+	for _, m := range new {
+		if !containsIP(old, m) {
+			added = append(added, m)
+		}
+	}
+	for _, m := range old {
+		if !containsIP(new, m) {
+			removed = append(removed, m)
+		}
+	}
+	return added, removed
+}
+
+// containsIP reports whether addrs has ip, comparing addresses rather than
+// their spelling; members that aren't addresses are compared as they are.
+func containsIP(addrs []string, ip string) bool {
+	parsed := net.ParseIP(strings.TrimSuffix(ip, "/128"))
+	return slices.ContainsFunc(addrs, func(a string) bool {
+		if parsed == nil {
+			return a == ip
+		}
+		return parsed.Equal(net.ParseIP(strings.TrimSuffix(a, "/128")))
+	})
+}
+
+// handleDrift reports each group's drift and applies its policy. Drift is
+// alerted on unless the group drifted the same way when last alerted on,
+// and logged every cycle unless the group respects it. It returns how many
+// groups drifted and the errors repairing them.
+func (u *Updater) handleDrift(cfg *Config, drifts map[string]Drift) (int, []error) {
+	u.driftMu.Lock()
+	defer u.driftMu.Unlock()
+	if u.alerted == nil {
+		u.alerted = map[string]string{}
+	}
+	logger := u.logger()
+	var n int
+	var errs []error
+	for key, d := range drifts {
+		if d.String() == "" {
+			delete(u.alerted, key)
+			continue
+		}
+		n++
+		known := u.alerted[key] == d.String()
+		if !known || d.Policy != DriftRespect {
+			logger.Printf("⚠️  Firewall group %s (%s) was changed outside the updater: %s\n", d.Group.Name, d.Group.ID, d)
+		}
+		if !known {
+			u.alerted[key] = d.String()
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindDrift, Severity: "warning", GroupID: d.Group.ID,
+				GroupName: d.Group.Name, Added: d.Added, Removed: d.Removed,
+				Message: fmt.Sprintf("⚠️ Firewall group %s (%s) was changed outside the updater: %s", d.Group.Name, d.Group.ID, d)})
+		}
+		if d.Policy != DriftRepair {
+			continue
+		}
+
+		_, err := d.t.UpdateAll(d.Group.ID, d.Published)
+		u.recordGroup(history.ActionRepair, d.Group, d.Published, err)
+		if err != nil {
+			logger.Printf("❌ Failed to repair firewall group %s (%s): %v\n", d.Group.Name, d.Group.ID, err)
+			u.reportError(err, "", d.Group.ID)
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error", GroupID: d.Group.ID,
+				Message: fmt.Sprintf("❌ Failed to repair firewall group %s (%s): %v", d.Group.Name, d.Group.ID, err)})
+			errs = append(errs, fmt.Errorf("repair firewall group %s: %w", d.Group.ID, err))
+			continue
+		}
+		logger.Printf("🔧 Repaired firewall group %s (%s): %s\n", d.Group.Name, d.Group.ID, strings.Join(d.Published, ", "))
+		delete(u.alerted, key)
+	}
+	return n, errs
+}
+
+// recordGroup adds a change to a firewall group not made for a single
+// client to History.
+func (u *Updater) recordGroup(action string, group unifi.FirewallGroup, members []string, err error) {
+	if u.History == nil {
+		return
+	}
+	e := history.Entry{Action: action, Target: DefaultTarget, Ref: group.ID, Old: group.Members, New: members}
+	if err != nil {
+		e.Result, e.Error = history.ResultFailed, err.Error()
+	}
+	if err := u.History.Record(e); err != nil {
+		u.logger().Println("⚠️  Failed to record history:", err)
+	}
+}
@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name           string
+		old, new       []string
+		added, removed []string
+	}{
+		{name: "same", old: []string{"2001:db8::1"}, new: []string{"2001:db8::1"}},
+		{name: "spelled differently", old: []string{"2001:db8::1"}, new: []string{"2001:0DB8:0:0::1/128"}},
+		{name: "added", old: []string{"2001:db8::1"}, new: []string{"2001:db8::1", "2001:db8::99"}, added: []string{"2001:db8::99"}},
+		{name: "removed", old: []string{"2001:db8::1", "2001:db8::2"}, new: []string{"2001:db8::2"}, removed: []string{"2001:db8::1"}},
+		{name: "replaced", old: []string{"2001:db8::1"}, new: []string{"2001:db8::2"}, added: []string{"2001:db8::2"}, removed: []string{"2001:db8::1"}},
+		{name: "other members", old: []string{"2001:db8::1"}, new: []string{"2001:db8::1", "192.168.1.0/24"}, added: []string{"192.168.1.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := diff(tt.old, tt.new)
+			if !slices.Equal(added, tt.added) || !slices.Equal(removed, tt.removed) {
+				t.Errorf("diff() = %v, %v, want %v, %v", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package updater
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolveTimeout bounds the reverse lookups of a client's addresses.
+const resolveTimeout = 2 * time.Second
+
+// hostnames returns the names the addresses resolve back to (PTR records),
+// by address. Addresses without one are left out; nil means none had one.
+func (u *Updater) hostnames(addrs []string) map[string]string {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var names map[string]string
+	for _, a := range addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found, err := net.DefaultResolver.LookupAddr(ctx, a)
+			if err != nil || len(found) == 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if names == nil {
+				names = map[string]string{}
+			}
+			names[a] = strings.TrimSuffix(found[0], ".")
+		}()
+	}
+	wg.Wait()
+	return names
+}
+
+// Annotate returns the addresses separated by commas, each followed by its
+// hostname in brackets if it has one in names.
+func Annotate(addrs []string, names map[string]string) string {
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a
+		if n := names[a]; n != "" {
+			parts[i] += " (" + n + ")"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"net"
+	"slices"
+)
+
+// maxPrefixMoves is how many renumberings are remembered.
+const maxPrefixMoves = 8
+
+// prefix64 returns the /64 prefix of addr, e.g. "2001:db8:1:2::".
+func prefix64(addr string) (string, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() != nil {
+		return "", false
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String(), true
+}
+
+// interfaceID returns the low 64 bits of addr as an address, e.g.
+// "::1a2b:3c4d:5e6f:7788", or "" if addr isn't an IPv6 address.
+func interfaceID(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() != nil {
+		return ""
+	}
+	iid := make(net.IP, net.IPv6len)
+	copy(iid[8:], ip.To16()[8:])
+	return iid.String()
+}
+
+// withPrefix returns the address made of the /64 prefix and the interface
+// ID iid.
+func withPrefix(prefix, iid string) (string, bool) {
+	p, id := net.ParseIP(prefix), net.ParseIP(iid)
+	if p == nil || id == nil || p.To4() != nil || id.To4() != nil {
+		return "", false
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, p.To16()[:8])
+	copy(ip[8:], id.To16()[8:])
+	return ip.String(), true
+}
+
+// prefixMoves works out which /64 prefixes were renumbered from the
+// clients seen this cycle: a client last published in one prefix and now
+// seen in another reveals the move. Prefixes whose clients disagree about
+// where they moved are left out, as are clients with TrackIID still seen
+// in a prefix known to be gone, whose last address was put ahead of them.
+func prefixMoves(clients []ClientConfig, seen []string, known []PrefixMove) map[string]string {
+	moves := map[string]string{}
+	conflicting := map[string]bool{}
+	for i, c := range clients {
+		from, ok1 := prefix64(c.LastIPv6)
+		to, ok2 := prefix64(seen[i])
+		if !ok1 || !ok2 || from == to || conflicting[from] {
+			continue
+		}
+		if _, gone := movedTo(known, to); gone && c.TrackIID {
+			continue
+		}
+		if prev, ok := moves[from]; ok && prev != to {
+			delete(moves, from)
+			conflicting[from] = true
+			continue
+		}
+		moves[from] = to
+	}
+	return moves
+}
+
+// movedTo returns the prefix that replaced prefix, if it was renumbered.
+func movedTo(known []PrefixMove, prefix string) (string, bool) {
+	for _, m := range known {
+		if m.From == prefix {
+			return m.To, true
+		}
+	}
+	return "", false
+}
+
+// recordMoves adds moves to the known renumberings, pointing earlier moves
+// at the latest prefix, forgetting those away from a prefix now in use
+// again and keeping the latest maxPrefixMoves.
+func recordMoves(known []PrefixMove, moves map[string]string) []PrefixMove {
+	for from, to := range moves {
+		known = slices.DeleteFunc(known, func(m PrefixMove) bool { return m.From == from || m.From == to })
+		for i := range known {
+			if known[i].To == from {
+				known[i].To = to
+			}
+		}
+		known = slices.Insert(known, 0, PrefixMove{From: from, To: to})
+	}
+	if len(known) > maxPrefixMoves {
+		known = known[:maxPrefixMoves]
+	}
+	return known
+}
@@ -0,0 +1,87 @@
+package updater
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Address flags in /proc/net/if_inet6 that make an address unsuitable to
+// publish.
+const (
+	ifaTemporary  = 0x01
+	ifaDadFailed  = 0x08
+	ifaDeprecated = 0x20
+	ifaTentative  = 0x40
+)
+
+// InterfaceSource reports the addresses of this machine's own network
+// interfaces, keyed by their MAC, for running on the client itself. On
+// Linux, temporary (privacy), deprecated and not yet usable addresses are
+// left out, so the stable address is published.
+type InterfaceSource struct{}
+
+func (InterfaceSource) Name() string { return "local interfaces" }
+
+func (InterfaceSource) Addresses() (map[string][]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	skip := unusableAddresses()
+
+	addrs := map[string][]string{}
+	for _, iface := range ifaces {
+		if len(iface.HardwareAddr) == 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		list, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		mac := strings.ToLower(iface.HardwareAddr.String())
+		for _, a := range list {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() != nil || skip[ipnet.IP.String()] {
+				continue
+			}
+			addrs[mac] = append(addrs[mac], ipnet.IP.String())
+		}
+	}
+	return addrs, nil
+}
+
+// unusableAddresses returns the addresses Linux flags as temporary,
+// deprecated, tentative or failed duplicate detection. It is empty where
+// /proc/net/if_inet6 doesn't exist.
+func unusableAddresses() map[string]bool {
+	skip := map[string]bool{}
+	f, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return skip
+	}
+	defer f.Close()
+
+	// each line is: address, interface index, prefix length, scope, flags
+	// and interface name, with the numbers in hex
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 || len(fields[0]) != 32 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil || flags&(ifaTemporary|ifaDadFailed|ifaDeprecated|ifaTentative) == 0 {
+			continue
+		}
+		var ip net.IP
+		for i := 0; i < 32; i += 2 {
+			b, _ := strconv.ParseUint(fields[0][i:i+2], 16, 8)
+			ip = append(ip, byte(b))
+		}
+		skip[ip.String()] = true
+	}
+	return skip
+}
@@ -0,0 +1,277 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/target"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Diagnostic is a problem found in the config.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	// Path locates the entry in the config, e.g. "clients[3].group_id".
+	Path string `json:"path"`
+	// Line is where the entry starts in the config file, when known.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Lint checks cfg for values the updater can't use and for entries that
+// work against each other, without contacting any controller.
+func Lint(cfg *Config) []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity, path, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.Version < ConfigVersion && len(cfg.Clients) > 0 {
+		add(SeverityInfo, "version", "the config is in the layout of version %d; the migrate command rewrites it in that of version %d", max(cfg.Version, 1), ConfigVersion)
+	}
+
+	for i, cc := range cfg.Controllers {
+		path := fmt.Sprintf("controllers[%d]", i)
+		if cc.Name == "" {
+			add(SeverityError, path+".name", "name is empty, so no client can use the controller")
+		} else if j := slices.IndexFunc(cfg.Controllers[:i], func(o ControllerConfig) bool { return o.Name == cc.Name }); j >= 0 {
+			add(SeverityError, path+".name", "controller %q is already defined by controllers[%d]", cc.Name, j)
+		}
+		if cc.URL() == "" || cc.APIKey == "" {
+			add(SeverityError, path, "controller %q needs a host or console_id, and an api_key", cc.Name)
+		}
+		if cc.Host != "" && cc.ConsoleID != "" {
+			add(SeverityWarning, path+".console_id", "console_id is ignored, as controller %q has a host", cc.Name)
+		}
+	}
+
+	// firewall groups, by groupKey, and the clients publishing to them
+	type shared struct {
+		id      string
+		clients []int
+	}
+	groups := map[string]*shared{}
+	var groupKeys []string
+	for i, c := range cfg.Clients {
+		path := fmt.Sprintf("clients[%d]", i)
+		if _, err := net.ParseMAC(c.MAC); err != nil {
+			add(SeverityError, path+".mac", "invalid MAC %q", c.MAC)
+		}
+		if c.GroupID == "" {
+			add(SeverityError, path+".group_id", "group_id is empty")
+		}
+		if c.Interval < 0 {
+			add(SeverityError, path+".interval", "interval must be positive")
+		}
+		if c.Controller != "" && !slices.ContainsFunc(cfg.Controllers, func(cc ControllerConfig) bool { return cc.Name == c.Controller }) {
+			add(SeverityError, path+".controller", "unknown controller %q", c.Controller)
+		}
+		if !ValidPreference(c.Prefer) {
+			add(SeverityError, path+".prefer", "prefer must be first, stable, temporary or all")
+		}
+		if c.MaxAddresses < 0 {
+			add(SeverityError, path+".max_addresses", "max_addresses must be positive")
+		}
+		if c.MaxAddresses > 0 && c.Prefer != "" && c.Prefer != PreferAll {
+			add(SeverityInfo, path+".max_addresses", "max_addresses only applies with prefer all")
+		}
+		if !ValidDriftPolicy(c.Drift) {
+			add(SeverityError, path+".drift", "drift must be alert, repair or respect")
+		}
+		if c.Overlap != nil && *c.Overlap < 0 {
+			add(SeverityError, path+".overlap", "overlap must be positive")
+		}
+		if !ValidVerify(c.Verify) {
+			add(SeverityError, path+".verify", "verify must be off, ping or tcp: and a port, e.g. tcp:22")
+		}
+		if c.TrackIID && c.Prefer == PreferTemporary {
+			add(SeverityWarning, path+".track_iid", "track_iid follows the interface ID into new prefixes, which temporary addresses don't keep")
+		}
+		for j, d := range c.Destinations() {
+			dpath := path + ".target"
+			if j > 0 {
+				dpath = fmt.Sprintf("%s.also[%d]", path, j-1)
+			}
+			if d.Target != DefaultTarget && !slices.ContainsFunc(cfg.Targets, func(t target.Config) bool { return t.Name == d.Target }) {
+				add(SeverityError, dpath, "unknown target %q", d.Target)
+			}
+			if j > 0 && d.Ref == "" {
+				add(SeverityError, dpath+".ref", "ref for target %q is empty", d.Target)
+			}
+			if d.Site != "" && d.Target != DefaultTarget {
+				add(SeverityWarning, dpath+".site", "site only applies to the %s target, not %q", DefaultTarget, d.Target)
+			}
+			if d.Target == DefaultTarget && d.Ref != "" {
+				key := c.At(d).groupKey(d.Ref)
+				g, ok := groups[key]
+				if !ok {
+					g = &shared{id: d.Ref}
+					groups[key] = g
+					groupKeys = append(groupKeys, key)
+				}
+				if !slices.Contains(g.clients, i) {
+					g.clients = append(g.clients, i)
+				}
+			}
+		}
+
+		// the same client listed again for the same controller and site
+		for j, o := range cfg.Clients[:i] {
+			if !strings.EqualFold(o.MAC, c.MAC) || o.Controller != c.Controller || o.Site != c.Site {
+				continue
+			}
+			if o.TargetName() == c.TargetName() && o.GroupID == c.GroupID {
+				add(SeverityError, path, "%s duplicates clients[%d], both publishing to %s %s", c.Label(), j, c.TargetName(), c.GroupID)
+			} else {
+				add(SeverityWarning, path, "%s is also listed as clients[%d]; to publish to several entries, list them in also", c.Label(), j)
+			}
+			break
+		}
+	}
+
+	// Clients sharing a group each write their own addresses to all of it,
+	// unless they all respect drift
+	for _, key := range groupKeys {
+		g := groups[key]
+		var macs []string
+		respect := true
+		for _, i := range g.clients {
+			macs = append(macs, strings.ToLower(cfg.Clients[i].MAC))
+			respect = respect && cfg.Clients[i].Drift == DriftRespect
+		}
+		slices.Sort(macs)
+		if len(slices.Compact(macs)) < 2 || respect {
+			continue
+		}
+		var paths []string
+		for _, i := range g.clients[1:] {
+			paths = append(paths, fmt.Sprintf("clients[%d]", i))
+		}
+		add(SeverityWarning, fmt.Sprintf("clients[%d]", g.clients[0]),
+			"firewall group %s is also published to by %s: each client replaces all its members with its own addresses, unless they all set drift to respect",
+			g.id, strings.Join(paths, ", "))
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(cfg.GroupLimits)) {
+		n := cfg.GroupLimits[id]
+		path := "group_limits." + id
+		if n < 0 {
+			add(SeverityError, path, "the limit of firewall group %s must be positive", id)
+			continue
+		}
+		// a client's own addresses evicted from its group read as drift
+		for _, key := range groupKeys {
+			g := groups[key]
+			if g.id != id || n == 0 {
+				continue
+			}
+			for _, i := range g.clients {
+				if c := cfg.Clients[i]; c.Prefer == PreferAll && (c.MaxAddresses == 0 || c.MaxAddresses > n) {
+					add(SeverityWarning, path, "firewall group %s holds at most %d members, fewer than %s may publish; set its max_addresses to %d or less", id, n, c.Label(), n)
+				}
+			}
+		}
+	}
+
+	for i, l := range cfg.Log {
+		path := fmt.Sprintf("log[%d]", i)
+		if l.Output == LogFile && l.Path == "" {
+			add(SeverityError, path+".path", "a file output needs a path")
+		}
+		if l.Path != "" && l.Output != LogFile {
+			add(SeverityWarning, path+".path", "path is ignored, as the output is %q", l.Output)
+		}
+		if l.Address != "" && l.Output != LogSyslog {
+			add(SeverityWarning, path+".address", "address is ignored, as the output is %q", l.Output)
+		}
+		if l.Output == LogConsole && slices.ContainsFunc(cfg.Log[:i], func(o LogConfig) bool { return o.Output == LogConsole }) {
+			add(SeverityWarning, path, "the console is already configured, only the first console output is used")
+		}
+	}
+	return diags
+}
+
+// Locate sets the Line of each diagnostic whose entry is found in data,
+// the config file's contents.
+func Locate(data []byte, diags []Diagnostic) {
+	lines := entryLines(data)
+	for i, d := range diags {
+		// the entry itself, e.g. clients[3] of clients[3].also[0].ref
+		entry, _, _ := strings.Cut(d.Path, ".")
+		diags[i].Line = lines[entry]
+	}
+}
+
+// entryLines returns the line each element of the config's top-level
+// arrays starts on, by path, e.g. "clients[3]".
+func entryLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return lines
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return lines
+		}
+		key, _ := t.(string)
+		if t, err := dec.Token(); err != nil {
+			return lines
+		} else if t != json.Delim('[') {
+			if d, ok := t.(json.Delim); ok && d == '{' {
+				if err := skipValue(dec); err != nil {
+					return lines
+				}
+			}
+			continue
+		}
+		for i := 0; dec.More(); i++ {
+			start := dec.InputOffset()
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return lines
+			}
+			// start is just past the previous token; the element begins at
+			// its first non-blank byte
+			rest := data[start:]
+			offset := start + int64(len(rest)-len(bytes.TrimLeft(rest, " \t\r\n,")))
+			lines[fmt.Sprintf("%s[%d]", key, i)] = 1 + bytes.Count(data[:offset], []byte("\n"))
+		}
+		if _, err := dec.Token(); err != nil {
+			return lines
+		}
+	}
+	return lines
+}
+
+// skipValue reads the rest of an object or array whose opening delimiter
+// dec has just returned.
+func skipValue(dec *json.Decoder) error {
+	for depth := 1; depth > 0; {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := t.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				depth++
+			default:
+				depth--
+			}
+		}
+	}
+	return nil
+}
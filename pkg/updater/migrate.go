@@ -0,0 +1,76 @@
+package updater
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+)
+
+// ConfigVersion is the config layout this updater writes when migrating.
+// Files without a version are version 1.
+const ConfigVersion = 2
+
+// migrations bring a config from version i+1 to i+2, returning a line for
+// each change made.
+var migrations = []func(*Config) []string{
+	migrateDestinations,
+}
+
+// Migrate rewrites cfg in the layout of ConfigVersion, returning what was
+// changed. Loading a config never migrates it, so older layouts keep
+// working until migrated.
+func Migrate(cfg *Config) ([]string, error) {
+	version := max(cfg.Version, 1)
+	if version > ConfigVersion {
+		return nil, fmt.Errorf("config version %d is newer than this updater's %d", cfg.Version, ConfigVersion)
+	}
+	var changes []string
+	for _, m := range migrations[version-1:] {
+		changes = append(changes, m(cfg)...)
+	}
+	if cfg.Version != ConfigVersion {
+		changes = append(changes, fmt.Sprintf("version %d → %d", version, ConfigVersion))
+		cfg.Version = ConfigVersion
+	}
+	return changes, nil
+}
+
+// migrateDestinations writes MACs as the controller does, in lower case
+// with colons, so they match its clients, and merges entries of the same
+// client into one publishing to all their entries with also, as listing a
+// client once per group was the only way to publish it to several.
+// Entries are only merged when they agree on everything else.
+func migrateDestinations(cfg *Config) []string {
+	var changes []string
+	for i, c := range cfg.Clients {
+		if mac, err := net.ParseMAC(c.MAC); err == nil && mac.String() != c.MAC {
+			changes = append(changes, fmt.Sprintf("clients[%d]: MAC %s → %s", i, c.MAC, mac))
+			cfg.Clients[i].MAC = mac.String()
+		}
+	}
+
+	// settings returns the client without its destinations, to compare
+	settings := func(c ClientConfig) ClientConfig {
+		c.GroupID, c.Target, c.Also = "", "", nil
+		return c
+	}
+	var clients []ClientConfig
+	var from []int // the index each of clients had
+	for i, c := range cfg.Clients {
+		j := slices.IndexFunc(clients, func(o ClientConfig) bool { return reflect.DeepEqual(settings(o), settings(c)) })
+		if j < 0 {
+			clients = append(clients, c)
+			from = append(from, i)
+			continue
+		}
+		for _, d := range c.Destinations() {
+			if !slices.Contains(clients[j].Destinations(), d) {
+				clients[j].Also = append(clients[j].Also, d)
+			}
+		}
+		changes = append(changes, fmt.Sprintf("clients[%d]: merged into clients[%d] (%s), which publishes to %s %s too", i, from[j], c.Label(), c.TargetName(), c.GroupID))
+	}
+	cfg.Clients = clients
+	return changes
+}
@@ -0,0 +1,61 @@
+package updater
+
+import (
+	"slices"
+	"time"
+)
+
+// RetiringAddress is an address a client's new one replaced, kept
+// published alongside it until Until so that sessions still using it
+// aren't cut off the moment the address changes.
+type RetiringAddress struct {
+	Address string    `json:"address"`
+	Until   time.Time `json:"until"`
+}
+
+// overlap returns how long the client's replaced addresses stay published:
+// its own Overlap, or the updater's.
+func (u *Updater) overlap(c ClientConfig) time.Duration {
+	if c.Overlap != nil {
+		return time.Duration(*c.Overlap) * time.Second
+	}
+	return u.Overlap
+}
+
+// retiring returns the client's replaced addresses still published at now
+// once ipv6s is: those retiring already whose overlap hasn't ended, and,
+// when ipv6s replaces what was published, the addresses it drops. An
+// address published again stops retiring.
+func (u *Updater) retiring(c ClientConfig, ipv6s []string, now time.Time) []RetiringAddress {
+	var kept []RetiringAddress
+	for _, r := range c.Retiring {
+		if now.Before(r.Until) && !containsIP(ipv6s, r.Address) {
+			kept = append(kept, r)
+		}
+	}
+	d := u.overlap(c)
+	if d <= 0 || slices.Equal(ipv6s, c.Published()) {
+		return kept
+	}
+	for _, a := range c.Published() {
+		if !containsIP(ipv6s, a) && !containsIP(retiringAddresses(kept), a) {
+			kept = append(kept, RetiringAddress{Address: a, Until: now.Add(d).Truncate(time.Second)})
+		}
+	}
+	return kept
+}
+
+// retiringAddresses returns the addresses of retiring.
+func retiringAddresses(retiring []RetiringAddress) []string {
+	addrs := make([]string, len(retiring))
+	for i, r := range retiring {
+		addrs[i] = r.Address
+	}
+	return addrs
+}
+
+// Written returns every address the client's entries hold: those last
+// published, followed by those still retiring.
+func (c ClientConfig) Written() []string {
+	return append(slices.Clip(c.Published()), retiringAddresses(c.Retiring)...)
+}
@@ -0,0 +1,112 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How a client's new addresses are checked before they are published.
+const (
+	// VerifyOff publishes addresses without checking them.
+	VerifyOff = "off"
+	// VerifyPing publishes an address only once it answers a ping.
+	VerifyPing = "ping"
+	// VerifyTCP, followed by a port as in "tcp:22", publishes an address
+	// only once it accepts a TCP connection on that port, for clients that
+	// drop pings.
+	VerifyTCP = "tcp:"
+)
+
+// probeTimeout bounds each check of an address.
+const probeTimeout = 2 * time.Second
+
+// ValidVerify reports whether v is a known reachability check, or empty.
+func ValidVerify(v string) bool {
+	switch v {
+	case "", VerifyOff, VerifyPing:
+		return true
+	}
+	port, ok := strings.CutPrefix(v, VerifyTCP)
+	n, err := strconv.Atoi(port)
+	return ok && err == nil && n > 0 && n < 65536
+}
+
+// Probe checks that something answers at addr with the reachability check
+// verify, VerifyPing or VerifyTCP and a port. Pings are sent with the ping
+// command, which must be installed.
+func Probe(verify, addr string) error {
+	if port, ok := strings.CutPrefix(verify, VerifyTCP); ok {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(addr, port), probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout+time.Second)
+	defer cancel()
+	wait := strconv.Itoa(int(probeTimeout.Seconds()))
+	err := exec.CommandContext(ctx, "ping", "-6", "-n", "-c", "1", "-W", wait, addr).Run()
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		return errors.New("no answer to ping")
+	}
+	return err
+}
+
+// verify returns the client's reachability check: the updater's, or the
+// client's own in place of it. Empty means none.
+func (u *Updater) verify(c ClientConfig) string {
+	v := u.Verify
+	if c.Verify != "" {
+		v = c.Verify
+	}
+	if v == VerifyOff {
+		return ""
+	}
+	return v
+}
+
+// reachable returns the addresses among addrs that are either published
+// already or pass the client's reachability check, and those that don't.
+// Checks run in parallel, so a client with several new addresses takes no
+// longer than one.
+func (u *Updater) reachable(c ClientConfig, addrs []string) (kept, dropped []string) {
+	verify := u.verify(c)
+	probe := u.Probe
+	if probe == nil {
+		probe = Probe
+	}
+
+	errs := make([]error, len(addrs))
+	done := make(chan struct{})
+	n := 0
+	for i, a := range addrs {
+		ip := net.ParseIP(strings.TrimSpace(a))
+		if ip == nil || ip.To4() != nil || !ip.IsGlobalUnicast() ||
+			slices.ContainsFunc(c.Published(), func(p string) bool { return ip.Equal(net.ParseIP(p)) }) {
+			continue // not a candidate, or proven when it was published
+		}
+		n++
+		go func() {
+			errs[i] = probe(verify, ip.String())
+			done <- struct{}{}
+		}()
+	}
+	for range n {
+		<-done
+	}
+
+	for i, a := range addrs {
+		if errs[i] != nil {
+			dropped = append(dropped, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	return kept, dropped
+}
@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"strings"
+	"time"
+)
+
+// batchWindow is the fraction of a client's interval by which it may be
+// checked early, so clients coming due soon share a cycle's controller
+// reads instead of each causing their own.
+const batchWindow = 10
+
+// interval returns how often c is checked by RunDue.
+func (u *Updater) interval(c ClientConfig) time.Duration {
+	switch {
+	case c.Interval > 0:
+		return time.Duration(c.Interval) * time.Second
+	case u.Interval > 0:
+		return u.Interval
+	}
+	return time.Hour
+}
+
+// due reports which clients to check at now: all of them unless dueOnly,
+// and otherwise those whose next check is at most a tenth of their interval
+// away. The clients checked are scheduled again one interval from now;
+// clients no longer in the config are forgotten.
+func (u *Updater) due(clients []ClientConfig, now time.Time, dueOnly bool) []bool {
+	u.scheduleMu.Lock()
+	defer u.scheduleMu.Unlock()
+
+	next := make(map[string]time.Time, len(clients))
+	due := make([]bool, len(clients))
+	for i, c := range clients {
+		key := scheduleKey(c)
+		interval := u.interval(c)
+		at, ok := u.next[key]
+		due[i] = !dueOnly || !ok || !now.Before(at.Add(-interval/batchWindow))
+		if due[i] {
+			at = now.Add(interval)
+		}
+		next[key] = at
+	}
+	u.next = next
+	return due
+}
+
+// scheduleKey identifies the client in the schedule.
+func scheduleKey(c ClientConfig) string {
+	return strings.ToLower(c.MAC) + "|" + c.Target + "|" + c.GroupID
+}
+
+// wakeAt brings the client's next check forward to at, when it is later.
+func (u *Updater) wakeAt(c ClientConfig, at time.Time) {
+	u.scheduleMu.Lock()
+	defer u.scheduleMu.Unlock()
+	key := scheduleKey(c)
+	if next, ok := u.next[key]; ok && at.Before(next) {
+		u.next[key] = at
+	}
+}
+
+// NextCheck returns when RunDue next checks the client's entry for the
+// group, the earliest of its targets', and false if it isn't scheduled.
+func (u *Updater) NextCheck(mac, groupID string) (time.Time, bool) {
+	u.scheduleMu.Lock()
+	defer u.scheduleMu.Unlock()
+
+	var earliest time.Time
+	for key, at := range u.next {
+		if strings.HasPrefix(key, strings.ToLower(mac)+"|") && strings.HasSuffix(key, "|"+groupID) &&
+			(earliest.IsZero() || at.Before(earliest)) {
+			earliest = at
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// NextDue returns how long until RunDue will have a client to check, going
+// by the clients of the last cycle, or the default interval before the
+// first.
+func (u *Updater) NextDue() time.Duration {
+	u.scheduleMu.Lock()
+	defer u.scheduleMu.Unlock()
+
+	if len(u.next) == 0 {
+		return u.interval(ClientConfig{})
+	}
+	var earliest time.Time
+	for _, at := range u.next {
+		if earliest.IsZero() || at.Before(earliest) {
+			earliest = at
+		}
+	}
+	return max(time.Until(earliest), time.Second)
+}
@@ -0,0 +1,304 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+)
+
+// schemaRules are what the Go types don't say about the config's fields,
+// by type and JSON name; "required" lists a type's required fields.
+var schemaRules = map[string]map[string]map[string]any{
+	"updater.Config": {
+		"required":     {"fields": []string{"clients"}},
+		"version":      {"minimum": 1, "maximum": ConfigVersion},
+		"group_limits": {"additionalProperties": map[string]any{"type": "integer", "minimum": 0}},
+	},
+	"updater.ClientConfig": {
+		"required":      {"fields": []string{"mac", "group_id"}},
+		"mac":           {"pattern": `^[0-9A-Fa-f]{2}([:-][0-9A-Fa-f]{2}){5}$`},
+		"group_id":      {"minLength": 1},
+		"interval":      {"minimum": 0},
+		"prefer":        {"enum": []string{PreferFirst, PreferStable, PreferTemporary, PreferAll}},
+		"max_addresses": {"minimum": 0},
+		"drift":         {"enum": []string{DriftAlert, DriftRepair, DriftRespect}},
+		"verify":        {"pattern": `^(off|ping|tcp:[0-9]{1,5})$`},
+		"overlap":       {"minimum": 0},
+	},
+	"updater.RetiringAddress": {
+		"required": {"fields": []string{"address", "until"}},
+	},
+	"updater.Destination": {
+		"required": {"fields": []string{"target", "ref"}},
+	},
+	"updater.ControllerConfig": {
+		"required": {"fields": []string{"name", "api_key"}},
+	},
+	"updater.LogConfig": {
+		"required": {"fields": []string{"output"}},
+		"output":   {"enum": []string{LogConsole, LogFile, LogSyslog}},
+		"address":  {"pattern": `^(udp|tcp)://.+$`},
+		"format":   {"enum": []string{LogText, LogJSON}},
+		"level":    {"enum": []string{SeverityInfo, SeverityWarning, SeverityError}},
+	},
+	"updater.PrefixMove": {
+		"required": {"fields": []string{"from", "to"}},
+	},
+	"notify.Config": {
+		"required":     {"fields": []string{"type", "url"}},
+		"type":         {"enum": []string{"slack", "webhook", "mqtt"}},
+		"events":       {"items": map[string]any{"type": "string", "enum": []string{notify.KindChange, notify.KindFailure, notify.KindNotFound, notify.KindDrift, notify.KindEviction, notify.KindAll}}},
+		"min_severity": {"enum": []string{"info", "warning", "error"}},
+		"quiet_hours":  {"pattern": `^[0-9]{1,2}:[0-9]{2}-[0-9]{1,2}:[0-9]{2}$`},
+		"digest":       {"pattern": `^(([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+|[0-9]{1,2}:[0-9]{2})$`},
+	},
+	"target.Config": {
+		"required": {"fields": []string{"name", "type"}},
+		"type": {"enum": []string{"opnsense", "pfsense", "mikrotik", "nftables", "ipset", "aws_security_group",
+			"cloudflare", "route53", "duckdns", "desec", "dynv6", "rfc2136"}},
+		"port": {"minimum": 0},
+		"ttl":  {"minimum": 0},
+	},
+}
+
+// Schema returns a JSON Schema of the config file, generated from Config,
+// for editors to complete and check configs with.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	root := schemaOf(reflect.TypeFor[Config](), defs)
+	s := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "unifi-ipv6-client-firewall-updater config",
+		"$defs":   defs,
+	}
+	name := strings.TrimPrefix(root["$ref"].(string), "#/$defs/")
+	for k, v := range defs[name].(map[string]any) {
+		s[k] = v
+	}
+	delete(defs, name)
+	return s
+}
+
+// schemaOf returns the schema of values of type t, adding those of the
+// structs it uses to defs.
+func schemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(schemaOf(t.Elem(), defs))
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// nil slices are saved as null
+		return nullable(map[string]any{"type": "array", "items": schemaOf(t.Elem(), defs)})
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+	default:
+		return map[string]any{}
+	}
+
+	name := t.String()
+	ref := map[string]any{"$ref": "#/$defs/" + name}
+	if _, ok := defs[name]; ok {
+		return ref
+	}
+	def := map[string]any{"type": "object", "additionalProperties": false}
+	defs[name] = def // before the fields, for types that contain themselves
+	props := map[string]any{}
+	rules := schemaRules[name]
+	for i := range t.NumField() {
+		f := t.Field(i)
+		field, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || field == "-" {
+			continue
+		}
+		if field == "" {
+			field = f.Name
+		}
+		prop := schemaOf(f.Type, defs)
+		for k, v := range rules[field] {
+			prop[k] = v
+		}
+		props[field] = prop
+	}
+	def["properties"] = props
+	if required, ok := rules["required"]; ok {
+		def["required"] = required["fields"]
+	}
+	return ref
+}
+
+// nullable allows null in place of values of schema s.
+func nullable(s map[string]any) map[string]any {
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+	}
+	return s
+}
+
+// CheckSchema validates the config file's contents against Schema,
+// returning a diagnostic for each value that doesn't match, located by
+// its path, and for JSON that doesn't parse, by its line.
+func CheckSchema(data []byte) []Diagnostic {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		d := Diagnostic{Severity: SeverityError, Message: err.Error()}
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			d.Line = 1 + bytes.Count(data[:syntax.Offset], []byte("\n"))
+		}
+		return []Diagnostic{d}
+	}
+
+	schema := Schema()
+	v := &schemaValidator{defs: schema["$defs"].(map[string]any)}
+	v.check(schema, doc, "")
+	Locate(data, v.diags)
+	return v.diags
+}
+
+type schemaValidator struct {
+	defs  map[string]any
+	diags []Diagnostic
+}
+
+func (v *schemaValidator) fail(path, format string, args ...any) {
+	v.diags = append(v.diags, Diagnostic{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check validates value at path against schema, with the keywords Schema
+// generates.
+func (v *schemaValidator) check(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = v.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+	}
+	types, _ := schema["type"].([]string)
+	if typ, ok := schema["type"].(string); ok {
+		types = []string{typ}
+	}
+	if len(types) > 0 {
+		if value == nil && slices.Contains(types, "null") {
+			return
+		}
+		if got := jsonType(value, types[0]); got != types[0] {
+			v.fail(path, "must be %s %s, not %s", article(types[0]), types[0], got)
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]string); ok {
+		if s, _ := value.(string); !slices.Contains(enum, s) {
+			v.fail(path, "%q must be one of %s", s, strings.Join(enum, ", "))
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, _ := value.(string); !regexp.MustCompile(pattern).MatchString(s) {
+			v.fail(path, "%q does not match %s", s, pattern)
+		}
+	}
+	if n, ok := schema["minLength"].(int); ok {
+		if s, _ := value.(string); len(s) < n {
+			v.fail(path, "must not be empty")
+		}
+	}
+	if min, ok := schema["minimum"].(int); ok {
+		if n, err := value.(json.Number).Int64(); err == nil && n < int64(min) {
+			v.fail(path, "must be at least %d", min)
+		}
+	}
+	if max, ok := schema["maximum"].(int); ok {
+		if n, err := value.(json.Number).Int64(); err == nil && n > int64(max) {
+			v.fail(path, "must be at most %d", max)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			prop, ok := props[k].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					v.fail(join(path, k), "unknown field %q", k)
+				} else if extra, ok := schema["additionalProperties"].(map[string]any); ok {
+					v.check(extra, value[k], join(path, k))
+				}
+				continue
+			}
+			v.check(prop, value[k], join(path, k))
+		}
+		required, _ := schema["required"].([]string)
+		for _, k := range required {
+			if _, ok := value[k]; !ok {
+				v.fail(join(path, k), "is required")
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				v.check(items, item, path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	}
+}
+
+// join returns the path of the field key of the object at path.
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonType returns the JSON Schema type of value, reporting whole numbers
+// as integers when want is.
+func jsonType(value any, want string) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil && want == "integer" {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+func article(typ string) string {
+	if strings.ContainsRune("aeiou", rune(typ[0])) {
+		return "an"
+	}
+	return "a"
+}
@@ -0,0 +1,183 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// Which of a client's global addresses is published.
+const (
+	// PreferFirst publishes the first address reported for the client.
+	PreferFirst = "first"
+	// PreferStable publishes an address that outlives the temporary ones,
+	// e.g. for servers and IoT devices reached from outside.
+	PreferStable = "stable"
+	// PreferTemporary publishes a privacy (temporary) address, the one a
+	// workstation makes its outgoing connections from.
+	PreferTemporary = "temporary"
+	// PreferAll publishes every address, up to MaxAddresses: stable ones
+	// first, then temporary ones not yet published, then those already
+	// published.
+	PreferAll = "all"
+)
+
+// Selection is how a client's address is chosen among those it has.
+type Selection struct {
+	// Prefer is PreferFirst, PreferStable, PreferTemporary or PreferAll;
+	// empty means PreferFirst.
+	Prefer string
+	// AllowULA also accepts unique local addresses (fc00::/7), e.g. for
+	// clients only reachable inside the network.
+	AllowULA bool
+	// MaxAddresses caps how many addresses PreferAll publishes, so privacy
+	// extensions can't grow an entry without bound; 0 means no cap.
+	MaxAddresses int
+}
+
+// ValidPreference reports whether p is a known preference, or empty.
+func ValidPreference(p string) bool {
+	switch p {
+	case "", PreferFirst, PreferStable, PreferTemporary, PreferAll:
+		return true
+	}
+	return false
+}
+
+// selection returns the client's selection: the updater's, with the
+// client's own settings in place of it.
+func (u *Updater) selection(c ClientConfig) Selection {
+	s := u.Selection
+	if c.Prefer != "" {
+		s.Prefer = c.Prefer
+	}
+	if c.AllowULA != nil {
+		s.AllowULA = *c.AllowULA
+	}
+	if c.MaxAddresses > 0 {
+		s.MaxAddresses = c.MaxAddresses
+	}
+	return s
+}
+
+// Pick returns the address to publish among addresses, or with PreferAll
+// the first of them. iid is the interface ID of the client's last
+// published address, if any.
+//
+// The controller doesn't say which addresses are temporary, so an address
+// is taken as stable when its interface ID is EUI-64, looks statically
+// assigned (e.g. ::10) or is the one last published; temporary addresses
+// get a new random one every day or so. Clients with a stable privacy
+// address (RFC 7217) settle on it once it has been published.
+func (s Selection) Pick(addresses []string, iid string) (string, error) {
+	candidates, err := s.candidates(addresses)
+	if err != nil {
+		return "", err
+	}
+
+	switch s.Prefer {
+	case "", PreferFirst:
+	case PreferStable, PreferTemporary:
+		for _, ip := range candidates {
+			if stableAddress(ip, iid) == (s.Prefer == PreferStable) {
+				return ip.String(), nil
+			}
+		}
+	case PreferAll:
+		return s.ordered(candidates, iid, nil)[0], nil
+	default:
+		return "", fmt.Errorf("unknown address preference %q", s.Prefer)
+	}
+	return candidates[0].String(), nil
+}
+
+// Select returns the addresses to publish among addresses: every one with
+// PreferAll, and otherwise the one Pick returns. published are the
+// addresses last published, which count as older than the others.
+func (s Selection) Select(addresses []string, iid string, published []string) ([]string, error) {
+	if s.Prefer != PreferAll {
+		ip, err := s.Pick(addresses, iid)
+		if err != nil {
+			return nil, err
+		}
+		return []string{ip}, nil
+	}
+	candidates, err := s.candidates(addresses)
+	if err != nil {
+		return nil, err
+	}
+	return s.ordered(candidates, iid, published), nil
+}
+
+// candidates returns the addresses the selection accepts, in the order
+// given.
+func (s Selection) candidates(addresses []string) ([]net.IP, error) {
+	var candidates []net.IP
+	var ula bool
+	for _, a := range addresses {
+		ip := net.ParseIP(strings.TrimSpace(a))
+		if ip == nil || ip.To4() != nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+		if ip[0]&0xfe == 0xfc && !s.AllowULA {
+			ula = true
+			continue
+		}
+		candidates = append(candidates, ip)
+	}
+	if len(candidates) == 0 {
+		if ula {
+			return nil, errors.New("only unique local IPv6 addresses found, which aren't allowed")
+		}
+		return nil, errors.New("no valid global IPv6 found")
+	}
+	return candidates, nil
+}
+
+// ordered returns the distinct candidates in the order PreferAll publishes
+// them, up to MaxAddresses: stable addresses, then temporary ones not yet
+// published, then those already published. The controller doesn't report
+// how old an address is, so an address not yet published stands in for a
+// newer one. Each group is sorted numerically, so the same addresses
+// always come out in the same order.
+func (s Selection) ordered(candidates []net.IP, iid string, published []string) []string {
+	var stable, fresh, old []net.IP
+	for _, ip := range candidates {
+		switch {
+		case slices.ContainsFunc(stable, ip.Equal), slices.ContainsFunc(fresh, ip.Equal), slices.ContainsFunc(old, ip.Equal):
+		case stableAddress(ip, iid):
+			stable = append(stable, ip)
+		case slices.ContainsFunc(published, func(p string) bool { return ip.Equal(net.ParseIP(p)) }):
+			old = append(old, ip)
+		default:
+			fresh = append(fresh, ip)
+		}
+	}
+	var addrs []string
+	for _, group := range [][]net.IP{stable, fresh, old} {
+		slices.SortFunc(group, func(a, b net.IP) int { return bytes.Compare(a.To16(), b.To16()) })
+		for _, ip := range group {
+			addrs = append(addrs, ip.String())
+		}
+	}
+	if s.MaxAddresses > 0 && len(addrs) > s.MaxAddresses {
+		addrs = addrs[:s.MaxAddresses]
+	}
+	return addrs
+}
+
+// stableAddress reports whether ip looks like it outlives temporary
+// addresses.
+func stableAddress(ip net.IP, iid string) bool {
+	ip = ip.To16()
+	if ip[11] == 0xff && ip[12] == 0xfe {
+		return true // EUI-64
+	}
+	if ip[8]|ip[9]|ip[10]|ip[11]|ip[12]|ip[13] == 0 {
+		return true // static or DHCPv6, e.g. ::10 or ::1:2
+	}
+	return iid != "" && interfaceID(ip.String()) == iid
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+const (
+	eui64  = "2001:db8:1:2:211:22ff:fe33:4455"
+	static = "2001:db8:1:2::10"
+	temp1  = "2001:db8:1:2:a1b2:c3d4:e5f6:1789"
+	temp2  = "2001:db8:1:2:5e6f:7788:99aa:bbcc"
+	temp3  = "2001:db8:1:2:3c4d:1122:3344:5566"
+	ula    = "fd00:1:2:3::1"
+)
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name      string
+		sel       Selection
+		addresses []string
+		iid       string
+		published []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "first", addresses: []string{"fe80::1", temp1, eui64}, want: []string{temp1}},
+		{name: "stable", sel: Selection{Prefer: PreferStable}, addresses: []string{temp1, eui64}, want: []string{eui64}},
+		{name: "static counts as stable", sel: Selection{Prefer: PreferStable}, addresses: []string{temp1, static}, want: []string{static}},
+		{name: "stable by last published", sel: Selection{Prefer: PreferStable}, addresses: []string{temp1, temp2}, iid: interfaceID(temp2), want: []string{temp2}},
+		{name: "stable falls back to first", sel: Selection{Prefer: PreferStable}, addresses: []string{temp1, temp2}, want: []string{temp1}},
+		{name: "temporary", sel: Selection{Prefer: PreferTemporary}, addresses: []string{eui64, temp1}, want: []string{temp1}},
+		{name: "ULA skipped", addresses: []string{ula, temp1}, want: []string{temp1}},
+		{name: "ULA only", addresses: []string{ula}, wantErr: true},
+		{name: "ULA allowed", sel: Selection{AllowULA: true}, addresses: []string{ula}, want: []string{ula}},
+		{name: "no global", addresses: []string{"fe80::1", "192.168.1.2"}, wantErr: true},
+		{name: "all", sel: Selection{Prefer: PreferAll}, addresses: []string{temp1, eui64, temp2}, published: []string{temp2}, want: []string{eui64, temp1, temp2}},
+		{name: "unknown preference", sel: Selection{Prefer: "newest"}, addresses: []string{temp1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.sel.Select(tt.addresses, tt.iid, tt.published)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	parse := func(addrs ...string) []net.IP {
+		ips := make([]net.IP, len(addrs))
+		for i, a := range addrs {
+			ips[i] = net.ParseIP(a)
+		}
+		return ips
+	}
+	published := []string{temp1}
+	// stable first, then the temporary addresses not yet published in
+	// numeric order, then those already published
+	want := []string{eui64, temp3, temp2, temp1}
+
+	for _, candidates := range [][]net.IP{
+		parse(temp1, temp2, temp3, eui64),
+		parse(eui64, temp3, temp1, temp2, temp3),
+		parse(temp2, eui64, temp1, temp3),
+	} {
+		if got := (Selection{}).ordered(candidates, "", published); !slices.Equal(got, want) {
+			t.Errorf("ordered(%v) = %v, want %v", candidates, got, want)
+		}
+	}
+
+	got := Selection{MaxAddresses: 2}.ordered(parse(temp1, temp2, temp3, eui64), "", published)
+	if want := []string{eui64, temp3}; !slices.Equal(got, want) {
+		t.Errorf("ordered() with MaxAddresses 2 = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// ControllerConfig is a further UniFi controller clients can be on, besides
+// the updater's own.
+type ControllerConfig struct {
+	// Name is what clients select the controller by.
+	Name string `json:"name"`
+	// Host is the controller's URL, e.g. https://192.168.2.1.
+	Host   string `json:"host"`
+	APIKey string `json:"api_key"`
+	// Insecure skips TLS certificate verification.
+	Insecure bool `json:"insecure,omitempty"`
+	// ConsoleID reaches the controller through the Site Manager in place
+	// of Host, with APIKey a Site Manager API key.
+	ConsoleID string `json:"console_id,omitempty"`
+}
+
+// URL returns the URL the controller is reached at: Host, or its console's
+// through the Site Manager.
+func (cc ControllerConfig) URL() string {
+	if cc.Host == "" && cc.ConsoleID != "" {
+		return unifi.ConsoleURL(cc.ConsoleID)
+	}
+	return cc.Host
+}
+
+// site is a controller site other than Controller's own that clients due
+// this cycle are on, with what was read from it.
+type site struct {
+	name      string
+	sources   []Source
+	snapshots []map[string][]string
+	groups    *FirewallGroupTarget
+	// err is why the site's clients can't be reconciled this cycle.
+	err error
+}
+
+// siteKey identifies the controller and site the client is on, or is
+// empty for Controller's own site.
+func (c ClientConfig) siteKey() string {
+	if c.Controller == "" && c.Site == "" {
+		return ""
+	}
+	return c.Controller + "/" + c.Site
+}
+
+// siteName describes the client's controller and site in logs.
+func (c ClientConfig) siteName() string {
+	name := "site " + c.Site
+	if c.Site == "" {
+		name = "default site"
+	}
+	if c.Controller != "" {
+		name += " of controller " + c.Controller
+	}
+	return name
+}
+
+// sites connects to the sites of the clients due this cycle and of their
+// destinations, other than Controller's own, reading each one's connected
+// clients and, if a client publishes to DefaultTarget there, its firewall
+// groups.
+func (u *Updater) sites(cfg *Config, due []bool) map[string]*site {
+	sites := map[string]*site{}
+	for i, client := range cfg.Clients {
+		if !due[i] || !client.IsEnabled() {
+			continue
+		}
+		for _, d := range client.Destinations() {
+			u.site(sites, cfg, client.At(d), d.Target == DefaultTarget)
+		}
+	}
+	return sites
+}
+
+// site adds the site of the client c to sites, reading its firewall groups
+// too if groups is set.
+func (u *Updater) site(sites map[string]*site, cfg *Config, c ClientConfig, groups bool) {
+	key := c.siteKey()
+	if key == "" {
+		return
+	}
+	s, ok := sites[key]
+	if !ok {
+		s = &site{name: c.siteName()}
+		sites[key] = s
+		ctrl, err := u.connect(cfg, c)
+		if err != nil {
+			s.err = err
+			return
+		}
+		track(ctrl, cfg)
+		s.sources = []Source{StationSource{ctrl}}
+		if u.IncludeOffline {
+			s.sources = append(s.sources, KnownStationSource{ctrl})
+		}
+		s.snapshots = make([]map[string][]string, len(s.sources))
+		if s.snapshots[0], err = s.sources[0].Addresses(); err != nil {
+			s.err = fmt.Errorf("get %s: %w", s.sources[0].Name(), err)
+			return
+		}
+		s.groups = u.firewallGroups(ctrl, cfg)
+	}
+	if s.err == nil && groups && !s.groups.read() {
+		if err := s.groups.Refresh(); err != nil {
+			s.err = err
+		}
+	}
+}
+
+// connect returns the controller of the client's site.
+func (u *Updater) connect(cfg *Config, c ClientConfig) (Controller, error) {
+	if u.Connect == nil {
+		return nil, errors.New("clients on other sites or controllers are not supported")
+	}
+	var cc *ControllerConfig
+	if c.Controller != "" {
+		i := slices.IndexFunc(cfg.Controllers, func(cc ControllerConfig) bool { return cc.Name == c.Controller })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown controller %q", c.Controller)
+		}
+		cc = &cfg.Controllers[i]
+	}
+	return u.Connect(cc, c.Site)
+}
@@ -0,0 +1,118 @@
+package updater
+
+import (
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// Source is somewhere the engine learns clients' current addresses from.
+type Source interface {
+	// Name identifies the source in logs.
+	Name() string
+	// Addresses returns the addresses of every client the source knows,
+	// keyed by lower-case MAC. It is called at most once per cycle.
+	Addresses() (map[string][]string, error)
+}
+
+// StationSource reports the clients currently connected to the controller.
+type StationSource struct {
+	Controller interface {
+		Stations() ([]unifi.Station, error)
+	}
+}
+
+func (StationSource) Name() string { return "connected clients" }
+
+func (s StationSource) Addresses() (map[string][]string, error) {
+	stations, err := s.Controller.Stations()
+	if err != nil {
+		return nil, err
+	}
+	return stationAddresses(stations), nil
+}
+
+// KnownStationSource reports the last addresses of every client the
+// controller remembers, including offline ones.
+type KnownStationSource struct {
+	Controller interface {
+		KnownStations() ([]unifi.Station, error)
+	}
+}
+
+func (KnownStationSource) Name() string { return "known clients" }
+
+func (s KnownStationSource) Addresses() (map[string][]string, error) {
+	stations, err := s.Controller.KnownStations()
+	if err != nil {
+		return nil, err
+	}
+	return stationAddresses(stations), nil
+}
+
+// track limits the controller's listings of clients to those in the
+// config, if it can, so it doesn't hold every client of large sites in
+// memory.
+func track(ctrl any, cfg *Config) {
+	t, ok := ctrl.(interface{ Track(macs ...string) })
+	if !ok {
+		return
+	}
+	macs := make([]string, len(cfg.Clients))
+	for i, c := range cfg.Clients {
+		macs[i] = c.MAC
+	}
+	t.Track(macs...)
+}
+
+func stationAddresses(stations []unifi.Station) map[string][]string {
+	addrs := make(map[string][]string, len(stations))
+	for _, s := range stations {
+		addrs[strings.ToLower(s.MAC)] = s.IPv6Addresses
+	}
+	return addrs
+}
+
+// PushSource layers addresses pushed by the clients themselves over those
+// of Base, which it otherwise reports unchanged. A pushed address is kept
+// until the client pushes another.
+type PushSource struct {
+	Base Source
+
+	mu     sync.Mutex
+	pushed map[string][]string
+}
+
+func (s *PushSource) Name() string { return s.Base.Name() }
+
+func (s *PushSource) Addresses() (map[string][]string, error) {
+	addrs, err := s.Base.Addresses()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for mac, a := range s.pushed {
+		addrs[mac] = a
+	}
+	return addrs, nil
+}
+
+// Push records addrs as the current addresses of the client mac.
+func (s *PushSource) Push(mac string, addrs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pushed == nil {
+		s.pushed = map[string][]string{}
+	}
+	s.pushed[strings.ToLower(mac)] = addrs
+}
+
+// Pushed returns a copy of the addresses pushed so far, by MAC.
+func (s *PushSource) Pushed() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.pushed)
+}
@@ -0,0 +1,178 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Per-client cycle results.
+const (
+	ResultUnchanged = "unchanged"
+	ResultUpdated   = "updated"
+	ResultNotFound  = "not_found"
+	ResultNoIPv6    = "no_ipv6"
+	ResultFailed    = "failed"
+	ResultPaused    = "paused"
+	ResultDisabled  = "disabled"
+	ResultNotDue    = "not_due"
+)
+
+// Status is the outcome of a single cycle, written to the status file so
+// monitors and scripts can check on the updater without parsing logs.
+type Status struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	DurationMS int64          `json:"duration_ms"`
+	Success    bool           `json:"success"`
+	Summary    Summary        `json:"summary"`
+	Clients    []ClientStatus `json:"clients"`
+	Errors     []string       `json:"errors,omitempty"`
+	// Endpoint is the controller URL the cycle used, which is a fallback
+	// one while the primary can't be reached.
+	Endpoint string `json:"endpoint,omitempty"`
+	// RecentErrors carries the errors of the last few cycles, newest first.
+	RecentErrors []StatusError `json:"recent_errors,omitempty"`
+}
+
+// Summary counts what happened during a single cycle
+type Summary struct {
+	Checked int `json:"checked"`
+	Found   int `json:"found"`
+	Missing int `json:"missing"`
+	NoIPv6  int `json:"no_ipv6"`
+	Changed int `json:"changed"`
+	Updated int `json:"updated"`
+	Paused  int `json:"paused"`
+	Errors  int `json:"errors"`
+	Drifted int `json:"drifted"`
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d checked, %d found, %d missing, %d without global IPv6, %d changed, %d groups updated, %d paused, %d errors, %d groups drifted",
+		s.Checked, s.Found, s.Missing, s.NoIPv6, s.Changed, s.Updated, s.Paused, s.Errors, s.Drifted)
+}
+
+// StatusError is an error recorded in the status file.
+type StatusError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// MaxRecentErrors bounds the errors kept in the status file.
+const MaxRecentErrors = 20
+
+// ClientStatus is the outcome of a cycle for a single client.
+type ClientStatus struct {
+	MAC       string   `json:"mac"`
+	Name      string   `json:"name,omitempty"`
+	GroupID   string   `json:"group_id"`
+	IPv6      string   `json:"ipv6,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	// Hostnames are the names the addresses resolve back to, by address.
+	Hostnames    map[string]string `json:"hostnames,omitempty"`
+	PreviousIPv6 string            `json:"previous_ipv6,omitempty"`
+	LastChanged  time.Time         `json:"last_changed,omitzero"`
+	Result       string            `json:"result"`
+	Error        string            `json:"error,omitempty"`
+	Paused       bool              `json:"paused,omitempty"`
+}
+
+// Label returns the client's name and MAC, or just the MAC.
+func (c ClientStatus) Label() string { return label(c.Name, c.MAC) }
+
+// Finish stamps the status with the cycle's timing and overall result.
+func (st *Status) Finish(started time.Time, err error) {
+	st.Timestamp = started
+	st.DurationMS = time.Since(started).Milliseconds()
+	st.Success = err == nil
+	st.Errors = nil
+	if err == nil {
+		return
+	}
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, e := range joined.Unwrap() {
+			st.Errors = append(st.Errors, e.Error())
+		}
+		return
+	}
+	st.Errors = []string{err.Error()}
+}
+
+// CarryOver brings forward what the previous status knew that this cycle
+// did not learn itself: recent errors, each client's previous address,
+// time of last change and hostnames, the last outcome of clients that were not due, and
+// the client list if this cycle never got to it.
+func (st *Status) CarryOver(prev *Status) {
+	st.RecentErrors = nil
+	for _, e := range st.Errors {
+		st.RecentErrors = append(st.RecentErrors, StatusError{Time: st.Timestamp, Error: e})
+	}
+	st.RecentErrors = append(st.RecentErrors, prev.RecentErrors...)
+	if len(st.RecentErrors) > MaxRecentErrors {
+		st.RecentErrors = st.RecentErrors[:MaxRecentErrors]
+	}
+
+	if len(st.Clients) == 0 {
+		st.Clients = prev.Clients
+		return
+	}
+	for i, c := range st.Clients {
+		if c.Result == ResultUpdated {
+			continue
+		}
+		for _, p := range prev.Clients {
+			if strings.EqualFold(p.MAC, c.MAC) {
+				if c.Result == ResultNotDue && p.Result != "" {
+					// not checked this cycle, so the last outcome stands
+					st.Clients[i] = p
+					break
+				}
+				st.Clients[i].PreviousIPv6 = p.PreviousIPv6
+				st.Clients[i].LastChanged = p.LastChanged
+				if p.IPv6 == c.IPv6 {
+					st.Clients[i].Hostnames = p.Hostnames
+				}
+				break
+			}
+		}
+	}
+}
+
+// SaveStatus atomically replaces the status file at path.
+func SaveStatus(path string, st *Status) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadStatus reads the status file written by SaveStatus.
+func LoadStatus(path string) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st Status
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
@@ -0,0 +1,233 @@
+package updater
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// DefaultTarget is the name of the UniFi firewall group target, used by
+// clients that don't name one.
+const DefaultTarget = "unifi"
+
+// Target is somewhere the engine publishes client addresses to.
+type Target interface {
+	// Update makes ipv6 the address of the entry ref (e.g. a firewall
+	// group ID), reporting whether anything had to change.
+	Update(ref, ipv6 string) (changed bool, err error)
+}
+
+// MultiTarget is implemented by targets whose entries can hold several
+// addresses. Other targets are given only the first address of clients
+// publishing several.
+type MultiTarget interface {
+	// UpdateAll makes ipv6s the addresses of the entry ref.
+	UpdateAll(ref string, ipv6s []string) (changed bool, err error)
+}
+
+// Clearer is implemented by targets whose entries can be left without any
+// address, for clients with AllowEmpty.
+type Clearer interface {
+	// Clear removes every address of the entry ref.
+	Clear(ref string) (changed bool, err error)
+}
+
+// Refresher is implemented by targets that read their current state once
+// per cycle, before any Update. A failure aborts the cycle.
+type Refresher interface {
+	Refresh() error
+}
+
+// FirewallGroupTarget publishes addresses as the members of UniFi firewall
+// groups. Groups are read once per cycle, and updates that
+// wouldn't change a group are skipped.
+type FirewallGroupTarget struct {
+	Controller interface {
+		FirewallGroups() ([]unifi.FirewallGroup, error)
+		UpdateFirewallGroup(group unifi.FirewallGroup, members ...string) error
+		ClearFirewallGroup(group unifi.FirewallGroup) error
+	}
+
+	// MaxMembers caps the members of every group, and Limits those of the
+	// groups they name by ID in place of it; 0 means no cap. Members are
+	// ranked in the order they are given, and those past the cap are
+	// evicted. Evicted, if set, is told which.
+	MaxMembers int
+	Limits     map[string]int
+	Evicted    func(group unifi.FirewallGroup, limit int, evicted []string)
+
+	mu      sync.Mutex
+	groups  map[string]unifi.FirewallGroup
+	evicted map[string][]string    // the members evicted from each group
+	writing map[string]*sync.Mutex // held from reading a group to writing it
+}
+
+func (t *FirewallGroupTarget) Refresh() error {
+	list, err := t.Controller.FirewallGroups()
+	if err != nil {
+		return fmt.Errorf("get firewall groups: %w", err)
+	}
+	groups := make(map[string]unifi.FirewallGroup, len(list))
+	for _, g := range list {
+		groups[g.ID] = g
+	}
+	t.mu.Lock()
+	t.groups = groups
+	t.mu.Unlock()
+	return nil
+}
+
+// read reports whether the groups have been read.
+func (t *FirewallGroupTarget) read() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.groups != nil
+}
+
+func (t *FirewallGroupTarget) Update(groupID, ipv6 string) (bool, error) {
+	return t.UpdateAll(groupID, []string{ipv6})
+}
+
+// lock serializes the changes to a group, as clients sharing it are
+// reconciled concurrently and each change is worked out from its members.
+func (t *FirewallGroupTarget) lock(groupID string) (unlock func()) {
+	t.mu.Lock()
+	if t.writing == nil {
+		t.writing = map[string]*sync.Mutex{}
+	}
+	m, ok := t.writing[groupID]
+	if !ok {
+		m = &sync.Mutex{}
+		t.writing[groupID] = m
+	}
+	t.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
+
+func (t *FirewallGroupTarget) UpdateAll(groupID string, ipv6s []string) (bool, error) {
+	defer t.lock(groupID)()
+	return t.updateAll(groupID, ipv6s)
+}
+
+func (t *FirewallGroupTarget) updateAll(groupID string, ipv6s []string) (bool, error) {
+	if len(ipv6s) == 0 {
+		return false, fmt.Errorf("refusing to remove every member of firewall group %s", groupID)
+	}
+	t.mu.Lock()
+	group, ok := t.groups[groupID]
+	t.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("firewall group %s not found", groupID)
+	}
+	limit := t.limit(groupID)
+	ipv6s, evicted := capMembers(ipv6s, limit)
+	if len(evicted) > 0 {
+		t.mu.Lock()
+		if t.evicted == nil {
+			t.evicted = map[string][]string{}
+		}
+		t.evicted[groupID] = append(t.evicted[groupID], evicted...)
+		t.mu.Unlock()
+		if t.Evicted != nil {
+			t.Evicted(group, limit, evicted)
+		}
+	}
+	// a group holding the same addresses written another way, twice or
+	// in another order is rewritten in canonical form
+	ipv6s = unifi.CanonicalMembers(ipv6s)
+	if slices.Equal(group.Members, ipv6s) {
+		return false, nil
+	}
+	if err := t.Controller.UpdateFirewallGroup(group, ipv6s...); err != nil {
+		return false, err
+	}
+	group.Members = ipv6s
+	t.mu.Lock()
+	t.groups[groupID] = group
+	t.mu.Unlock()
+	return true, nil
+}
+
+// limit returns the most members the group may have, 0 for no cap.
+func (t *FirewallGroupTarget) limit(groupID string) int {
+	if n, ok := t.Limits[groupID]; ok {
+		return n
+	}
+	return t.MaxMembers
+}
+
+// capMembers returns members cut to the first limit of them in the order
+// given, once deduplicated, along with those cut. The members' age isn't
+// known here: callers rank them, e.g. the addresses being published first.
+func capMembers(members []string, limit int) (kept, evicted []string) {
+	if limit <= 0 || len(members) <= limit {
+		return members, nil
+	}
+	for _, m := range members {
+		switch {
+		case containsIP(kept, m) || containsIP(evicted, m):
+		case len(kept) < limit:
+			kept = append(kept, m)
+		default:
+			evicted = append(evicted, m)
+		}
+	}
+	return kept, evicted
+}
+
+// wasEvicted reports whether addr was evicted from the group over its cap.
+func (t *FirewallGroupTarget) wasEvicted(groupID, addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return containsIP(t.evicted[groupID], addr)
+}
+
+// Replace swaps the addresses old for new in a group, keeping its other
+// members, and clears the group if nothing is left. new is ranked first,
+// then the other members in their order in the group, so over the group's
+// cap those are evicted before new.
+func (t *FirewallGroupTarget) Replace(groupID string, old, new []string) (bool, error) {
+	defer t.lock(groupID)()
+	group, ok := t.group(groupID)
+	if !ok {
+		return false, fmt.Errorf("firewall group %s not found", groupID)
+	}
+	members := slices.Clone(new)
+	for _, m := range group.Members {
+		if !containsIP(old, m) && !containsIP(new, m) {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return t.clear(groupID)
+	}
+	return t.updateAll(groupID, members)
+}
+
+func (t *FirewallGroupTarget) Clear(groupID string) (bool, error) {
+	defer t.lock(groupID)()
+	return t.clear(groupID)
+}
+
+func (t *FirewallGroupTarget) clear(groupID string) (bool, error) {
+	t.mu.Lock()
+	group, ok := t.groups[groupID]
+	t.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("firewall group %s not found", groupID)
+	}
+	if len(group.Members) == 0 {
+		return false, nil
+	}
+	if err := t.Controller.ClearFirewallGroup(group); err != nil {
+		return false, err
+	}
+	group.Members = nil
+	t.mu.Lock()
+	t.groups[groupID] = group
+	t.mu.Unlock()
+	return true, nil
+}
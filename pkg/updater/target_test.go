@@ -0,0 +1,75 @@
+package updater
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+func TestCapMembers(t *testing.T) {
+	tests := []struct {
+		name          string
+		members       []string
+		limit         int
+		kept, evicted []string
+	}{
+		{name: "no cap", members: []string{"::1", "::2", "::3"}, kept: []string{"::1", "::2", "::3"}},
+		{name: "under cap", members: []string{"::1", "::2"}, limit: 2, kept: []string{"::1", "::2"}},
+		{name: "last evicted", members: []string{"::1", "::2", "::3"}, limit: 2, kept: []string{"::1", "::2"}, evicted: []string{"::3"}},
+		{name: "duplicates dropped", members: []string{"::1", "::0:1", "::2", "::3", "::3"}, limit: 2, kept: []string{"::1", "::2"}, evicted: []string{"::3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, evicted := capMembers(tt.members, tt.limit)
+			if !slices.Equal(kept, tt.kept) || !slices.Equal(evicted, tt.evicted) {
+				t.Errorf("capMembers() = %v, %v, want %v, %v", kept, evicted, tt.kept, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestFirewallGroupTargetEvicts(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Type: unifi.GroupTypeIPv6})
+	var evicted []string
+	ft := &FirewallGroupTarget{Controller: ctrl, MaxMembers: 3, Limits: map[string]int{"g1": 2},
+		Evicted: func(_ unifi.FirewallGroup, _ int, e []string) { evicted = e }}
+	if err := ft.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.UpdateAll("g1", []string{"2001:db8::3", "2001:db8::1", "2001:db8::2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ctrl.members("g1"), []string{"2001:db8::1", "2001:db8::3"}; !slices.Equal(got, want) {
+		t.Errorf("group members = %v, want %v", got, want)
+	}
+	if !slices.Equal(evicted, []string{"2001:db8::2"}) || !ft.wasEvicted("g1", "2001:db8::2") {
+		t.Errorf("evicted %v, want [2001:db8::2]", evicted)
+	}
+}
+
+func TestFirewallGroupTargetReplaceConcurrently(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Type: unifi.GroupTypeIPv6, Members: []string{"::1", "::2", "::99"}})
+	ctrl.delay = 10 * time.Millisecond
+	ft := &FirewallGroupTarget{Controller: ctrl}
+	if err := ft.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range [][2]string{{"::1", "::a"}, {"::2", "::b"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ft.Replace("g1", []string{r[0]}, []string{r[1]}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := ctrl.members("g1"), []string{"::a", "::b", "::99"}; !slices.Equal(got, want) {
+		t.Errorf("group members = %v, want %v", got, want)
+	}
+}
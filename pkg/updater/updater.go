@@ -0,0 +1,794 @@
+// Package updater is the reconciliation engine: it looks up each tracked
+// client's current global IPv6 address and, when it has changed, publishes
+// it to the client's target, by default a UniFi firewall group.
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/history"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/notify"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/target"
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// Controller is the part of the controller API the engine uses.
+// *unifi.Client implements it.
+type Controller interface {
+	Stations() ([]unifi.Station, error)
+	KnownStations() ([]unifi.Station, error)
+	FirewallGroups() ([]unifi.FirewallGroup, error)
+	UpdateFirewallGroup(group unifi.FirewallGroup, members ...string) error
+	ClearFirewallGroup(group unifi.FirewallGroup) error
+}
+
+// Updater runs reconciliation cycles.
+type Updater struct {
+	Controller Controller
+	Store      Store
+
+	// Sources are consulted in order for each client's addresses; the
+	// first one that knows a global address for the client wins. If empty,
+	// the controller's connected clients are used, followed by its known
+	// clients when IncludeOffline is set.
+	Sources []Source
+
+	// Targets are where addresses are published, by the name clients select
+	// them with. The UniFi firewall group target is always available as
+	// DefaultTarget unless replaced here.
+	Targets map[string]Target
+
+	// Concurrency is how many clients are reconciled at once (at least 1).
+	Concurrency int
+	// IncludeOffline looks up clients missing from the connected list among
+	// the controller's known clients and uses their last addresses. It only
+	// applies when Sources is empty.
+	IncludeOffline bool
+	// Paused, if set, reports clients to skip this cycle.
+	Paused func(mac string) bool
+	// ReportError, if set, is called with every error worth alerting on.
+	ReportError func(err error, mac, groupID string)
+	// Panicked, if set, is called with the value of a panic reconciling a
+	// client, from the goroutine that panicked. The panic is recovered and
+	// counts as the client's error.
+	Panicked func(v any, mac, groupID string)
+	// Log receives progress messages. Nil logs to stdout.
+	Log *log.Logger
+	// Connect returns the controller of clients on another site than
+	// Controller's: site of the config's controller cc, or of Controller
+	// when cc is nil; an empty site is the controller's default one.
+	// Without it, such clients fail.
+	Connect func(cc *ControllerConfig, site string) (Controller, error)
+	// Selection chooses which of a client's addresses is published, unless
+	// the client has its own preference.
+	Selection Selection
+	// History, if set, records every change made to a client's entries,
+	// and every attempt that failed.
+	History *history.File
+	// DriftPolicy is what is done about firewall groups changed outside
+	// the updater, unless their clients have their own: DriftAlert (the
+	// default), DriftRepair or DriftRespect.
+	DriftPolicy string
+	// Verify checks a client's new addresses before they are published,
+	// unless the client has its own check: VerifyPing, VerifyTCP with a
+	// port, or empty or VerifyOff for none. Probe, if set, runs the checks
+	// in place of the package's Probe.
+	Verify string
+	Probe  func(verify, addr string) error
+	// ResolveHostnames looks up the names new addresses resolve back to,
+	// for logs, notifications and the status.
+	ResolveHostnames bool
+	// MaxGroupMembers caps the members of firewall groups without a limit
+	// of their own in the config's GroupLimits, evicting the members
+	// ranked last over it; 0 means no cap.
+	MaxGroupMembers int
+	// Overlap is how long an address a client's new one replaced stays
+	// published alongside it, unless the client has its own, so that
+	// established sessions using it aren't cut off. Zero removes it as
+	// soon as the new one is published.
+	Overlap time.Duration
+
+	// Interval is how often RunDue checks clients without an interval of
+	// their own; an hour if unset.
+	Interval time.Duration
+
+	scheduleMu sync.Mutex
+	next       map[string]time.Time // when each client is next due
+
+	driftMu sync.Mutex
+	alerted map[string]string // the drift last alerted on, by group
+}
+
+// ConfigError is returned by Run when the config can't be loaded.
+type ConfigError struct{ Err error }
+
+func (e *ConfigError) Error() string { return "load config: " + e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// PartialError is returned by Run when some clients failed to update; Err
+// joins the individual failures.
+type PartialError struct{ Err error }
+
+func (e *PartialError) Error() string { return e.Err.Error() }
+func (e *PartialError) Unwrap() error { return e.Err }
+
+func (u *Updater) logger() *log.Logger {
+	if u.Log != nil {
+		return u.Log
+	}
+	return log.New(os.Stdout, "", 0)
+}
+
+func (u *Updater) sources() []Source {
+	if len(u.Sources) > 0 {
+		return u.Sources
+	}
+	return u.DefaultSources()
+}
+
+// DefaultSources returns the sources used when Sources is empty: the
+// controller's connected clients, then its known clients when
+// IncludeOffline is set.
+func (u *Updater) DefaultSources() []Source {
+	sources := []Source{StationSource{u.Controller}}
+	if u.IncludeOffline {
+		sources = append(sources, KnownStationSource{u.Controller})
+	}
+	return sources
+}
+
+// targets returns the built-in target, those configured in cfg and
+// u.Targets, later ones replacing earlier ones of the same name.
+func (u *Updater) targets(cfg *Config) (map[string]Target, error) {
+	targets := map[string]Target{DefaultTarget: u.firewallGroups(u.Controller, cfg)}
+	for _, tc := range cfg.Targets {
+		t, err := target.New(tc)
+		if err != nil {
+			return nil, err
+		}
+		targets[tc.Name] = t
+	}
+	for name, t := range u.Targets {
+		targets[name] = t
+	}
+	return targets, nil
+}
+
+// firewallGroups returns the firewall group target of ctrl, with the caps
+// of cfg and MaxGroupMembers, warning about members evicted over them.
+func (u *Updater) firewallGroups(ctrl Controller, cfg *Config) *FirewallGroupTarget {
+	evicted := func(g unifi.FirewallGroup, limit int, evicted []string) {
+		msg := fmt.Sprintf("Firewall group %s (%s) is at its cap of %d members, evicting %s", g.Name, g.ID, limit, strings.Join(evicted, ", "))
+		u.logger().Println("⚠️ ", msg)
+		notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindEviction, Severity: "warning", GroupID: g.ID, Message: "⚠️ " + msg})
+	}
+	return &FirewallGroupTarget{Controller: ctrl, MaxMembers: u.MaxGroupMembers, Limits: cfg.GroupLimits, Evicted: evicted}
+}
+
+func (u *Updater) reportError(err error, mac, groupID string) {
+	if u.ReportError != nil {
+		u.ReportError(err, mac, groupID)
+	}
+}
+
+// record adds a change to the client's entries to History, the error err
+// making it a failed one.
+func (u *Updater) record(action string, c ClientConfig, old, new []string, err error) {
+	if u.History == nil {
+		return
+	}
+	e := history.Entry{Action: action, MAC: c.MAC, Name: c.Name, Target: c.TargetName(), Ref: c.GroupID, Old: old, New: new}
+	if err != nil {
+		e.Result, e.Error = history.ResultFailed, err.Error()
+	}
+	if err := u.History.Record(e); err != nil {
+		u.logger().Println("⚠️  Failed to record history:", err)
+	}
+}
+
+// Run performs a single reconciliation cycle and returns the per-client
+// outcome and summary of it, along with the combined error of every failure
+// encountered.
+func (u *Updater) Run() (Status, error) { return u.run(false) }
+
+// RunDue is like Run but only checks the clients that are due, going by
+// their own interval or Interval, along with those coming due shortly.
+// When none are, the controller isn't queried at all. Clients not checked
+// have the result ResultNotDue.
+func (u *Updater) RunDue() (Status, error) { return u.run(true) }
+
+func (u *Updater) run(dueOnly bool) (st Status, _ error) {
+	logger := u.logger()
+	defer func() {
+		if h, ok := u.Controller.(interface{ Host() string }); ok {
+			st.Endpoint = h.Host()
+		}
+	}()
+
+	cfg, err := u.Store.Load()
+	if err != nil {
+		logger.Println("❌ Failed to load config:", err)
+		u.reportError(err, "", "")
+		st.Summary.Errors++
+		return st, &ConfigError{err}
+	}
+
+	due := u.due(cfg.Clients, time.Now(), dueOnly)
+	if !slices.Contains(due, true) {
+		for _, c := range cfg.Clients {
+			st.Clients = append(st.Clients, ClientStatus{MAC: c.MAC, Name: c.Name, GroupID: c.GroupID, IPv6: c.LastIPv6, Result: ResultNotDue})
+		}
+		return st, nil
+	}
+
+	// The first source is read up front and a failure aborts the cycle; the
+	// others are fallbacks, read only once a client is missing from all
+	// before them.
+	track(u.Controller, cfg)
+	sources := u.sources()
+	snapshots := make([]map[string][]string, len(sources))
+	snapshots[0], err = sources[0].Addresses()
+	if err != nil {
+		logger.Printf("❌ Failed to get %s: %v\n", sources[0].Name(), err)
+		u.reportError(err, "", "")
+		notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error",
+			Message: fmt.Sprintf("❌ Failed to get %s: %v", sources[0].Name(), err)})
+		st.Summary.Errors++
+		return st, fmt.Errorf("get %s: %w", sources[0].Name(), err)
+	}
+
+	// Targets in use read their current state once, and clients reconcile
+	// against that
+	targets, err := u.targets(cfg)
+	if err != nil {
+		logger.Println("❌ Invalid target:", err)
+		u.reportError(err, "", "")
+		st.Summary.Errors++
+		return st, &ConfigError{err}
+	}
+	for name, t := range targets {
+		r, ok := t.(Refresher)
+		inUse := false
+		for i, c := range cfg.Clients {
+			if due[i] && c.IsEnabled() && slices.ContainsFunc(c.Destinations(), func(d Destination) bool {
+				return d.Target == name && (name != DefaultTarget || c.At(d).siteKey() == "")
+			}) {
+				inUse = true
+				break
+			}
+		}
+		if !ok || !inUse {
+			continue
+		}
+		if err := r.Refresh(); err != nil {
+			logger.Printf("❌ Failed to read %s target: %v\n", name, err)
+			u.reportError(err, "", "")
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error",
+				Message: fmt.Sprintf("❌ Failed to read %s target: %v", name, err)})
+			st.Summary.Errors++
+			return st, fmt.Errorf("read %s target: %w", name, err)
+		}
+	}
+
+	// Clients on other sites are looked up there; a site that can't be
+	// read only fails its own clients.
+	sites := u.sites(cfg, due)
+	var siteErrs []error
+	for _, s := range sites {
+		if s.err != nil {
+			logger.Printf("❌ Failed to read %s: %v\n", s.name, s.err)
+			u.reportError(s.err, "", "")
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error",
+				Message: fmt.Sprintf("❌ Failed to read %s: %v", s.name, s.err)})
+			siteErrs = append(siteErrs, fmt.Errorf("read %s: %w", s.name, s.err))
+		}
+	}
+
+	// Groups changed outside the updater are reported, and repaired if
+	// their policy says so, before anything is published to them
+	drifts := u.drift(cfg, due, targets, sites)
+	var driftErrs []error
+	st.Summary.Drifted, driftErrs = u.handleDrift(cfg, drifts)
+	siteErrs = append(siteErrs, driftErrs...)
+
+	// Clients are all looked up before any is published, so that prefix
+	// moves revealed by some clients can be applied to the others.
+	type lookup struct {
+		addrs    []string
+		found    bool
+		paused   bool
+		disabled bool
+		notDue   bool
+		siteErr  error
+	}
+	lookups := make([]lookup, len(cfg.Clients))
+	snapshots0 := snapshots[0]
+	seen := make([]string, len(cfg.Clients))
+	for i, c := range cfg.Clients {
+		l := &lookups[i]
+		if l.notDue = !due[i]; l.notDue {
+			continue
+		}
+		if l.disabled = !c.IsEnabled(); l.disabled {
+			continue
+		}
+		if l.paused = u.Paused != nil && u.Paused(c.MAC); l.paused {
+			continue
+		}
+
+		// Find client by MAC, falling back through the sources while
+		// it's missing or known without a usable address
+		sel := u.selection(c)
+		usable := func(addrs []string) bool {
+			_, err := sel.Pick(addrs, c.interfaceID())
+			return err == nil
+		}
+		sources, snapshots := sources, snapshots
+		if s := sites[c.siteKey()]; s != nil {
+			if l.siteErr = s.err; l.siteErr != nil {
+				continue
+			}
+			sources, snapshots = s.sources, s.snapshots
+		}
+		l.addrs, l.found = snapshots[0][strings.ToLower(c.MAC)]
+		for j := 1; j < len(sources) && !usable(l.addrs); j++ {
+			if snapshots[j] == nil {
+				if snapshots[j], err = sources[j].Addresses(); err != nil {
+					logger.Printf("⚠️  Failed to get %s: %v\n", sources[j].Name(), err)
+					snapshots[j] = map[string][]string{}
+				}
+			}
+			more, ok := snapshots[j][strings.ToLower(c.MAC)]
+			switch {
+			case ok && !l.found:
+				logger.Printf("💤 Client %s not in %s, using %s\n", c.Label(), sources[0].Name(), sources[j].Name())
+			case ok && usable(more):
+				logger.Printf("🔍 No global IPv6 for %s in %s, using %s\n", c.Label(), sources[0].Name(), sources[j].Name())
+			default:
+				continue
+			}
+			l.addrs, l.found = more, true
+		}
+		// A client roaming between the sites of its destinations is looked
+		// up on the others while missing from its own
+		for _, d := range c.Destinations() {
+			at := c.At(d)
+			if usable(l.addrs) || at.siteKey() == c.siteKey() {
+				continue
+			}
+			snapshot := snapshots0
+			if s := sites[at.siteKey()]; s != nil {
+				if s.err != nil {
+					continue
+				}
+				snapshot = s.snapshots[0]
+			}
+			if more, ok := snapshot[strings.ToLower(c.MAC)]; ok && usable(more) {
+				logger.Printf("🧳 %s not on %s, found on %s\n", c.Label(), c.siteName(), at.siteName())
+				l.addrs, l.found = more, true
+			}
+		}
+		seen[i], _ = sel.Pick(l.addrs, c.interfaceID())
+	}
+	cfg.RenumberedPrefixes = recordMoves(cfg.RenumberedPrefixes, prefixMoves(cfg.Clients, seen, cfg.RenumberedPrefixes))
+
+	// mu serializes everything the workers share: the summary, errors and
+	// the config (and its store).
+	var (
+		mu   sync.Mutex
+		errs = siteErrs
+	)
+	defer func() { st.Summary.Errors = len(errs) }()
+
+	// target returns the target of one of the client's destinations.
+	target := func(c ClientConfig, d Destination) (Target, error) {
+		if s := sites[c.At(d).siteKey()]; s != nil && d.Target == DefaultTarget {
+			if s.err != nil {
+				return nil, s.err
+			}
+			return s.groups, nil
+		}
+		t, ok := targets[d.Target]
+		if !ok {
+			return nil, fmt.Errorf("unknown target %q", d.Target)
+		}
+		return t, nil
+	}
+
+	// replace returns the firewall groups of a destination whose group
+	// respects drift, so that only the client's own addresses are changed
+	// in it.
+	replace := func(c ClientConfig, d Destination, t Target) (*FirewallGroupTarget, bool) {
+		ft, ok := t.(*FirewallGroupTarget)
+		return ft, ok && d.Target == DefaultTarget && drifts[c.At(d).groupKey(d.Ref)].Policy == DriftRespect
+	}
+
+	// update publishes to each of the client's destinations in turn,
+	// stopping at the first failure; the addresses aren't saved then, so
+	// the next cycle retries them all. Retiring addresses are kept
+	// alongside ipv6s on targets holding several, newest first.
+	update := func(c ClientConfig, ipv6s []string, retiring []RetiringAddress) (bool, error) {
+		all := slices.Clone(ipv6s)
+		for _, r := range slices.Backward(retiring) {
+			all = append(all, r.Address)
+		}
+		var changed bool
+		for i, d := range c.Destinations() {
+			t, err := target(c, d)
+			if err != nil {
+				return changed, err
+			}
+			var put bool
+			if ft, ok := replace(c, d, t); ok {
+				put, err = ft.Replace(d.Ref, c.Written(), all)
+			} else if mt, ok := t.(MultiTarget); ok {
+				put, err = mt.UpdateAll(d.Ref, all)
+			} else {
+				put, err = t.Update(d.Ref, ipv6s[0])
+			}
+			if err != nil {
+				if i > 0 {
+					err = fmt.Errorf("%s %s: %w", d.Target, d.Ref, err)
+				}
+				return changed, err
+			}
+			changed = changed || put
+		}
+		return changed, nil
+	}
+
+	// evicted reports whether the address was evicted from one of the
+	// client's firewall groups over its cap.
+	evicted := func(c ClientConfig, addr string) bool {
+		return slices.ContainsFunc(c.Destinations(), func(d Destination) bool {
+			t, err := target(c, d)
+			ft, ok := t.(*FirewallGroupTarget)
+			return err == nil && ok && ft.wasEvicted(d.Ref, addr)
+		})
+	}
+
+	// groupName returns the name of the client's main firewall group, or
+	// empty if it publishes elsewhere.
+	groupName := func(c ClientConfig) string {
+		t, err := target(c, c.Destinations()[0])
+		if ft, ok := t.(*FirewallGroupTarget); ok && err == nil {
+			g, _ := ft.group(c.GroupID)
+			return g.Name
+		}
+		return ""
+	}
+
+	count := func(n *int) {
+		mu.Lock()
+		*n++
+		mu.Unlock()
+	}
+	fail := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	// clearEntries empties the entries of a client with AllowEmpty that has
+	// no usable address.
+	clearEntries := func(i int, c ClientConfig, cs *ClientStatus) {
+		if !c.AllowEmpty || len(c.Written()) == 0 {
+			return
+		}
+		var changed bool
+		for _, d := range c.Destinations() {
+			t, err := target(c, d)
+			if ft, ok := replace(c, d, t); ok {
+				var put bool
+				put, err = ft.Replace(d.Ref, c.Written(), nil)
+				changed = changed || put
+			} else if err == nil {
+				cl, ok := t.(Clearer)
+				if !ok {
+					logger.Printf("⚠️  %s target can't be left empty, keeping %s for %s\n", d.Target, d.Ref, c.Label())
+					continue
+				}
+				var put bool
+				put, err = cl.Clear(d.Ref)
+				changed = changed || put
+			}
+			if err != nil {
+				logger.Printf("❌ Failed to clear %s %s: %v\n", d.Target, d.Ref, err)
+				u.reportError(err, c.MAC, c.GroupID)
+				notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+					Message: fmt.Sprintf("❌ Failed to clear %s %s for %s: %v", d.Target, d.Ref, c.Label(), err)})
+				fail(fmt.Errorf("clear %s %s for %s: %w", d.Target, d.Ref, c.Label(), err))
+				u.record(history.ActionClear, c, c.Written(), nil, err)
+				cs.Error = err.Error()
+				return
+			}
+		}
+		if changed {
+			count(&st.Summary.Updated)
+		}
+		old := strings.Join(c.Published(), ", ")
+		logger.Printf("🧹 Cleared the entries of %s (was %s)\n", c.Label(), old)
+		u.record(history.ActionClear, c, c.Written(), nil, nil)
+		cs.IPv6, cs.Addresses, cs.PreviousIPv6, cs.LastChanged = "", nil, c.LastIPv6, time.Now()
+
+		mu.Lock()
+		cfg.Clients[i].LastIPv6, cfg.Clients[i].Addresses, cfg.Clients[i].Retiring = "", nil, nil
+		err := u.Store.Save(cfg)
+		mu.Unlock()
+		if err != nil {
+			logger.Println("❌ Failed to save config:", err)
+			u.reportError(err, c.MAC, c.GroupID)
+			fail(fmt.Errorf("save config: %w", err))
+			cs.Error = err.Error()
+		}
+		notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindChange, Severity: "info", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+			GroupName: groupName(c), OldIPv6: old, Removed: c.Written(), Message: fmt.Sprintf("🧹 Cleared the entries of %s, which has no usable address", c.Label())})
+	}
+
+	// prune stops publishing the client's replaced addresses whose overlap
+	// has ended, leaving retiring those that are left.
+	prune := func(i int, c ClientConfig, retiring []RetiringAddress, cs *ClientStatus) {
+		var ended []string
+		for _, r := range c.Retiring {
+			if !slices.Contains(retiring, r) {
+				ended = append(ended, r.Address)
+			}
+		}
+		left := append(slices.Clip(c.Published()), retiringAddresses(retiring)...)
+		logger.Printf("✂️  Overlap ended for %s, removing %s\n", c.Label(), strings.Join(ended, ", "))
+		put, err := update(c, c.Published(), retiring)
+		u.record(history.ActionPrune, c, c.Written(), left, err)
+		if err != nil {
+			logger.Printf("❌ Failed to update %s target: %v\n", c.TargetName(), err)
+			u.reportError(err, c.MAC, c.GroupID)
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+				Message: fmt.Sprintf("❌ Failed to remove %s from %s %s for %s: %v", strings.Join(ended, ", "), c.TargetName(), c.GroupID, c.Label(), err)})
+			fail(fmt.Errorf("prune group %s for %s: %w", c.GroupID, c.Label(), err))
+			cs.Result = ResultFailed
+			cs.Error = err.Error()
+			return
+		}
+		if put {
+			count(&st.Summary.Updated)
+		}
+
+		mu.Lock()
+		cfg.Clients[i].Retiring = retiring
+		err = u.Store.Save(cfg)
+		mu.Unlock()
+		if err != nil {
+			logger.Println("❌ Failed to save config:", err)
+			u.reportError(err, c.MAC, c.GroupID)
+			fail(fmt.Errorf("save config: %w", err))
+			cs.Error = err.Error()
+		}
+	}
+
+	reconcileClient := func(i int, c ClientConfig) ClientStatus {
+		cs := ClientStatus{MAC: c.MAC, Name: c.Name, GroupID: c.GroupID, IPv6: c.LastIPv6, Addresses: c.Addresses}
+		l := lookups[i]
+		if l.notDue {
+			cs.Result = ResultNotDue
+			return cs
+		}
+		if l.disabled {
+			logger.Println("⏭️  Skipping disabled client:", c.Label())
+			cs.Result = ResultDisabled
+			return cs
+		}
+		count(&st.Summary.Checked)
+
+		if l.siteErr != nil {
+			cs.Result = ResultFailed
+			cs.Error = l.siteErr.Error()
+			return cs
+		}
+		if l.paused {
+			count(&st.Summary.Paused)
+			logger.Println("⏸️  Skipping paused client:", c.Label())
+			cs.Result = ResultPaused
+			return cs
+		}
+
+		ipv6s, err := u.selection(c).Select(l.addrs, c.interfaceID(), c.Published())
+
+		// New addresses must answer before they are published, so stale
+		// or phantom ones the controller still lists are passed over
+		if verify := u.verify(c); err == nil && verify != "" && !slices.Equal(ipv6s, c.Published()) {
+			kept, dropped := u.reachable(c, l.addrs)
+			if len(dropped) > 0 {
+				logger.Printf("📡 %s didn't answer %s at %s, not publishing it\n", c.Label(), verify, strings.Join(dropped, ", "))
+				if ipv6s, err = u.selection(c).Select(kept, c.interfaceID(), c.Published()); err != nil {
+					err = fmt.Errorf("no new address answered %s", verify)
+				}
+			}
+		}
+
+		// Publish the client's interface ID in its renumbered prefix,
+		// ahead of the client being seen there
+		if c.TrackIID {
+			base := c.LastIPv6
+			if err == nil {
+				base = ipv6s[0]
+			}
+			prefix, _ := prefix64(base)
+			if to, ok := movedTo(cfg.RenumberedPrefixes, prefix); ok {
+				if renumbered, ok := withPrefix(to, c.interfaceID()); ok {
+					logger.Printf("🧩 Prefix of %s moved to %s/64, using %s\n", c.Label(), to, renumbered)
+					ipv6s, err = []string{renumbered}, nil
+					l.found = true
+				}
+			}
+		}
+
+		if !l.found {
+			count(&st.Summary.Missing)
+			logger.Println("⚠️  Client not found:", c.Label())
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindNotFound, Severity: "warning", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+				Message: fmt.Sprintf("⚠️ Client not found: %s", c.Label())})
+			cs.Result = ResultNotFound
+			clearEntries(i, c, &cs)
+			return cs
+		}
+
+		count(&st.Summary.Found)
+
+		// Pick global IPv6
+		if err != nil {
+			count(&st.Summary.NoIPv6)
+			logger.Printf("⚠️  No global IPv6 for %s (%v)\n", c.Label(), err)
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindNotFound, Severity: "warning", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+				Message: fmt.Sprintf("⚠️ No global IPv6 for %s", c.Label())})
+			cs.Result = ResultNoIPv6
+			clearEntries(i, c, &cs)
+			return cs
+		}
+
+		ipv6 := strings.Join(ipv6s, ", ")
+		old := strings.Join(c.Published(), ", ")
+		retiring := u.retiring(c, ipv6s, time.Now())
+		if slices.Equal(ipv6s, c.Published()) {
+			logger.Printf("✅ IPv6 unchanged for %s (%s)\n", c.Label(), ipv6)
+			cs.Result = ResultUnchanged
+			if len(retiring) < len(c.Retiring) {
+				prune(i, c, retiring, &cs)
+			}
+			return cs
+		}
+
+		count(&st.Summary.Changed)
+		if u.ResolveHostnames {
+			cs.Hostnames = u.hostnames(ipv6s)
+			ipv6 = Annotate(ipv6s, cs.Hostnames)
+		}
+		logger.Printf("🔄 IPv6 changed for %s: %s → %s\n", c.Label(), old, ipv6)
+		for _, r := range retiring {
+			if !slices.Contains(c.Retiring, r) {
+				logger.Printf("⏳ Keeping %s published for %s until %s\n", r.Address, c.Label(), r.Until.Format(time.DateTime))
+			}
+		}
+		put, err := update(c, ipv6s, retiring)
+		if err != nil {
+			logger.Printf("❌ Failed to update %s target: %v\n", c.TargetName(), err)
+			u.reportError(err, c.MAC, c.GroupID)
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+				GroupName: groupName(c), OldIPv6: old, NewIPv6: strings.Join(ipv6s, ", "),
+				Message: fmt.Sprintf("❌ Failed to update %s %s for %s: %v", c.TargetName(), c.GroupID, c.Label(), err)})
+			fail(fmt.Errorf("update group %s for %s: %w", c.GroupID, c.Label(), err))
+			u.record(history.ActionChange, c, c.Published(), ipv6s, err)
+			cs.Result = ResultFailed
+			cs.Error = err.Error()
+			return cs
+		}
+		u.record(history.ActionChange, c, c.Published(), ipv6s, nil)
+		if put {
+			count(&st.Summary.Updated)
+		} else {
+			logger.Printf("✅ %s %s already has %s\n", c.TargetName(), c.GroupID, ipv6)
+		}
+		cs.IPv6, cs.Addresses = ipv6s[0], nil
+		if len(ipv6s) > 1 {
+			cs.Addresses = ipv6s
+		}
+		cs.PreviousIPv6 = c.LastIPv6
+		cs.LastChanged = time.Now()
+		cs.Result = ResultUpdated
+
+		// retiring addresses evicted over a group's cap are gone already
+		retiring = slices.DeleteFunc(retiring, func(r RetiringAddress) bool { return evicted(c, r.Address) })
+		added, removed := diff(c.Written(), append(slices.Clone(ipv6s), retiringAddresses(retiring)...))
+
+		mu.Lock()
+		cfg.Clients[i].LastIPv6, cfg.Clients[i].Addresses = cs.IPv6, cs.Addresses
+		cfg.Clients[i].Retiring = retiring
+		if c.TrackIID {
+			cfg.Clients[i].IID = interfaceID(cs.IPv6)
+		}
+		err = u.Store.Save(cfg)
+		mu.Unlock()
+		if err != nil {
+			logger.Println("❌ Failed to save config:", err)
+			u.reportError(err, c.MAC, c.GroupID)
+			notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindFailure, Severity: "error", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+				Message: fmt.Sprintf("❌ Failed to save config: %v", err)})
+			fail(fmt.Errorf("save config: %w", err))
+			cs.Error = err.Error()
+		} else if put {
+			logger.Printf("✅ Updated %s %s and saved new address.\n", c.TargetName(), c.GroupID)
+		} else {
+			logger.Println("✅ Saved new address.")
+		}
+		notify.Send(cfg.Notifiers, notify.Event{Kind: notify.KindChange, Severity: "info", MAC: c.MAC, Name: c.Name, GroupID: c.GroupID,
+			GroupName: groupName(c), OldIPv6: old, NewIPv6: strings.Join(ipv6s, ", "), Hostnames: cs.Hostnames, Added: added, Removed: removed,
+			Message: fmt.Sprintf("🔄 IPv6 changed for %s: %s → %s", c.Label(), old, ipv6)})
+		return cs
+	}
+
+	results := make([]ClientStatus, len(cfg.Clients))
+	var g errgroup.Group
+	g.SetLimit(max(u.Concurrency, 1))
+
+	for i, c := range cfg.Clients {
+		g.Go(func() error {
+			// a panic in one client's worker would otherwise take the
+			// process down past the caller's recover
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				logger.Printf("❌ Panic reconciling %s: %v\n%s", c.Label(), r, debug.Stack())
+				if u.Panicked != nil {
+					u.Panicked(r, c.MAC, c.GroupID)
+				}
+				err := fmt.Errorf("panic: %v", r)
+				fail(fmt.Errorf("%s: %w", c.Label(), err))
+				results[i] = ClientStatus{MAC: c.MAC, Name: c.Name, GroupID: c.GroupID, IPv6: c.LastIPv6, Result: ResultFailed, Error: err.Error()}
+			}()
+			results[i] = reconcileClient(i, c)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	st.Clients = results
+
+	// Clients are checked again in time to remove their replaced addresses
+	// once the overlap ends
+	for _, c := range cfg.Clients {
+		for _, r := range c.Retiring {
+			if r.Until.After(time.Now()) {
+				u.wakeAt(c, r.Until)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return st, &PartialError{errors.Join(errs...)}
+	}
+	return st, nil
+}
+
+// GlobalIPv6 returns the first global (non link-local) IPv6 address.
+func GlobalIPv6(addresses []string) (string, error) {
+	for _, ip := range addresses {
+		ip = strings.TrimSpace(ip)
+		if strings.HasPrefix(ip, "fe80") || strings.HasPrefix(ip, "FE80") {
+			continue
+		}
+		if net.ParseIP(ip) != nil && strings.Contains(ip, ":") {
+			return ip, nil
+		}
+	}
+	return "", errors.New("no valid global IPv6 found")
+}
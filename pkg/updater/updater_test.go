@@ -0,0 +1,221 @@
+package updater
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brendann993/unifi-ipv6-client-firewall-updater/pkg/unifi"
+)
+
+// fakeController keeps firewall groups in memory. Writes take delay, to
+// let concurrent ones overlap.
+type fakeController struct {
+	delay time.Duration
+
+	mu     sync.Mutex
+	groups map[string]unifi.FirewallGroup
+	writes int
+}
+
+func newFakeController(groups ...unifi.FirewallGroup) *fakeController {
+	c := &fakeController{groups: map[string]unifi.FirewallGroup{}}
+	for _, g := range groups {
+		c.groups[g.ID] = g
+	}
+	return c
+}
+
+func (c *fakeController) Stations() ([]unifi.Station, error)      { return nil, nil }
+func (c *fakeController) KnownStations() ([]unifi.Station, error) { return nil, nil }
+
+func (c *fakeController) FirewallGroups() ([]unifi.FirewallGroup, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []unifi.FirewallGroup
+	for _, g := range c.groups {
+		list = append(list, g)
+	}
+	return list, nil
+}
+
+func (c *fakeController) UpdateFirewallGroup(group unifi.FirewallGroup, members ...string) error {
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group.Members = slices.Clone(members)
+	c.groups[group.ID] = group
+	c.writes++
+	return nil
+}
+
+func (c *fakeController) ClearFirewallGroup(group unifi.FirewallGroup) error {
+	return c.UpdateFirewallGroup(group)
+}
+
+func (c *fakeController) members(id string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.groups[id].Members
+}
+
+// memStore keeps the config in memory, handing out copies as a file would.
+type memStore struct{ data []byte }
+
+func newMemStore(t *testing.T, cfg *Config) *memStore {
+	s := &memStore{}
+	if err := s.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *memStore) Load() (*Config, error) {
+	var cfg Config
+	return &cfg, json.Unmarshal(s.data, &cfg)
+}
+
+func (s *memStore) Save(cfg *Config) (err error) {
+	s.data, err = json.Marshal(cfg)
+	return err
+}
+
+// staticSource reports fixed addresses.
+type staticSource map[string][]string
+
+func (staticSource) Name() string { return "static addresses" }
+
+func (s staticSource) Addresses() (map[string][]string, error) {
+	addrs := map[string][]string{}
+	for mac, a := range s {
+		addrs[strings.ToLower(mac)] = a
+	}
+	return addrs, nil
+}
+
+func newUpdater(ctrl Controller, store Store, src Source) *Updater {
+	return &Updater{
+		Controller:  ctrl,
+		Store:       store,
+		Sources:     []Source{src},
+		Concurrency: 4,
+		Log:         log.New(io.Discard, "", 0),
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Name: "NAS", Type: unifi.GroupTypeIPv6, Members: []string{"2001:db8::1"}})
+	store := newMemStore(t, &Config{Clients: []ClientConfig{
+		{MAC: "AA:BB:CC:DD:EE:01", GroupID: "g1", LastIPv6: "2001:db8::1"},
+	}})
+	src := staticSource{"aa:bb:cc:dd:ee:01": {"fe80::1", "2001:db8::2"}}
+	u := newUpdater(ctrl, store, src)
+
+	st, err := u.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Summary.Changed != 1 || st.Summary.Updated != 1 {
+		t.Errorf("summary = %v, want 1 changed and 1 group updated", st.Summary)
+	}
+	if got := ctrl.members("g1"); !slices.Equal(got, []string{"2001:db8::2"}) {
+		t.Errorf("group members = %v, want [2001:db8::2]", got)
+	}
+	cfg, _ := store.Load()
+	if got := cfg.Clients[0].LastIPv6; got != "2001:db8::2" {
+		t.Errorf("saved address = %q, want 2001:db8::2", got)
+	}
+
+	// nothing changed, so nothing is written
+	if _, err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if ctrl.writes != 1 {
+		t.Errorf("%d group writes after an unchanged cycle, want 1", ctrl.writes)
+	}
+}
+
+func TestRunRespectsDrift(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Type: unifi.GroupTypeIPv6, Members: []string{"2001:db8::1", "2001:db8::2", "2001:db8::99"}})
+	store := newMemStore(t, &Config{Clients: []ClientConfig{
+		{MAC: "aa:bb:cc:dd:ee:01", GroupID: "g1", LastIPv6: "2001:db8::1", Drift: DriftRespect},
+		{MAC: "aa:bb:cc:dd:ee:02", GroupID: "g1", LastIPv6: "2001:db8::2", Drift: DriftRespect},
+	}})
+	src := staticSource{
+		"aa:bb:cc:dd:ee:01": {"2001:db8::a"},
+		"aa:bb:cc:dd:ee:02": {"2001:db8::b"},
+	}
+	ctrl.delay = 10 * time.Millisecond
+	u := newUpdater(ctrl, store, src)
+
+	// both clients replace their own address in the group at once, and
+	// the member added outside the updater is kept
+	if _, err := u.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2001:db8::a", "2001:db8::b", "2001:db8::99"}
+	if got := ctrl.members("g1"); !slices.Equal(got, want) {
+		t.Errorf("group members = %v, want %v", got, want)
+	}
+}
+
+func TestRunRepairsDrift(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Type: unifi.GroupTypeIPv6, Members: []string{"2001:db8::1", "2001:db8::99"}})
+	store := newMemStore(t, &Config{Clients: []ClientConfig{
+		{MAC: "aa:bb:cc:dd:ee:01", GroupID: "g1", LastIPv6: "2001:db8::1", Drift: DriftRepair},
+	}})
+	src := staticSource{"aa:bb:cc:dd:ee:01": {"2001:db8::1"}}
+	u := newUpdater(ctrl, store, src)
+
+	st, err := u.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Summary.Drifted != 1 {
+		t.Errorf("%d groups drifted, want 1", st.Summary.Drifted)
+	}
+	if got := ctrl.members("g1"); !slices.Equal(got, []string{"2001:db8::1"}) {
+		t.Errorf("group members = %v, want [2001:db8::1]", got)
+	}
+}
+
+// panicTarget panics on every update.
+type panicTarget struct{}
+
+func (panicTarget) Update(ref, ipv6 string) (bool, error) { panic("boom") }
+
+func TestRunRecoversPanics(t *testing.T) {
+	ctrl := newFakeController(unifi.FirewallGroup{ID: "g1", Type: unifi.GroupTypeIPv6, Members: []string{"2001:db8::1"}})
+	store := newMemStore(t, &Config{Clients: []ClientConfig{
+		{MAC: "aa:bb:cc:dd:ee:01", GroupID: "g1", LastIPv6: "2001:db8::1"},
+		{MAC: "aa:bb:cc:dd:ee:02", GroupID: "dns", Target: "boom", LastIPv6: "2001:db8::2"},
+	}})
+	src := staticSource{
+		"aa:bb:cc:dd:ee:01": {"2001:db8::a"},
+		"aa:bb:cc:dd:ee:02": {"2001:db8::b"},
+	}
+	u := newUpdater(ctrl, store, src)
+	u.Targets = map[string]Target{"boom": panicTarget{}}
+	var panicked []string
+	u.Panicked = func(v any, mac, groupID string) { panicked = append(panicked, mac) }
+
+	st, err := u.Run()
+	if err == nil {
+		t.Fatal("Run succeeded, want the panic as an error")
+	}
+	if !slices.Equal(panicked, []string{"aa:bb:cc:dd:ee:02"}) {
+		t.Errorf("Panicked called for %v, want the second client", panicked)
+	}
+	if got := st.Clients[1]; got.Result != ResultFailed || got.Error != "panic: boom" {
+		t.Errorf("second client = %s %q, want failed with the panic", got.Result, got.Error)
+	}
+	// the other client is still reconciled
+	if got := ctrl.members("g1"); !slices.Equal(got, []string{"2001:db8::a"}) {
+		t.Errorf("group members = %v, want [2001:db8::a]", got)
+	}
+}
@@ -0,0 +1,825 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: updater/v1/updater.proto
+
+package updaterv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusRequest) Reset() {
+	*x = GetStatusRequest{}
+	mi := &file_updater_v1_updater_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusRequest) ProtoMessage() {}
+
+func (x *GetStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetStatusRequest) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{0}
+}
+
+type GetStatusResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	DurationMs    int64                  `protobuf:"varint,2,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Summary       *Summary               `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	Clients       []*ClientStatus        `protobuf:"bytes,5,rep,name=clients,proto3" json:"clients,omitempty"`
+	Errors        []string               `protobuf:"bytes,6,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatusResponse) Reset() {
+	*x = GetStatusResponse{}
+	mi := &file_updater_v1_updater_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatusResponse) ProtoMessage() {}
+
+func (x *GetStatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatusResponse.ProtoReflect.Descriptor instead.
+func (*GetStatusResponse) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetStatusResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *GetStatusResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *GetStatusResponse) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetClients() []*ClientStatus {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+func (x *GetStatusResponse) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Checked       int32                  `protobuf:"varint,1,opt,name=checked,proto3" json:"checked,omitempty"`
+	Found         int32                  `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Missing       int32                  `protobuf:"varint,3,opt,name=missing,proto3" json:"missing,omitempty"`
+	NoIpv6        int32                  `protobuf:"varint,4,opt,name=no_ipv6,json=noIpv6,proto3" json:"no_ipv6,omitempty"`
+	Changed       int32                  `protobuf:"varint,5,opt,name=changed,proto3" json:"changed,omitempty"`
+	Updated       int32                  `protobuf:"varint,6,opt,name=updated,proto3" json:"updated,omitempty"`
+	Paused        int32                  `protobuf:"varint,7,opt,name=paused,proto3" json:"paused,omitempty"`
+	Errors        int32                  `protobuf:"varint,8,opt,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_updater_v1_updater_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Summary) GetChecked() int32 {
+	if x != nil {
+		return x.Checked
+	}
+	return 0
+}
+
+func (x *Summary) GetFound() int32 {
+	if x != nil {
+		return x.Found
+	}
+	return 0
+}
+
+func (x *Summary) GetMissing() int32 {
+	if x != nil {
+		return x.Missing
+	}
+	return 0
+}
+
+func (x *Summary) GetNoIpv6() int32 {
+	if x != nil {
+		return x.NoIpv6
+	}
+	return 0
+}
+
+func (x *Summary) GetChanged() int32 {
+	if x != nil {
+		return x.Changed
+	}
+	return 0
+}
+
+func (x *Summary) GetUpdated() int32 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *Summary) GetPaused() int32 {
+	if x != nil {
+		return x.Paused
+	}
+	return 0
+}
+
+func (x *Summary) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+type ClientStatus struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Mac          string                 `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	GroupId      string                 `protobuf:"bytes,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Ipv6         string                 `protobuf:"bytes,3,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	PreviousIpv6 string                 `protobuf:"bytes,4,opt,name=previous_ipv6,json=previousIpv6,proto3" json:"previous_ipv6,omitempty"`
+	LastChanged  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=last_changed,json=lastChanged,proto3" json:"last_changed,omitempty"`
+	// One of unchanged, updated, not_found, no_ipv6, failed or paused.
+	Result        string `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"`
+	Error         string `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"`
+	Paused        bool   `protobuf:"varint,8,opt,name=paused,proto3" json:"paused,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientStatus) Reset() {
+	*x = ClientStatus{}
+	mi := &file_updater_v1_updater_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientStatus) ProtoMessage() {}
+
+func (x *ClientStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientStatus.ProtoReflect.Descriptor instead.
+func (*ClientStatus) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClientStatus) GetMac() string {
+	if x != nil {
+		return x.Mac
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetIpv6() string {
+	if x != nil {
+		return x.Ipv6
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetPreviousIpv6() string {
+	if x != nil {
+		return x.PreviousIpv6
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetLastChanged() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastChanged
+	}
+	return nil
+}
+
+func (x *ClientStatus) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ClientStatus) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+type ListClientsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientsRequest) Reset() {
+	*x = ListClientsRequest{}
+	mi := &file_updater_v1_updater_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientsRequest) ProtoMessage() {}
+
+func (x *ListClientsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientsRequest.ProtoReflect.Descriptor instead.
+func (*ListClientsRequest) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{4}
+}
+
+type ListClientsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Clients       []*ClientEntry         `protobuf:"bytes,1,rep,name=clients,proto3" json:"clients,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListClientsResponse) Reset() {
+	*x = ListClientsResponse{}
+	mi := &file_updater_v1_updater_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListClientsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListClientsResponse) ProtoMessage() {}
+
+func (x *ListClientsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListClientsResponse.ProtoReflect.Descriptor instead.
+func (*ListClientsResponse) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListClientsResponse) GetClients() []*ClientEntry {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+type ClientEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mac           string                 `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	GroupId       string                 `protobuf:"bytes,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	LastIpv6      string                 `protobuf:"bytes,3,opt,name=last_ipv6,json=lastIpv6,proto3" json:"last_ipv6,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientEntry) Reset() {
+	*x = ClientEntry{}
+	mi := &file_updater_v1_updater_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientEntry) ProtoMessage() {}
+
+func (x *ClientEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientEntry.ProtoReflect.Descriptor instead.
+func (*ClientEntry) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ClientEntry) GetMac() string {
+	if x != nil {
+		return x.Mac
+	}
+	return ""
+}
+
+func (x *ClientEntry) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *ClientEntry) GetLastIpv6() string {
+	if x != nil {
+		return x.LastIpv6
+	}
+	return ""
+}
+
+type ReconcileRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileRequest) Reset() {
+	*x = ReconcileRequest{}
+	mi := &file_updater_v1_updater_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileRequest) ProtoMessage() {}
+
+func (x *ReconcileRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileRequest) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{7}
+}
+
+type ReconcileResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileResponse) Reset() {
+	*x = ReconcileResponse{}
+	mi := &file_updater_v1_updater_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileResponse) ProtoMessage() {}
+
+func (x *ReconcileResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileResponse.ProtoReflect.Descriptor instead.
+func (*ReconcileResponse) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{8}
+}
+
+type StreamEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_updater_v1_updater_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{9}
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of change, failure or not_found.
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// One of info, warning or error.
+	Severity      string                 `protobuf:"bytes,2,opt,name=severity,proto3" json:"severity,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Mac           string                 `protobuf:"bytes,4,opt,name=mac,proto3" json:"mac,omitempty"`
+	GroupId       string                 `protobuf:"bytes,5,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	OldIpv6       string                 `protobuf:"bytes,6,opt,name=old_ipv6,json=oldIpv6,proto3" json:"old_ipv6,omitempty"`
+	NewIpv6       string                 `protobuf:"bytes,7,opt,name=new_ipv6,json=newIpv6,proto3" json:"new_ipv6,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_updater_v1_updater_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_updater_v1_updater_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_updater_v1_updater_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Event) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Event) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *Event) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Event) GetMac() string {
+	if x != nil {
+		return x.Mac
+	}
+	return ""
+}
+
+func (x *Event) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *Event) GetOldIpv6() string {
+	if x != nil {
+		return x.OldIpv6
+	}
+	return ""
+}
+
+func (x *Event) GetNewIpv6() string {
+	if x != nil {
+		return x.NewIpv6
+	}
+	return ""
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+var File_updater_v1_updater_proto protoreflect.FileDescriptor
+
+const file_updater_v1_updater_proto_rawDesc = "" +
+	"\n" +
+	"\x18updater/v1/updater.proto\x12\n" +
+	"updater.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x12\n" +
+	"\x10GetStatusRequest\"\x83\x02\n" +
+	"\x11GetStatusResponse\x128\n" +
+	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1f\n" +
+	"\vduration_ms\x18\x02 \x01(\x03R\n" +
+	"durationMs\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12-\n" +
+	"\asummary\x18\x04 \x01(\v2\x13.updater.v1.SummaryR\asummary\x122\n" +
+	"\aclients\x18\x05 \x03(\v2\x18.updater.v1.ClientStatusR\aclients\x12\x16\n" +
+	"\x06errors\x18\x06 \x03(\tR\x06errors\"\xd0\x01\n" +
+	"\aSummary\x12\x18\n" +
+	"\achecked\x18\x01 \x01(\x05R\achecked\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\x05R\x05found\x12\x18\n" +
+	"\amissing\x18\x03 \x01(\x05R\amissing\x12\x17\n" +
+	"\ano_ipv6\x18\x04 \x01(\x05R\x06noIpv6\x12\x18\n" +
+	"\achanged\x18\x05 \x01(\x05R\achanged\x12\x18\n" +
+	"\aupdated\x18\x06 \x01(\x05R\aupdated\x12\x16\n" +
+	"\x06paused\x18\a \x01(\x05R\x06paused\x12\x16\n" +
+	"\x06errors\x18\b \x01(\x05R\x06errors\"\xf9\x01\n" +
+	"\fClientStatus\x12\x10\n" +
+	"\x03mac\x18\x01 \x01(\tR\x03mac\x12\x19\n" +
+	"\bgroup_id\x18\x02 \x01(\tR\agroupId\x12\x12\n" +
+	"\x04ipv6\x18\x03 \x01(\tR\x04ipv6\x12#\n" +
+	"\rprevious_ipv6\x18\x04 \x01(\tR\fpreviousIpv6\x12=\n" +
+	"\flast_changed\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vlastChanged\x12\x16\n" +
+	"\x06result\x18\x06 \x01(\tR\x06result\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\x12\x16\n" +
+	"\x06paused\x18\b \x01(\bR\x06paused\"\x14\n" +
+	"\x12ListClientsRequest\"H\n" +
+	"\x13ListClientsResponse\x121\n" +
+	"\aclients\x18\x01 \x03(\v2\x17.updater.v1.ClientEntryR\aclients\"W\n" +
+	"\vClientEntry\x12\x10\n" +
+	"\x03mac\x18\x01 \x01(\tR\x03mac\x12\x19\n" +
+	"\bgroup_id\x18\x02 \x01(\tR\agroupId\x12\x1b\n" +
+	"\tlast_ipv6\x18\x03 \x01(\tR\blastIpv6\"\x12\n" +
+	"\x10ReconcileRequest\"\x13\n" +
+	"\x11ReconcileResponse\"\x15\n" +
+	"\x13StreamEventsRequest\"\xe4\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1a\n" +
+	"\bseverity\x18\x02 \x01(\tR\bseverity\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12\x10\n" +
+	"\x03mac\x18\x04 \x01(\tR\x03mac\x12\x19\n" +
+	"\bgroup_id\x18\x05 \x01(\tR\agroupId\x12\x19\n" +
+	"\bold_ipv6\x18\x06 \x01(\tR\aoldIpv6\x12\x19\n" +
+	"\bnew_ipv6\x18\a \x01(\tR\anewIpv6\x12.\n" +
+	"\x04time\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x04time2\xba\x02\n" +
+	"\x0eUpdaterService\x12H\n" +
+	"\tGetStatus\x12\x1c.updater.v1.GetStatusRequest\x1a\x1d.updater.v1.GetStatusResponse\x12N\n" +
+	"\vListClients\x12\x1e.updater.v1.ListClientsRequest\x1a\x1f.updater.v1.ListClientsResponse\x12H\n" +
+	"\tReconcile\x12\x1c.updater.v1.ReconcileRequest\x1a\x1d.updater.v1.ReconcileResponse\x12D\n" +
+	"\fStreamEvents\x12\x1f.updater.v1.StreamEventsRequest\x1a\x11.updater.v1.Event0\x01BVZTgithub.com/brendann993/unifi-ipv6-client-firewall-updater/proto/updater/v1;updaterv1b\x06proto3"
+
+var (
+	file_updater_v1_updater_proto_rawDescOnce sync.Once
+	file_updater_v1_updater_proto_rawDescData []byte
+)
+
+func file_updater_v1_updater_proto_rawDescGZIP() []byte {
+	file_updater_v1_updater_proto_rawDescOnce.Do(func() {
+		file_updater_v1_updater_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_updater_v1_updater_proto_rawDesc), len(file_updater_v1_updater_proto_rawDesc)))
+	})
+	return file_updater_v1_updater_proto_rawDescData
+}
+
+var file_updater_v1_updater_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_updater_v1_updater_proto_goTypes = []any{
+	(*GetStatusRequest)(nil),      // 0: updater.v1.GetStatusRequest
+	(*GetStatusResponse)(nil),     // 1: updater.v1.GetStatusResponse
+	(*Summary)(nil),               // 2: updater.v1.Summary
+	(*ClientStatus)(nil),          // 3: updater.v1.ClientStatus
+	(*ListClientsRequest)(nil),    // 4: updater.v1.ListClientsRequest
+	(*ListClientsResponse)(nil),   // 5: updater.v1.ListClientsResponse
+	(*ClientEntry)(nil),           // 6: updater.v1.ClientEntry
+	(*ReconcileRequest)(nil),      // 7: updater.v1.ReconcileRequest
+	(*ReconcileResponse)(nil),     // 8: updater.v1.ReconcileResponse
+	(*StreamEventsRequest)(nil),   // 9: updater.v1.StreamEventsRequest
+	(*Event)(nil),                 // 10: updater.v1.Event
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_updater_v1_updater_proto_depIdxs = []int32{
+	11, // 0: updater.v1.GetStatusResponse.timestamp:type_name -> google.protobuf.Timestamp
+	2,  // 1: updater.v1.GetStatusResponse.summary:type_name -> updater.v1.Summary
+	3,  // 2: updater.v1.GetStatusResponse.clients:type_name -> updater.v1.ClientStatus
+	11, // 3: updater.v1.ClientStatus.last_changed:type_name -> google.protobuf.Timestamp
+	6,  // 4: updater.v1.ListClientsResponse.clients:type_name -> updater.v1.ClientEntry
+	11, // 5: updater.v1.Event.time:type_name -> google.protobuf.Timestamp
+	0,  // 6: updater.v1.UpdaterService.GetStatus:input_type -> updater.v1.GetStatusRequest
+	4,  // 7: updater.v1.UpdaterService.ListClients:input_type -> updater.v1.ListClientsRequest
+	7,  // 8: updater.v1.UpdaterService.Reconcile:input_type -> updater.v1.ReconcileRequest
+	9,  // 9: updater.v1.UpdaterService.StreamEvents:input_type -> updater.v1.StreamEventsRequest
+	1,  // 10: updater.v1.UpdaterService.GetStatus:output_type -> updater.v1.GetStatusResponse
+	5,  // 11: updater.v1.UpdaterService.ListClients:output_type -> updater.v1.ListClientsResponse
+	8,  // 12: updater.v1.UpdaterService.Reconcile:output_type -> updater.v1.ReconcileResponse
+	10, // 13: updater.v1.UpdaterService.StreamEvents:output_type -> updater.v1.Event
+	10, // [10:14] is the sub-list for method output_type
+	6,  // [6:10] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_updater_v1_updater_proto_init() }
+func file_updater_v1_updater_proto_init() {
+	if File_updater_v1_updater_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_updater_v1_updater_proto_rawDesc), len(file_updater_v1_updater_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_updater_v1_updater_proto_goTypes,
+		DependencyIndexes: file_updater_v1_updater_proto_depIdxs,
+		MessageInfos:      file_updater_v1_updater_proto_msgTypes,
+	}.Build()
+	File_updater_v1_updater_proto = out.File
+	file_updater_v1_updater_proto_goTypes = nil
+	file_updater_v1_updater_proto_depIdxs = nil
+}